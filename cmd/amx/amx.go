@@ -0,0 +1,232 @@
+package amx
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/pkg/amx"
+)
+
+// AmxCmd represents the amx command
+var AmxCmd = &cobra.Command{
+	Use:   "amx",
+	Short: "Inspect compiled .amx files",
+	Long: `Amx command looks inside compiled Pawn scripts: their header, the
+public functions they export and the natives they need from the server
+and its plugins.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+}
+
+// InfoCmd represents the amx info command
+var InfoCmd = &cobra.Command{
+	Use:   "info <file>",
+	Short: "Show the header and symbol tables of an .amx file",
+	Long: `Info command prints the header of an .amx file (magic, versions, flags,
+cell size, and code, data and stack/heap sizes) along with its publics,
+natives, libraries, public variables and tags, and whether it carries
+debug information from compiling with -d2 or -d3.
+
+Use --json to print the same information as JSON.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		f, err := amx.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error reading AMX file: %v\n", err)
+			return
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(f, "", "  ")
+			if err != nil {
+				fmt.Printf("Error reading AMX file: %v\n", err)
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		h := f.Header
+		fmt.Printf("File:        %s\n", args[0])
+		fmt.Printf("Magic:       %#04x (%d-bit cells)\n", h.Magic, f.CellSize*8)
+		fmt.Printf("Version:     file %d, AMX %d\n", h.FileVersion, h.AMXVersion)
+		fmt.Printf("Flags:       %#04x %s\n", h.Flags, strings.Join(f.FlagNames, ", "))
+		fmt.Printf("Code:        %d bytes\n", f.CodeSize)
+		fmt.Printf("Data:        %d bytes\n", f.DataSize)
+		fmt.Printf("Stack/heap:  %d bytes\n", f.StackHeapSize)
+		fmt.Printf("Main:        %s\n", yesNo(f.HasMain))
+		fmt.Printf("Debug info:  %s\n", yesNo(f.DebugInfo))
+
+		printSymbols("Publics", f.Publics, true)
+		printSymbols("Natives", f.Natives, false)
+		printSymbols("Libraries", f.Libraries, false)
+		printSymbols("Public variables", f.PubVars, true)
+
+		fmt.Printf("\nTags (%d):\n", len(f.Tags))
+		for _, t := range f.Tags {
+			fmt.Printf("  %-32s 0x%08x\n", t.Name, t.ID)
+		}
+	},
+}
+
+// DisasmCmd represents the amx disasm command
+var DisasmCmd = &cobra.Command{
+	Use:   "disasm <file>",
+	Short: "Disassemble the code of an .amx file",
+	Long: `Disasm command decodes the instructions of an .amx file, packed opcodes
+and compact encoding included, for scripts with 16, 32 or 64-bit cells.
+Calls and system requests are annotated with the function or native they
+refer to.
+
+When the script was compiled with -d2 or -d3, each function is labelled
+and each instruction is annotated with the source file and line it was
+compiled from. Without debug information public functions are labelled.
+
+Use --function to disassemble a single function.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		function, _ := cmd.Flags().GetString("function")
+
+		f, err := amx.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error reading AMX file: %v\n", err)
+			return
+		}
+		instructions, err := f.Disassemble()
+		if err != nil {
+			fmt.Printf("Error disassembling: %v\n", err)
+			return
+		}
+		debug, err := f.Debug()
+		if err != nil {
+			fmt.Printf("Warning: ignoring debug information: %v\n", err)
+		}
+
+		labels := functionLabels(f, debug)
+		width := 2 * f.CellSize
+		current := ""
+		found := false
+		for _, ins := range instructions {
+			if name, ok := labels[ins.Address]; ok {
+				current = name
+				if function == "" || function == current {
+					found = true
+					fmt.Printf("\n%s:\n", name)
+				}
+			}
+			if function != "" && function != current {
+				continue
+			}
+
+			// Format the operands as unsigned cells
+			operands := make([]string, len(ins.Operands))
+			for i, v := range ins.Operands {
+				operands[i] = fmt.Sprintf("%0*x", width, uint64(v)&(1<<(8*f.CellSize)-1))
+			}
+			line := fmt.Sprintf("%0*x  %-14s %s", width, ins.Address, ins.Name, strings.Join(operands, " "))
+
+			var notes []string
+			if note := operandNote(f, labels, ins); note != "" {
+				notes = append(notes, note)
+			}
+			if loc := f.Locate(ins.Address); loc.Line > 0 {
+				notes = append(notes, fmt.Sprintf("%s:%d", loc.File, loc.Line))
+			}
+			if len(notes) > 0 {
+				line = fmt.Sprintf("%-48s ; %s", line, strings.Join(notes, "  "))
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+
+		if function != "" && !found {
+			fmt.Printf("Error disassembling: no function %s\n", function)
+		}
+	},
+}
+
+// functionLabels returns the names of the functions by their address:
+// every function from the debug information or, without it, the publics
+// and main
+func functionLabels(f *amx.File, debug *amx.Debug) map[uint64]string {
+	labels := map[uint64]string{}
+	if debug != nil {
+		for _, s := range debug.Symbols {
+			if s.Ident == amx.IdentFunction {
+				labels[s.CodeStart] = s.Name
+			}
+		}
+		return labels
+	}
+
+	for _, p := range f.Publics {
+		labels[p.Address] = p.Name
+	}
+	if f.HasMain {
+		labels[uint64(f.Header.CIP)] = "main"
+	}
+	return labels
+}
+
+// operandNote names what the operand of a call or system request refers to
+func operandNote(f *amx.File, labels map[uint64]string, ins amx.Instruction) string {
+	if len(ins.Operands) == 0 {
+		return ""
+	}
+	switch ins.Opcode {
+	case amx.OpCall:
+		if name, ok := labels[uint64(ins.Operands[0])]; ok {
+			return name
+		}
+	case amx.OpSysreqC, amx.OpSysreqN:
+		if i := ins.Operands[0]; i >= 0 && int(i) < len(f.Natives) {
+			return f.Natives[i].Name
+		}
+	}
+	return ""
+}
+
+// printSymbols prints a symbol table, with addresses when they are known
+func printSymbols(title string, symbols []amx.Symbol, addresses bool) {
+	fmt.Printf("\n%s (%d):\n", title, len(symbols))
+	for _, s := range symbols {
+		if addresses {
+			fmt.Printf("  %-32s 0x%08x\n", s.Name, s.Address)
+		} else {
+			fmt.Printf("  %s\n", s.Name)
+		}
+	}
+}
+
+// yesNo formats a boolean for the report
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	// Add subcommands
+	AmxCmd.AddCommand(InfoCmd)
+	AmxCmd.AddCommand(DisasmCmd)
+
+	// Add flags
+	InfoCmd.Flags().Bool("json", false, "Print the information as JSON")
+	DisasmCmd.Flags().StringP("function", "f", "", "Disassemble only this function")
+}
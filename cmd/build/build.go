@@ -2,9 +2,13 @@ package build
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/weltschmerzie/omp-cli/internal/builder"
+	"github.com/weltschmerzie/omp-cli/internal/progress"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
 )
 
 // BuildCmd represents the build command
@@ -12,9 +16,25 @@ var BuildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build/compile the open.mp project",
 	Long: `Build command compiles the open.mp project.
-It will look for the project files in the current directory
-and compile them according to open.mp specifications.
-It uses project.json for project configuration and config.json for server settings.`,
+It will look for the project files in the current directory or the
+nearest parent directory containing project.json, and compile them
+according to open.mp specifications.
+It uses project.json for project configuration and config.json for server settings.
+
+Inside a workspace (workspace.json), building from the workspace root
+compiles every member, libraries first; use --member to pick one.
+
+Plugins are staged for the system ompcli runs on; use --target-os to
+stage the .so or .dll files for a Linux or Windows server instead.
+Use --profile to stage config.<profile>.json instead of config.json.
+
+After compiling, the natives the script calls are checked against those
+open.mp and the configured plugins provide. Missing ones are a warning, or
+an error when project.json sets "check_natives" to "error".
+
+Progress is shown as a live line per project on a terminal and as plain
+lines when the output is redirected or the CI variable is set; use
+--progress to choose. --verbose shows the full compiler output instead.`,
 	DisableFlagParsing:    false,
 	DisableAutoGenTag:     true,
 	DisableFlagsInUseLine: false,
@@ -22,18 +42,97 @@ It uses project.json for project configuration and config.json for server settin
 	Run: func(cmd *cobra.Command, args []string) {
 		// Get flags
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		member, _ := cmd.Flags().GetString("member")
+		targetOS, _ := cmd.Flags().GetString("target-os")
+		profile, _ := cmd.Flags().GetString("profile")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		progressMode, _ := cmd.Flags().GetString("progress")
+
+		switch targetOS {
+		case "", "linux", "windows":
+		default:
+			fmt.Printf("Error building project: unsupported target OS %q (use linux or windows)\n", targetOS)
+			return
+		}
+
+		// Locate the workspace and the projects to build
+		ws, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
+			fmt.Printf("Error building project: %v\n", err)
+			return
+		}
 
-		// Execute build
-		if err := builder.Build(verbose); err != nil {
+		members, err = ws.BuildOrder(members)
+		if err != nil {
 			fmt.Printf("Error building project: %v\n", err)
 			return
 		}
 
+		tty, err := progress.Interactive(progressMode, os.Stdout)
+		if err != nil {
+			fmt.Printf("Error building project: %v\n", err)
+			return
+		}
+
+		// Show progress from build events unless the compiler output is wanted
+		var renderer *progress.Renderer
+		if !verbose {
+			names := make([]string, len(members))
+			for i, m := range members {
+				names[i] = m.Name
+			}
+			renderer = progress.New(os.Stdout, tty, names)
+		}
+
+		for _, m := range members {
+			if len(members) > 1 && verbose {
+				fmt.Printf("==> Building %s\n", m.Name)
+			}
+
+			includes, err := ws.IncludePaths(m)
+			if err != nil {
+				closeRenderer(renderer)
+				fmt.Printf("Error building %s: %v\n", m.Name, err)
+				return
+			}
+
+			// Execute build
+			opts := builder.Options{
+				Verbose:       verbose,
+				IncludePaths:  includes,
+				PawnccPath:    ws.PawnccPath(),
+				PawnccVersion: ws.Config.PawnccVersion,
+				TargetOS:      targetOS,
+				Profile:       profile,
+			}
+			if renderer != nil {
+				opts.Stdout = io.Discard
+				opts.OnEvent = renderer.Event
+			}
+			if _, err := builder.Build(cmd.Context(), m.Root, opts); err != nil {
+				closeRenderer(renderer)
+				fmt.Printf("Error building project: %v\n", err)
+				return
+			}
+		}
+
+		closeRenderer(renderer)
 		fmt.Println("Project built successfully!")
 	},
 }
 
+// closeRenderer stops the progress display, if there is one
+func closeRenderer(r *progress.Renderer) {
+	if r != nil {
+		r.Close()
+	}
+}
+
 func init() {
 	// Add flags
 	BuildCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
+	BuildCmd.Flags().StringP("member", "m", "", "Build only this workspace member")
+	BuildCmd.Flags().String("profile", "", "Stage config.<profile>.json as the server configuration")
+	BuildCmd.Flags().String("progress", progress.ModeAuto, "How to show progress (auto, tty or plain)")
+	BuildCmd.Flags().String("target-os", "", "Stage plugins for this OS (linux or windows, default: this system)")
 }
@@ -0,0 +1,101 @@
+package clean
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/clean"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+)
+
+// CleanCmd represents the clean command
+var CleanCmd = &cobra.Command{
+	Use:   "clean [scope...]",
+	Short: "Remove build output",
+	Long: `Clean command removes build output selected by scope:
+
+  artifacts - compiled scripts (.amx) in build/
+  staged    - files staged into build/ by the last build
+  cache     - the download cache in .ompcli/cache
+  logs      - logs written by the server (log.txt, *.log, logs/)
+  all       - everything in build/ and the cache
+
+Without a scope, artifacts and staged files are removed. Runtime data in
+build/scriptfiles/ is kept unless --include-data is given. Use --dry-run
+to list what would be removed.
+
+Inside a workspace (workspace.json), cleaning from the workspace root
+cleans every member; use --member to pick one.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		includeData, _ := cmd.Flags().GetBool("include-data")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		scopes := args
+		if len(scopes) == 0 {
+			scopes = []string{clean.ScopeArtifacts, clean.ScopeStaged}
+		}
+
+		// Locate the workspace and the projects to clean
+		_, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
+			fmt.Printf("Error cleaning project: %v\n", err)
+			return
+		}
+
+		removed := 0
+		for _, m := range members {
+			paths, err := clean.Plan(m.Root, clean.Options{Scopes: scopes, IncludeData: includeData})
+			if err != nil {
+				fmt.Printf("Error cleaning %s: %v\n", m.Name, err)
+				return
+			}
+
+			for _, p := range paths {
+				rel, err := filepath.Rel(m.Root, p)
+				if err != nil {
+					rel = p
+				}
+				if len(members) > 1 {
+					rel = filepath.Join(m.Name, rel)
+				}
+				if dryRun {
+					fmt.Printf("Would remove %s\n", rel)
+				} else {
+					fmt.Printf("Removing %s\n", rel)
+				}
+			}
+
+			if !dryRun {
+				if err := clean.Remove(m.Root, paths); err != nil {
+					fmt.Printf("Error cleaning %s: %v\n", m.Name, err)
+					return
+				}
+			}
+			removed += len(paths)
+		}
+
+		switch {
+		case removed == 0:
+			fmt.Println("Nothing to clean.")
+		case dryRun:
+			fmt.Printf("%d paths would be removed.\n", removed)
+		default:
+			fmt.Printf("Removed %d paths.\n", removed)
+		}
+	},
+}
+
+func init() {
+	// Add flags
+	CleanCmd.Flags().BoolP("dry-run", "n", false, "List what would be removed without removing it")
+	CleanCmd.Flags().Bool("include-data", false, "Also remove runtime data such as build/scriptfiles")
+	CleanCmd.Flags().StringP("member", "m", "", "Clean only this workspace member")
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/configedit"
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// ConfigCmd represents the config command
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and edit project.json and config.json",
+	Long: `Config command reads and edits the project configuration (project.json)
+and the server configuration (config.json) without disturbing their
+formatting or key order.
+
+Paths are prefixed with the file they refer to, "project:" or "server:"
+(project.json is used when no prefix is given), or "user:" for the
+user-level configuration, and use dots and brackets for nested values:
+
+  ompcli config get server:port
+  ompcli config set server:maxplayers 200
+  ompcli config set project:plugins+=plugins/streamer.so
+  ompcli config set project:plugins-=plugins/streamer.so
+  ompcli config unset project:repository
+  ompcli config set user:registry_mirror /mnt/mirror`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+}
+
+// GetCmd represents the config get command
+var GetCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "Print a configuration value",
+	Long: `Get command prints the value stored at the given path.
+Strings are printed without quotes unless --json is given; arrays and
+objects are always printed as JSON.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		file, path, err := open(cmd, args[0])
+		if err != nil {
+			fail("Error reading configuration: %v", err)
+		}
+
+		value, err := file.Get(path)
+		if err != nil {
+			fail("Error reading configuration: %v", err)
+		}
+
+		if value.Kind == jsonedit.String && !asJSON {
+			var s string
+			_ = value.Decode(&s)
+			fmt.Println(s)
+			return
+		}
+		fmt.Println(string(value.Indented(file.Doc.Indent)))
+	},
+}
+
+// SetCmd represents the config set command
+var SetCmd = &cobra.Command{
+	Use:   "set <path> <value> | <path>=<value> | <path>+=<value> | <path>-=<value>",
+	Short: "Change a configuration value",
+	Long: `Set command stores a value at the given path.
+The value is converted to the type the configuration expects (number,
+boolean, string or JSON for lists and objects). A change that makes the
+file invalid is refused, while problems the file already had are reported
+as warnings. Keys config.json does not know, often misspelt ones such as
+"server:max_players", are refused unless --force is given; unknown keys of
+project.json and the user configuration are reported as warnings. Use "+="
+to append to a list and "-=" to remove an entry from it.`,
+	Args:                  cobra.RangeArgs(1, 2),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: true,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Split "path op value" into its parts
+		arg, op, raw := args[0], "=", ""
+		if len(args) == 2 {
+			raw = args[1]
+		} else {
+			i := strings.Index(arg, "=")
+			if i < 0 {
+				fail("Error: missing value (use <path> <value> or <path>=<value>)")
+			}
+			arg, raw = arg[:i], arg[i+1:]
+			if strings.HasSuffix(arg, "+") || strings.HasSuffix(arg, "-") {
+				op = arg[len(arg)-1:] + "="
+				arg = arg[:len(arg)-1]
+			}
+		}
+
+		file, path, err := open(cmd, arg)
+		if err != nil {
+			fail("Error reading configuration: %v", err)
+		}
+		file.Force, _ = cmd.Flags().GetBool("force")
+
+		switch op {
+		case "+=":
+			err = file.Append(path, raw)
+		case "-=":
+			err = file.Remove(path, raw)
+		default:
+			err = file.Set(path, raw)
+		}
+		if err != nil {
+			printWarnings(file)
+			fail("Error updating configuration: %v", err)
+		}
+
+		err = file.Save()
+		printWarnings(file)
+		if err != nil {
+			fail("Error saving configuration: %v", err)
+		}
+	},
+}
+
+// UnsetCmd represents the config unset command
+var UnsetCmd = &cobra.Command{
+	Use:                   "unset <path>",
+	Short:                 "Remove a configuration value",
+	Long:                  `Unset command removes the value stored at the given path.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, path, err := open(cmd, args[0])
+		if err != nil {
+			fail("Error reading configuration: %v", err)
+		}
+
+		if err := file.Unset(path); err != nil {
+			fail("Error updating configuration: %v", err)
+		}
+
+		err = file.Save()
+		printWarnings(file)
+		if err != nil {
+			fail("Error saving configuration: %v", err)
+		}
+	},
+}
+
+func init() {
+	// Add subcommands
+	ConfigCmd.AddCommand(GetCmd)
+	ConfigCmd.AddCommand(SetCmd)
+	ConfigCmd.AddCommand(UnsetCmd)
+
+	// Add flags
+	GetCmd.Flags().Bool("json", false, "Print strings as JSON")
+	SetCmd.Flags().Bool("force", false, "Set keys config.json does not know")
+}
+
+// fail prints an error and exits with a non-zero status, so that scripts
+// notice when the configuration could not be read or changed
+func fail(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
+	os.Exit(1)
+}
+
+// printWarnings prints the problems found while editing file
+func printWarnings(file *configedit.File) {
+	for _, warning := range file.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+}
+
+// open loads the file a "target:path" argument refers to and parses the path
+func open(cmd *cobra.Command, arg string) (*configedit.File, jsonedit.Path, error) {
+	target, rawPath, err := configedit.SplitTarget(arg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The user configuration does not belong to a project
+	root := ""
+	if target != configedit.TargetUser {
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		if root, err = utils.FindProjectRoot(projectDir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	path, err := jsonedit.ParsePath(rawPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := configedit.Open(root, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, path, nil
+}
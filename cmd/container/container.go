@@ -0,0 +1,98 @@
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/container"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// ContainerCmd represents the container command
+var ContainerCmd = &cobra.Command{
+	Use:   "container",
+	Short: "Generate a container image build context",
+	Long: `Container command generates a container image build context in
+dist/container from the files the last build staged into build/:
+
+  Dockerfile     - installs the 32-bit runtime and runs the server as a
+                   non-root user, with scriptfiles as a volume
+  entrypoint.sh  - renders config.json, replacing ${VAR} and ${VAR:-default}
+                   with environment variables, and forwards SIGTERM and
+                   SIGINT to the server
+  healthcheck.sh - asks the server for its info over the query protocol
+  .dockerignore  - keeps logs out of the image
+  server/        - the staged server, plugins, components and scripts
+
+Build the project for Linux first. Docker is not needed to generate the
+context, only to build the image from it.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		output, _ := cmd.Flags().GetString("output")
+		baseImage, _ := cmd.Flags().GetString("base-image")
+		port, _ := cmd.Flags().GetInt("port")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		if port < 0 || port > 65535 {
+			fmt.Println("Error generating container context: port must be between 1 and 65535")
+			return
+		}
+
+		// Locate the workspace and the projects to containerize
+		_, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
+			fmt.Printf("Error generating container context: %v\n", err)
+			return
+		}
+
+		var servers []*workspace.Member
+		for _, m := range members {
+			if m.Config.ProjectType() != utils.ProjectTypeLibrary {
+				servers = append(servers, m)
+			}
+		}
+		if len(servers) == 0 {
+			fmt.Println("Nothing to containerize.")
+			return
+		}
+		if output != "" && len(servers) > 1 {
+			fmt.Println("Error generating container context: --output needs a single project, use --member")
+			return
+		}
+
+		for _, m := range servers {
+			result, err := container.Generate(m.Root, container.Options{
+				OutputDir: output,
+				BaseImage: baseImage,
+				Port:      port,
+			})
+			if err != nil {
+				fmt.Printf("Error generating container context for %s: %v\n", m.Name, err)
+				return
+			}
+
+			dir := result.Dir
+			if rel, err := filepath.Rel(m.Root, dir); err == nil {
+				dir = rel
+			}
+			fmt.Printf("Generated container context in %s (%d files copied, %d unchanged)\n",
+				result.Dir, result.Stats.Copied+result.Stats.Linked, result.Stats.Skipped)
+			fmt.Printf("Build it with: docker build -t %s %s\n", container.Tag(m.Config), dir)
+		}
+	},
+}
+
+func init() {
+	// Add flags
+	ContainerCmd.Flags().StringP("output", "o", "", "Directory to write the context to (default: dist/container)")
+	ContainerCmd.Flags().String("base-image", container.DefaultBaseImage, "Image to base the server image on")
+	ContainerCmd.Flags().Int("port", 0, "Port the server listens on (default: port from config.json)")
+	ContainerCmd.Flags().StringP("member", "m", "", "Generate the context for only this workspace member")
+}
@@ -0,0 +1,250 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/builder"
+	"github.com/weltschmerzie/omp-cli/internal/deploy"
+	"github.com/weltschmerzie/omp-cli/internal/packager"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// DeployCmd represents the deploy command
+var DeployCmd = &cobra.Command{
+	Use:   "deploy <target>",
+	Short: "Deploy the project to a target",
+	Long: `Deploy command builds and packages the project and uploads it to a
+target from the deploy section of project.json:
+
+  "deploy": {
+    "production": {
+      "host": "omp@example.com",
+      "path": "/srv/omp",
+      "keep": 5,
+      "shared": ["scriptfiles"],
+      "post_deploy": ["systemctl --user restart omp"]
+    }
+  }
+
+Targets without a host are local directories; hosts are reached with ssh.
+Every deploy goes to a new folder in <path>/releases, after which the
+<path>/current symlink is switched to it in one step. The newest "keep"
+releases are kept for ompcli rollback. Shared paths are kept in
+<path>/shared and linked into every release.
+
+pre_deploy hooks run in <path> before the upload and post_deploy hooks in
+<path>/current after the switch, with OMPCLI_RELEASE, OMPCLI_PREVIOUS_RELEASE
+and OMPCLI_DEPLOY_PATH set.
+
+Use --package to deploy an archive made by ompcli package instead of
+building a new one. Hosts reached with ssh need a .tar.gz package. As with
+ompcli package, the build replaces a development build in build/.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		archive, _ := cmd.Flags().GetString("package")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		name := args[0]
+
+		if archive != "" {
+			if abs, err := filepath.Abs(archive); err == nil {
+				archive = abs
+			}
+		}
+
+		// Locate the workspace and the projects with this target
+		ws, members, err := targetMembers(projectDir, member, name)
+		if err != nil {
+			fmt.Printf("Error deploying project: %v\n", err)
+			return
+		}
+		if archive != "" && len(members) > 1 {
+			fmt.Println("Error deploying project: --package needs a single project, use --member")
+			return
+		}
+
+		for _, m := range members {
+			target := m.Config.Deploy[name]
+			if len(members) > 1 {
+				fmt.Printf("==> Deploying %s\n", m.Name)
+			}
+
+			path := archive
+			if path == "" {
+				tmp, err := os.MkdirTemp("", "ompcli-deploy-")
+				if err != nil {
+					fmt.Printf("Error deploying project: %v\n", err)
+					return
+				}
+				defer os.RemoveAll(tmp)
+
+				if path, err = buildPackage(cmd.Context(), ws, m, target, tmp); err != nil {
+					fmt.Printf("Error deploying %s: %v\n", m.Name, err)
+					return
+				}
+			}
+
+			result, err := deploy.Deploy(target, path, deploy.Options{Out: os.Stdout})
+			if err != nil {
+				fmt.Printf("Error deploying %s: %v\n", m.Name, err)
+				return
+			}
+			for _, release := range result.Pruned {
+				fmt.Printf("Removed old release %s\n", release)
+			}
+			fmt.Printf("Deployed release %s to %s\n", result.Release, name)
+		}
+	},
+}
+
+// RollbackCmd represents the rollback command
+var RollbackCmd = &cobra.Command{
+	Use:   "rollback <target> [release]",
+	Short: "Switch a target back to an earlier release",
+	Long: `Rollback command switches the current symlink of a deploy target back to
+an earlier release: the given one, or the release deployed before the
+current one. post_deploy hooks run afterwards, as for a deploy.
+
+Use --list to show the releases kept on the target.`,
+	Args:                  cobra.RangeArgs(1, 2),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		list, _ := cmd.Flags().GetBool("list")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		name := args[0]
+
+		var release string
+		if len(args) > 1 {
+			release = args[1]
+		}
+
+		// Locate the workspace and the projects with this target
+		_, members, err := targetMembers(projectDir, member, name)
+		if err != nil {
+			fmt.Printf("Error rolling back: %v\n", err)
+			return
+		}
+		if len(members) > 1 {
+			fmt.Println("Error rolling back: several workspace members deploy to this target, use --member")
+			return
+		}
+		target := members[0].Config.Deploy[name]
+
+		if list {
+			releases, err := deploy.List(target)
+			if err != nil {
+				fmt.Printf("Error listing releases: %v\n", err)
+				return
+			}
+			if len(releases) == 0 {
+				fmt.Printf("No releases on %s.\n", name)
+				return
+			}
+			for _, r := range releases {
+				marker := " "
+				if r.Current {
+					marker = "*"
+				}
+				fmt.Printf("%s %s\n", marker, r.ID)
+			}
+			return
+		}
+
+		result, err := deploy.Rollback(target, release, deploy.Options{Out: os.Stdout})
+		if err != nil {
+			fmt.Printf("Error rolling back: %v\n", err)
+			return
+		}
+		fmt.Printf("Rolled %s back from %s to %s\n", name, result.Previous, result.Release)
+	},
+}
+
+func init() {
+	// Add flags
+	DeployCmd.Flags().String("package", "", "Deploy this package archive instead of building one")
+	DeployCmd.Flags().StringP("member", "m", "", "Deploy only this workspace member")
+
+	RollbackCmd.Flags().Bool("list", false, "List the releases on the target")
+	RollbackCmd.Flags().StringP("member", "m", "", "Roll back only this workspace member")
+}
+
+// targetMembers returns the workspace members that define the deploy target
+func targetMembers(projectDir, member, name string) (*workspace.Workspace, []*workspace.Member, error) {
+	ws, members, err := workspace.Resolve(projectDir, member)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var found []*workspace.Member
+	known := map[string]bool{}
+	for _, m := range members {
+		for target := range m.Config.Deploy {
+			known[target] = true
+		}
+		if m.Config.Deploy[name] != nil {
+			found = append(found, m)
+		}
+	}
+
+	if len(found) == 0 {
+		if len(known) == 0 {
+			return nil, nil, fmt.Errorf("no deploy targets are defined in project.json")
+		}
+		var names []string
+		for target := range known {
+			names = append(names, target)
+		}
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("unknown deploy target %q (defined: %s)", name, strings.Join(names, ", "))
+	}
+	return ws, found, nil
+}
+
+// buildPackage builds a member for a target and packages it into dir
+func buildPackage(ctx context.Context, ws *workspace.Workspace, m *workspace.Member, target *utils.DeployTarget, dir string) (string, error) {
+	profile := target.Profile
+	if profile == "" {
+		profile = packager.DefaultProfile
+	}
+
+	includes, err := ws.IncludePaths(m)
+	if err != nil {
+		return "", err
+	}
+	opts := builder.Options{
+		IncludePaths:  includes,
+		PawnccPath:    ws.PawnccPath(),
+		PawnccVersion: ws.Config.PawnccVersion,
+		TargetOS:      target.TargetOS,
+		Profile:       profile,
+	}
+	if notice := packager.BuildNotice(m.Root, profile, target.TargetOS); notice != "" {
+		fmt.Println(notice)
+	}
+	if _, err := builder.Build(ctx, m.Root, opts); err != nil {
+		return "", err
+	}
+
+	// Remote hosts unpack the package with tar
+	return packager.Package(m.Root, packager.Options{
+		Format:    "tar.gz",
+		OutputDir: dir,
+	})
+}
@@ -0,0 +1,217 @@
+package deps
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/deps"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// InstallCmd represents the install command
+var InstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the project's dependencies",
+	Long: `Install command resolves the dependencies declared in project.json,
+unpacks them into the dependencies/ folder and records the exact versions
+and checksums in ompcli.lock. When ompcli.lock is up to date, the locked
+versions are installed as-is.
+
+Packages come from the project's vendor/ folder, the registry_mirror of
+the user configuration, and the registry set in the "registry" key of
+project.json or the OMPCLI_REGISTRY environment variable: a directory,
+a file:// URL or an http(s):// URL. In offline mode ("offline": true in
+the user configuration, or OMPCLI_OFFLINE=1) only local directories are
+used.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		frozen, _ := cmd.Flags().GetBool("frozen")
+
+		manager, err := newManager(cmd)
+		if err != nil {
+			fmt.Printf("Error installing dependencies: %v\n", err)
+			return
+		}
+
+		lock, err := manager.Install(deps.InstallOptions{Frozen: frozen})
+		if err != nil {
+			fmt.Printf("Error installing dependencies: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Installed %d dependencies.\n", len(lock.Packages))
+	},
+}
+
+// AddCmd represents the add command
+var AddCmd = &cobra.Command{
+	Use:   "add <package>[@<constraint>]...",
+	Short: "Add dependencies to the project",
+	Long: `Add command declares dependencies in project.json and installs them.
+Without a constraint the newest release is used, pinned with a caret
+constraint (for example "streamer" becomes "streamer": "^2.9.6").`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, err := newManager(cmd)
+		if err != nil {
+			fmt.Printf("Error adding dependencies: %v\n", err)
+			return
+		}
+
+		var names []string
+		for _, arg := range args {
+			name, constraint, _ := strings.Cut(arg, "@")
+			if err := manager.Add(name, constraint); err != nil {
+				fmt.Printf("Error adding %s: %v\n", name, err)
+				return
+			}
+			names = append(names, name)
+		}
+
+		if _, err := manager.Install(deps.InstallOptions{Update: names}); err != nil {
+			fmt.Printf("Error installing dependencies: %v\n", err)
+			return
+		}
+	},
+}
+
+// RemoveCmd represents the remove command
+var RemoveCmd = &cobra.Command{
+	Use:                   "remove <package>...",
+	Short:                 "Remove dependencies from the project",
+	Long:                  `Remove command drops dependencies from project.json, ompcli.lock and the dependencies/ folder.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, err := newManager(cmd)
+		if err != nil {
+			fmt.Printf("Error removing dependencies: %v\n", err)
+			return
+		}
+
+		for _, name := range args {
+			if err := manager.Remove(name); err != nil {
+				fmt.Printf("Error removing %s: %v\n", name, err)
+				return
+			}
+		}
+
+		if _, err := manager.Install(deps.InstallOptions{}); err != nil {
+			fmt.Printf("Error installing dependencies: %v\n", err)
+			return
+		}
+	},
+}
+
+// UpdateCmd represents the update command
+var UpdateCmd = &cobra.Command{
+	Use:   "update [package...]",
+	Short: "Update dependencies to the newest allowed versions",
+	Long: `Update command moves the given dependencies, or all of them when none
+are given, to the newest versions their constraints in project.json allow,
+and rewrites ompcli.lock.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, err := newManager(cmd)
+		if err != nil {
+			fmt.Printf("Error updating dependencies: %v\n", err)
+			return
+		}
+
+		opts := deps.InstallOptions{Update: args, UpdateAll: len(args) == 0}
+		lock, err := manager.Install(opts)
+		if err != nil {
+			fmt.Printf("Error updating dependencies: %v\n", err)
+			return
+		}
+
+		for _, name := range lockedNames(lock) {
+			fmt.Printf("%s@%s\n", name, lock.Packages[name].Version)
+		}
+	},
+}
+
+// VendorCmd represents the vendor command
+var VendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Copy all dependencies into the project",
+	Long: `Vendor command copies the archives of every dependency in ompcli.lock,
+includes and plugin binaries alike, into the vendor/ folder after
+verifying their checksums. The vendor/ folder is laid out as a package
+registry and is preferred by install, so a project with a committed
+vendor/ folder installs without network access.
+
+With --output the archives are added to another directory instead, for
+example to fill a shared registry mirror for offline build servers.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		output, _ := cmd.Flags().GetString("output")
+
+		manager, err := newManager(cmd)
+		if err != nil {
+			fmt.Printf("Error vendoring dependencies: %v\n", err)
+			return
+		}
+
+		// Only the project's own vendor folder is pruned
+		dir := filepath.Join(manager.Root, deps.VendorDir)
+		if output != "" {
+			dir = output
+		}
+
+		lock, err := manager.Vendor(dir, output == "")
+		if err != nil {
+			fmt.Printf("Error vendoring dependencies: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Vendored %d dependencies into %s.\n", len(lock.Packages), dir)
+	},
+}
+
+func init() {
+	// Add flags
+	InstallCmd.Flags().Bool("frozen", false, "Fail if ompcli.lock is out of date instead of updating it")
+	VendorCmd.Flags().StringP("output", "o", "", "Add the archives to this directory instead of vendor/")
+}
+
+// newManager creates a dependency manager for the current project
+func newManager(cmd *cobra.Command) (*deps.Manager, error) {
+	projectDir, _ := cmd.Flags().GetString("project-dir")
+	root, err := utils.FindProjectRoot(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	return deps.NewManager(root)
+}
+
+// lockedNames returns the names of the locked packages in sorted order
+func lockedNames(lock *deps.Lock) []string {
+	names := make([]string, 0, len(lock.Packages))
+	for name := range lock.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
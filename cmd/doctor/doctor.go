@@ -0,0 +1,84 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/doctor"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+)
+
+// DoctorCmd represents the doctor command
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose problems with the environment and the project",
+	Long: `Doctor command checks the things that commonly break builds and runs:
+a missing or mismatched pawncc, dependencies that are not installed,
+plugins built for another operating system, a server binary that is
+missing or not executable, and a port that is already in use.
+
+Each check is reported as pass, warn or fail along with a hint on how to
+fix it. Use --json to print the report for attaching to support tickets.
+The exit status is non-zero when a check fails.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		asJSON, _ := cmd.Flags().GetBool("json")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		// Machine checks still run outside of a project
+		ws, members, err := workspace.Resolve(projectDir, member)
+		if err != nil && member != "" {
+			fmt.Printf("Error running diagnostics: %v\n", err)
+			os.Exit(1)
+		}
+
+		report := doctor.Run(ws, members, err)
+
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Printf("Error running diagnostics: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			printReport(report)
+		}
+
+		// Scripts and CI see failed checks in the exit status
+		if report.Count(doctor.Fail) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// printReport prints the checks of a report and a summary
+func printReport(report *doctor.Report) {
+	for _, check := range report.Checks {
+		name := check.Name
+		if check.Project != "" {
+			name = check.Project + ": " + name
+		}
+		fmt.Printf("[%s] %s: %s\n", strings.ToUpper(string(check.Status)), name, check.Message)
+		if check.Hint != "" {
+			fmt.Printf("       hint: %s\n", check.Hint)
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d warnings, %d failed.\n",
+		report.Count(doctor.Pass), report.Count(doctor.Warn), report.Count(doctor.Fail))
+}
+
+func init() {
+	// Add flags
+	DoctorCmd.Flags().Bool("json", false, "Print the report as JSON")
+	DoctorCmd.Flags().StringP("member", "m", "", "Check only this workspace member")
+}
@@ -4,15 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/scaffold"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
 )
 
 // Project represents the structure of project.json
 type Project struct {
 	Name       string   `json:"name"`
 	Version    string   `json:"version"`
+	Type       string   `json:"type,omitempty"`
 	MainFile   string   `json:"main_file"`
 	OutputFile string   `json:"output_file"`
 	Resources  []string `json:"resources"`
@@ -40,8 +48,32 @@ type Server struct {
 var InitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a new open.mp project",
-	Long: `Init command creates a new open.mp project by generating
-the necessary configuration files (project.json and config.json).`,
+	Long: `Init command creates a new open.mp project from a template.
+
+Built-in templates:
+  gamemode     - a gamemode with a starter script (default)
+  filterscript - a filterscript loaded next to a gamemode
+  library      - an include library for other projects
+  minimal      - the smallest project that builds
+
+--template also takes a local directory or a git repository (a URL or a
+path ending in .git, optionally followed by #branch). {{name}}, {{author}},
+{{version}}, {{pawncc_path}}, {{ident}} and {{year}} are replaced in the
+template's file names and contents; --var sets more. A template.json in
+the template can describe it, declare variables with defaults and list
+hooks: commands run in the new project once it is created.
+
+Files that are not in the template get defaults: project.json, config.json
+and the main script project.json refers to.
+
+In a folder that already has sources, init adopts them: project.json
+points at the existing gamemode, and the plugins and settings of a
+server.cfg are carried over. Existing JSON files only get the keys they
+are missing and other existing files are kept; --force replaces them.
+Files are written all at once: if one fails, none are changed.
+
+Run in a terminal without flags, init asks for the project's settings and
+shows a summary before creating anything. Use --yes to skip the questions.`,
 	DisableFlagParsing:    false,
 	DisableAutoGenTag:     true,
 	DisableFlagsInUseLine: false,
@@ -51,44 +83,204 @@ the necessary configuration files (project.json and config.json).`,
 		name, _ := cmd.Flags().GetString("name")
 		author, _ := cmd.Flags().GetString("author")
 		pawnccPath, _ := cmd.Flags().GetString("pawncc-path")
+		source, _ := cmd.Flags().GetString("template")
+		extraVars, _ := cmd.Flags().GetStringArray("var")
+		port, _ := cmd.Flags().GetInt("port")
+		maxPlayers, _ := cmd.Flags().GetInt("max-players")
+		plugins, _ := cmd.Flags().GetStringSlice("plugins")
+		createGit, _ := cmd.Flags().GetBool("git")
+		yes, _ := cmd.Flags().GetBool("yes")
+		listTemplates, _ := cmd.Flags().GetBool("list-templates")
+		noHooks, _ := cmd.Flags().GetBool("no-hooks")
+		force, _ := cmd.Flags().GetBool("force")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		if listTemplates {
+			templates, err := scaffold.Builtins()
+			if err != nil {
+				fmt.Printf("Error listing templates: %v\n", err)
+				return
+			}
+			for _, t := range templates {
+				fmt.Printf("%-12s %s\n", t.Name, t.Manifest.Description)
+			}
+			return
+		}
+
+		// Initialize the given directory, or the current one
+		dir, err := filepath.Abs(projectDir)
+		if err != nil {
+			fmt.Printf("Error resolving project directory: %v\n", err)
+			return
+		}
 
-		// If name is not provided, use current directory name
+		// If name is not provided, use the project directory name
 		if name == "" {
-			currentDir, err := os.Getwd()
-			if err == nil {
-				name = filepath.Base(currentDir)
-			} else {
+			name = filepath.Base(dir)
+			if name == string(filepath.Separator) || name == "." {
 				name = "gamemode"
 			}
 		}
 
+		if err := checkName(name); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
 		// If pawncc path is not provided, use default
 		if pawnccPath == "" {
 			pawnccPath = "qawno"
 		}
 
-		// Create project.json
-		if err := createProjectJson(name, author, pawnccPath); err != nil {
-			fmt.Printf("Error creating project.json: %v\n", err)
+		if port < 0 || port > 65535 {
+			fmt.Println("Error: --port must be between 1 and 65535")
+			return
+		}
+		if maxPlayers < 0 || maxPlayers > 1000 {
+			fmt.Println("Error: --max-players must be between 1 and 1000")
+			return
+		}
+
+		a := &answers{
+			Name:       name,
+			Author:     author,
+			Template:   source,
+			PawnccPath: pawnccPath,
+			Port:       port,
+			MaxPlayers: maxPlayers,
+			Plugins:    plugins,
+			Git:        createGit,
+		}
+
+		// Ask for the settings when run in a terminal without flags
+		if !yes && interactive(cmd) {
+			ok, err := wizard(os.Stdin, os.Stdout, dir, a)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if !ok {
+				fmt.Println("Aborted, nothing was created.")
+				return
+			}
+		}
+
+		vars := map[string]string{
+			"name":        a.Name,
+			"author":      a.Author,
+			"version":     "1.0.0",
+			"pawncc_path": a.PawnccPath,
+			"year":        strconv.Itoa(time.Now().Year()),
+		}
+		for _, v := range extraVars {
+			key, value, ok := strings.Cut(v, "=")
+			if !ok || key == "" {
+				fmt.Printf("Error: --var must be key=value, got %q\n", v)
+				return
+			}
+			vars[key] = value
+		}
+
+		// Load and render the template
+		t, err := scaffold.Load(a.Template)
+		if err != nil {
+			fmt.Printf("Error loading template: %v\n", err)
+			return
+		}
+		defer t.Close()
+
+		files, err := t.Render(vars)
+		if err != nil {
+			fmt.Printf("Error rendering template: %v\n", err)
+			return
+		}
+		files, err = addDefaults(files, a.Name, a.Author, a.PawnccPath)
+		if err != nil {
+			fmt.Printf("Error creating project files: %v\n", err)
+			return
+		}
+
+		// Adopt the sources, server.cfg and plugins already in the folder
+		existing, err := scaffold.Detect(dir, a.Name)
+		if err != nil {
+			fmt.Printf("Error looking for existing sources: %v\n", err)
+			return
+		}
+		if existing != nil {
+			if files, err = existing.Adopt(files); err != nil {
+				fmt.Printf("Error adopting existing sources: %v\n", err)
+				return
+			}
+			if existing.MainFile != "" {
+				fmt.Printf("Adopting existing sources with %s as the main file\n", existing.MainFile)
+			}
+			if existing.ServerCfg != nil {
+				fmt.Println("Adopting settings from server.cfg")
+			}
+			if len(existing.Plugins) > 0 {
+				fmt.Printf("Adopting plugins: %s\n", strings.Join(existing.Plugins, ", "))
+			}
+
+			// The plugins asked for are added to the adopted ones
+			a.Plugins = mergeLists(existing.Plugins, a.Plugins)
+		}
+
+		if err := applySettings(files, a); err != nil {
+			fmt.Printf("Error creating project files: %v\n", err)
 			return
 		}
 
-		// Create config.json
-		if err := createConfigJson(name); err != nil {
-			fmt.Printf("Error creating config.json: %v\n", err)
+		// Work out what to do with files that already exist
+		changes, err := scaffold.Plan(dir, files, force)
+		if err != nil {
+			fmt.Printf("Error initializing project: %v\n", err)
 			return
 		}
 
-		// Create gamemodes directory if it doesn't exist
-		if err := os.MkdirAll("gamemodes", 0755); err != nil {
-			fmt.Printf("Warning: Failed to create gamemodes directory: %v\n", err)
+		// Write all files, or none of them
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Printf("Error creating project directory: %v\n", err)
+			return
+		}
+		if err := scaffold.Write(dir, changes); err != nil {
+			fmt.Printf("Error initializing project: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Project initialized successfully from the %s template!\n", t.Name)
+		for _, change := range changes {
+			switch {
+			case change.Action == scaffold.ActionKeep:
+				fmt.Printf("- %s (kept existing file)\n", change.Path)
+			case change.Unchanged():
+				fmt.Printf("- %s (up to date)\n", change.Path)
+			case change.Action == scaffold.ActionMerge:
+				fmt.Printf("- %s (added missing keys)\n", change.Path)
+			case change.Action == scaffold.ActionOverwrite:
+				fmt.Printf("- %s (overwritten)\n", change.Path)
+			default:
+				fmt.Printf("- %s (created)\n", change.Path)
+			}
+		}
+
+		// Create the git repository before hooks, which may commit
+		if a.Git {
+			if err := initGit(dir); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
 		}
 
-		fmt.Println("Project initialized successfully!")
-		fmt.Println("Created files:")
-		fmt.Println("- project.json")
-		fmt.Println("- config.json")
-		fmt.Println("- gamemodes/ directory")
+		// Run the template's hooks
+		if len(t.Manifest.Hooks) > 0 {
+			if noHooks {
+				fmt.Printf("Skipped %d template hooks.\n", len(t.Manifest.Hooks))
+				return
+			}
+			if err := t.RunHooks(dir, vars, os.Stdout); err != nil {
+				fmt.Printf("Error running template hooks: %v\n", err)
+				return
+			}
+		}
 	},
 }
 
@@ -97,21 +289,84 @@ func init() {
 	InitCmd.Flags().StringP("name", "n", "", "Project name (default: current directory name)")
 	InitCmd.Flags().StringP("author", "a", "", "Project author")
 	InitCmd.Flags().String("pawncc-path", "", "Path to pawncc compiler (default: qawno)")
+	InitCmd.Flags().StringP("template", "t", scaffold.DefaultTemplate, "Built-in template, template directory or git repository")
+	InitCmd.Flags().StringArray("var", nil, "Set a template variable (key=value); can be repeated")
+	InitCmd.Flags().Int("port", 0, "Server port (default: the template's, 7777)")
+	InitCmd.Flags().Int("max-players", 0, "Maximum number of players (default: the template's, 50)")
+	InitCmd.Flags().StringSlice("plugins", nil, "Plugins to add to project.json, separated by commas")
+	InitCmd.Flags().Bool("git", false, "Create a git repository")
+	InitCmd.Flags().BoolP("yes", "y", false, "Do not ask questions, use the flags and defaults")
+	InitCmd.Flags().Bool("list-templates", false, "List the built-in templates")
+	InitCmd.Flags().Bool("no-hooks", false, "Do not run the template's hooks")
+	InitCmd.Flags().BoolP("force", "f", false, "Replace existing files instead of keeping or merging them")
 }
 
-// createProjectJson creates a project.json file
-func createProjectJson(name, author, pawnccPath string) error {
-	// Check if file already exists
-	if _, err := os.Stat("project.json"); !os.IsNotExist(err) {
-		return fmt.Errorf("project.json already exists")
+// addDefaults adds project.json, config.json and the main script when the
+// template does not provide them
+func addDefaults(files []scaffold.File, name, author, pawnccPath string) ([]scaffold.File, error) {
+	has := map[string]bool{}
+	for _, file := range files {
+		has[file.Path] = true
 	}
 
+	mainFile := path.Join("gamemodes", name+".pwn")
+	library := false
+	if has["project.json"] {
+		// Create the main script of a template's own project.json, if missing
+		var project Project
+		for _, file := range files {
+			if file.Path == "project.json" {
+				if err := json.Unmarshal(file.Data, &project); err != nil {
+					return nil, fmt.Errorf("template project.json: %w", err)
+				}
+			}
+		}
+		mainFile = path.Clean(filepath.ToSlash(project.MainFile))
+		if mainFile != "." && !scaffold.Inside(mainFile) {
+			return nil, fmt.Errorf("template project.json: main_file %s is outside of the project", project.MainFile)
+		}
+		library = project.Type == utils.ProjectTypeLibrary
+	} else {
+		data, err := projectJson(name, author, pawnccPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, scaffold.File{Path: "project.json", Data: data, Mode: 0644})
+	}
+
+	// Libraries are not run and need no server configuration
+	if !has["config.json"] && !library {
+		data, err := configJson(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, scaffold.File{Path: "config.json", Data: data, Mode: 0644})
+	}
+
+	if mainFile != "." && !has[mainFile] {
+		files = append(files, scaffold.File{Path: mainFile, Data: []byte(defaultScript), Mode: 0644})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// defaultScript is the main script created when a template has none
+const defaultScript = `#include <open.mp>
+
+main()
+{
+}
+`
+
+// projectJson returns the default project.json
+func projectJson(name, author, pawnccPath string) ([]byte, error) {
 	// Create project configuration
 	project := Project{
 		Name:       name,
 		Version:    "1.0.0",
-		MainFile:   filepath.Join("gamemodes", name+".pwn"),
-		OutputFile: filepath.Join("gamemodes", name+".amx"),
+		MainFile:   path.Join("gamemodes", name+".pwn"),
+		OutputFile: path.Join("gamemodes", name+".amx"),
 		Resources:  []string{},
 		Plugins:    []string{},
 		ServerCfg:  "config.json",
@@ -121,26 +376,11 @@ func createProjectJson(name, author, pawnccPath string) error {
 	}
 
 	// Convert to JSON
-	jsonData, err := json.MarshalIndent(project, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Write to file
-	if err := os.WriteFile("project.json", jsonData, 0644); err != nil {
-		return err
-	}
-
-	return nil
+	return json.MarshalIndent(project, "", "  ")
 }
 
-// createConfigJson creates a config.json file
-func createConfigJson(name string) error {
-	// Check if file already exists
-	if _, err := os.Stat("config.json"); !os.IsNotExist(err) {
-		return fmt.Errorf("config.json already exists")
-	}
-
+// configJson returns the default config.json
+func configJson(name string) ([]byte, error) {
 	// Create server configuration
 	server := Server{
 		Hostname:     name + " Server",
@@ -155,15 +395,5 @@ func createConfigJson(name string) error {
 	}
 
 	// Convert to JSON
-	jsonData, err := json.MarshalIndent(server, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Write to file
-	if err := os.WriteFile("config.json", jsonData, 0644); err != nil {
-		return err
-	}
-
-	return nil
+	return json.MarshalIndent(server, "", "  ")
 }
@@ -0,0 +1,28 @@
+package init
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/scaffold"
+)
+
+func TestAddDefaultsRefusesOutsideMainFile(t *testing.T) {
+	files := []scaffold.File{{Path: "project.json", Data: []byte(`{"name": "gm", "main_file": "../../x.pwn"}`)}}
+	if _, err := addDefaults(files, "gm", "", "qawno"); err == nil || !strings.Contains(err.Error(), "outside of the project") {
+		t.Fatalf("addDefaults error = %v", err)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"gm", "my-gamemode", "gm.v2"} {
+		if err := checkName(name); err != nil {
+			t.Errorf("checkName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../../x", `a\b`, "a/b"} {
+		if err := checkName(name); err == nil {
+			t.Errorf("checkName(%q) succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package init
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/scaffold"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// Defaults shown by the wizard for settings templates provide
+const (
+	defaultPort       = 7777
+	defaultMaxPlayers = 50
+)
+
+// pluginName matches a plugin given by name or path
+var pluginName = regexp.MustCompile(`^[A-Za-z0-9_.\-/\\]+$`)
+
+// answers are the settings a project is created with. Zero values of
+// Port and MaxPlayers keep what the template has.
+type answers struct {
+	Name       string
+	Author     string
+	Template   string
+	PawnccPath string
+	Port       int
+	MaxPlayers int
+	Plugins    []string
+	Git        bool
+}
+
+// interactive reports whether init should ask its questions: it runs in a
+// terminal and was given none of its own flags
+func interactive(cmd *cobra.Command) bool {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return false
+	}
+
+	given := cmd.Flags().NFlag()
+	if cmd.Flags().Changed("project-dir") {
+		given--
+	}
+	return given == 0
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// wizard asks for the settings of a new project, starting from a, and
+// returns false when the user does not confirm them
+func wizard(in io.Reader, out io.Writer, dir string, a *answers) (bool, error) {
+	p := &prompter{in: bufio.NewReader(in), out: out}
+
+	fmt.Fprintf(out, "Creating a new open.mp project in %s\n", dir)
+	fmt.Fprintln(out, "Press enter to accept the value in brackets.")
+	fmt.Fprintln(out)
+
+	var err error
+	if a.Name, err = p.ask("Project name", a.Name, checkName); err != nil {
+		return false, err
+	}
+
+	if a.Author, err = p.ask("Author", a.Author, nil); err != nil {
+		return false, err
+	}
+
+	if templates, err := scaffold.Builtins(); err == nil {
+		fmt.Fprintln(out, "Templates:")
+		for _, t := range templates {
+			fmt.Fprintf(out, "  %-12s %s\n", t.Name, t.Manifest.Description)
+		}
+	}
+	if a.Template, err = p.ask("Template (or a directory or git URL)", a.Template, func(s string) error {
+		t, err := scaffold.Load(s)
+		if err != nil {
+			return err
+		}
+		t.Close()
+		return nil
+	}); err != nil {
+		return false, err
+	}
+
+	if a.PawnccPath, err = p.ask("Compiler location (pawncc_path)", a.PawnccPath, nil); err != nil {
+		return false, err
+	}
+
+	if a.Port, err = p.askInt("Server port", a.Port, defaultPort, 1, 65535); err != nil {
+		return false, err
+	}
+	if a.MaxPlayers, err = p.askInt("Max players", a.MaxPlayers, defaultMaxPlayers, 1, 1000); err != nil {
+		return false, err
+	}
+
+	plugins, err := p.ask("Plugins to include, separated by commas", strings.Join(a.Plugins, ", "), func(s string) error {
+		for _, plugin := range splitList(s) {
+			if !pluginName.MatchString(plugin) {
+				return fmt.Errorf("%q is not a plugin name or path", plugin)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	a.Plugins = splitList(plugins)
+
+	if a.Git, err = p.confirm("Create a git repository?", a.Git); err != nil {
+		return false, err
+	}
+
+	// Show what will be created before writing anything
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Summary:")
+	fmt.Fprintf(out, "  Name:        %s\n", a.Name)
+	fmt.Fprintf(out, "  Author:      %s\n", a.Author)
+	fmt.Fprintf(out, "  Template:    %s\n", a.Template)
+	fmt.Fprintf(out, "  pawncc_path: %s\n", a.PawnccPath)
+	fmt.Fprintf(out, "  Port:        %s\n", settingString(a.Port, defaultPort))
+	fmt.Fprintf(out, "  Max players: %s\n", settingString(a.MaxPlayers, defaultMaxPlayers))
+	if len(a.Plugins) > 0 {
+		fmt.Fprintf(out, "  Plugins:     %s\n", strings.Join(a.Plugins, ", "))
+	} else {
+		fmt.Fprintln(out, "  Plugins:     none")
+	}
+	fmt.Fprintf(out, "  Git:         %t\n", a.Git)
+	fmt.Fprintln(out)
+
+	return p.confirm("Create the project?", true)
+}
+
+// applySettings writes the port, max players and plugins into the
+// rendered config.json and project.json
+func applySettings(files []scaffold.File, a *answers) error {
+	// The plugins must be valid entries of project.json
+	for _, name := range a.Plugins {
+		data, _ := json.Marshal(name)
+		var plugin utils.Plugin
+		if err := json.Unmarshal(data, &plugin); err != nil {
+			return err
+		}
+		if err := plugin.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for i, file := range files {
+		var settings map[string]any
+		switch file.Path {
+		case "config.json":
+			settings = map[string]any{}
+			if a.Port != 0 {
+				settings["port"] = a.Port
+			}
+			if a.MaxPlayers != 0 {
+				settings["maxplayers"] = a.MaxPlayers
+			}
+		case "project.json":
+			if len(a.Plugins) > 0 {
+				settings = map[string]any{"plugins": a.Plugins}
+			}
+		}
+		if len(settings) == 0 {
+			continue
+		}
+
+		doc, err := jsonedit.Parse(file.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+		for _, key := range []string{"port", "maxplayers", "plugins"} {
+			value, ok := settings[key]
+			if !ok {
+				continue
+			}
+			v, err := jsonedit.FromGo(value)
+			if err != nil {
+				return err
+			}
+			if err := doc.Set(jsonedit.Path{{Key: key}}, v); err != nil {
+				return fmt.Errorf("%s: %w", file.Path, err)
+			}
+		}
+		files[i].Data = doc.Bytes()
+	}
+
+	return nil
+}
+
+// initGit creates a git repository in dir unless it is already in one
+func initGit(dir string) error {
+	check := exec.Command("git", "rev-parse", "--git-dir")
+	check.Dir = dir
+	if check.Run() == nil {
+		fmt.Println("Already inside a git repository, not creating one.")
+		return nil
+	}
+
+	cmd := exec.Command("git", "init", "--quiet")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git init failed: %s", strings.TrimSpace(string(out)))
+	}
+	fmt.Println("Created a git repository.")
+	return nil
+}
+
+// prompter asks questions on a terminal
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask asks a question until the answer passes validate; an empty answer
+// picks def
+func (p *prompter) ask(question, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(p.out)
+			return "", fmt.Errorf("no answer given")
+		}
+
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(p.out, "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// askInt asks for a number between min and max. value is the current
+// setting, zero when the template's is kept, which shows as def.
+func (p *prompter) askInt(question string, value, def, min, max int) (int, error) {
+	shown := value
+	if shown == 0 {
+		shown = def
+	}
+
+	answer, err := p.ask(question, strconv.Itoa(shown), func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return fmt.Errorf("enter a number between %d and %d", min, max)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n, _ := strconv.Atoi(answer)
+	if value == 0 && n == def {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// confirm asks a yes or no question
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+
+	answer, err := p.ask(question+" ("+hint+")", "", func(s string) error {
+		switch strings.ToLower(s) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("answer yes or no")
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return def, nil
+}
+
+// settingString shows a setting, where zero means the template's value
+func settingString(value, def int) string {
+	if value == 0 {
+		return strconv.Itoa(def)
+	}
+	return strconv.Itoa(value)
+}
+
+// splitList splits a comma-separated answer
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkName checks a project name, which also names the main script in
+// gamemodes/
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("the name must not be empty")
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("the name %q must not be a path", name)
+	}
+	return nil
+}
+
+// mergeLists returns the items of a followed by those of b that a lacks
+func mergeLists(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, item := range b {
+		if !slices.Contains(merged, item) {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
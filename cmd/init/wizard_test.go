@@ -0,0 +1,13 @@
+package init
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeLists(t *testing.T) {
+	got := mergeLists([]string{"crashdetect", "streamer"}, []string{"mysql", "streamer"})
+	if want := []string{"crashdetect", "streamer", "mysql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeLists() = %v, want %v", got, want)
+	}
+}
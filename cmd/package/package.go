@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/builder"
+	"github.com/weltschmerzie/omp-cli/internal/packager"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// PackageCmd represents the package command
+var PackageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Package the built project for deployment",
+	Long: `Package command builds the project and archives the result as
+dist/<name>-<version>-<os>.tar.gz (or .zip for Windows servers).
+
+The archive holds the compiled scripts, the staged plugins, components and
+resources, the server configuration and ompcli-package.json, which lists
+every file with its SHA-256 along with the build profile, target OS and
+git commit.
+
+The build uses the "production" profile: config.production.json is
+packaged as config.json when it exists. Use --profile to pick another
+config.<profile>.json, or --no-build to package the last build as it is;
+the package then records the profile, target OS and compiler version that
+build was made with.
+
+The package is built in build/ like any other build, so it replaces a
+development build there; run ompcli build afterwards to get that back.
+
+Packages are reproducible: files are stored in a fixed order and all carry
+the timestamp of SOURCE_DATE_EPOCH, else of the current git commit.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		profile, _ := cmd.Flags().GetString("profile")
+		targetOS, _ := cmd.Flags().GetString("target-os")
+		noBuild, _ := cmd.Flags().GetBool("no-build")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		switch targetOS {
+		case "", "linux", "windows":
+		default:
+			fmt.Printf("Error packaging project: unsupported target OS %q (use linux or windows)\n", targetOS)
+			return
+		}
+		if noBuild && (cmd.Flags().Changed("profile") || cmd.Flags().Changed("target-os")) {
+			fmt.Println("Error packaging project: --no-build packages the last build as it was made; --profile and --target-os cannot be used with it")
+			return
+		}
+		if output != "" {
+			if abs, err := filepath.Abs(output); err == nil {
+				output = abs
+			}
+		}
+
+		// Locate the workspace and the projects to package
+		ws, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
+			fmt.Printf("Error packaging project: %v\n", err)
+			return
+		}
+
+		members, err = ws.BuildOrder(members)
+		if err != nil {
+			fmt.Printf("Error packaging project: %v\n", err)
+			return
+		}
+
+		packaged := 0
+		for _, m := range members {
+			buildOpts := builder.Options{
+				PawnccPath:    ws.PawnccPath(),
+				PawnccVersion: ws.Config.PawnccVersion,
+				TargetOS:      targetOS,
+				Profile:       profile,
+			}
+
+			// Build with the profile first; libraries are built for the
+			// members that include them but not packaged
+			if !noBuild {
+				if len(members) > 1 {
+					fmt.Printf("==> Building %s\n", m.Name)
+				}
+				includes, err := ws.IncludePaths(m)
+				if err != nil {
+					fmt.Printf("Error building %s: %v\n", m.Name, err)
+					return
+				}
+				buildOpts.IncludePaths = includes
+				if notice := packager.BuildNotice(m.Root, profile, targetOS); notice != "" {
+					fmt.Println(notice)
+				}
+				if _, err := builder.Build(cmd.Context(), m.Root, buildOpts); err != nil {
+					fmt.Printf("Error building project: %v\n", err)
+					return
+				}
+			}
+			if m.Config.ProjectType() == utils.ProjectTypeLibrary {
+				continue
+			}
+
+			path, err := packager.Package(m.Root, packager.Options{
+				Format:    format,
+				OutputDir: output,
+			})
+			if err != nil {
+				fmt.Printf("Error packaging %s: %v\n", m.Name, err)
+				return
+			}
+			fmt.Printf("Packaged %s\n", path)
+			packaged++
+		}
+
+		if packaged == 0 {
+			fmt.Println("Nothing to package.")
+		}
+	},
+}
+
+func init() {
+	// Add flags
+	PackageCmd.Flags().String("format", "", "Archive format: tar.gz or zip (default: zip for Windows, else tar.gz)")
+	PackageCmd.Flags().StringP("output", "o", "", "Directory to write the package to (default: dist/)")
+	PackageCmd.Flags().String("profile", packager.DefaultProfile, "Build profile; packages config.<profile>.json when it exists")
+	PackageCmd.Flags().String("target-os", "", "Package plugins for this OS (linux or windows, default: this system)")
+	PackageCmd.Flags().Bool("no-build", false, "Package the last build without building first")
+	PackageCmd.Flags().StringP("member", "m", "", "Package only this workspace member")
+}
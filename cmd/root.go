@@ -2,9 +2,21 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	amxCmd "github.com/weltschmerzie/omp-cli/cmd/amx"
 	buildCmd "github.com/weltschmerzie/omp-cli/cmd/build"
+	cleanCmd "github.com/weltschmerzie/omp-cli/cmd/clean"
+	configCmd "github.com/weltschmerzie/omp-cli/cmd/config"
+	containerCmd "github.com/weltschmerzie/omp-cli/cmd/container"
+	deployCmd "github.com/weltschmerzie/omp-cli/cmd/deploy"
+	depsCmd "github.com/weltschmerzie/omp-cli/cmd/deps"
+	doctorCmd "github.com/weltschmerzie/omp-cli/cmd/doctor"
 	initCmd "github.com/weltschmerzie/omp-cli/cmd/init"
+	packageCmd "github.com/weltschmerzie/omp-cli/cmd/package"
 	runCmd "github.com/weltschmerzie/omp-cli/cmd/run"
+	serverCmd "github.com/weltschmerzie/omp-cli/cmd/server"
+	symbolicateCmd "github.com/weltschmerzie/omp-cli/cmd/symbolicate"
+	toolchainCmd "github.com/weltschmerzie/omp-cli/cmd/toolchain"
+	validateCmd "github.com/weltschmerzie/omp-cli/cmd/validate"
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -15,9 +27,26 @@ var RootCmd = &cobra.Command{
 It allows you to build and run open.mp projects easily.
 	
 For example:
-  ompcli init  - Initialize a new open.mp project
-  ompcli build - Builds/compiles the open.mp project
-  ompcli run   - Runs the open.mp project`,
+  ompcli init      - Initialize a new open.mp project
+  ompcli build     - Builds/compiles the open.mp project
+  ompcli run       - Runs the open.mp project
+  ompcli clean     - Removes build output
+  ompcli package   - Packages the built project for deployment
+  ompcli deploy    - Deploys the project to a target
+  ompcli rollback  - Switches a target back to an earlier release
+  ompcli container - Generates a container image build context
+  ompcli config    - Reads and edits project.json and config.json
+  ompcli validate  - Checks the project configuration
+  ompcli install   - Installs the project's dependencies
+  ompcli add       - Adds dependencies to the project
+  ompcli remove    - Removes dependencies from the project
+  ompcli update    - Updates dependencies to the newest allowed versions
+  ompcli vendor    - Copies all dependencies into the project
+  ompcli toolchain - Manages pawncc compiler versions
+  ompcli server    - Manages open.mp server versions
+  ompcli doctor    - Diagnoses problems with the environment and the project
+  ompcli amx       - Inspects compiled .amx files
+  ompcli symbolicate - Maps crash backtraces in a server log to source lines`,
 	DisableFlagParsing:         false,
 	DisableAutoGenTag:          true,
 	DisableFlagsInUseLine:      false,
@@ -31,8 +60,28 @@ For example:
 }
 
 func init() {
+	// Add global flags
+	RootCmd.PersistentFlags().StringP("project-dir", "C", "", "Run as if ompcli was started in this directory")
+
 	// Add subcommands
 	RootCmd.AddCommand(initCmd.InitCmd)
 	RootCmd.AddCommand(buildCmd.BuildCmd)
 	RootCmd.AddCommand(runCmd.RunCmd)
+	RootCmd.AddCommand(cleanCmd.CleanCmd)
+	RootCmd.AddCommand(packageCmd.PackageCmd)
+	RootCmd.AddCommand(deployCmd.DeployCmd)
+	RootCmd.AddCommand(deployCmd.RollbackCmd)
+	RootCmd.AddCommand(containerCmd.ContainerCmd)
+	RootCmd.AddCommand(configCmd.ConfigCmd)
+	RootCmd.AddCommand(validateCmd.ValidateCmd)
+	RootCmd.AddCommand(depsCmd.InstallCmd)
+	RootCmd.AddCommand(depsCmd.AddCmd)
+	RootCmd.AddCommand(depsCmd.RemoveCmd)
+	RootCmd.AddCommand(depsCmd.UpdateCmd)
+	RootCmd.AddCommand(depsCmd.VendorCmd)
+	RootCmd.AddCommand(toolchainCmd.ToolchainCmd)
+	RootCmd.AddCommand(serverCmd.ServerCmd)
+	RootCmd.AddCommand(doctorCmd.DoctorCmd)
+	RootCmd.AddCommand(amxCmd.AmxCmd)
+	RootCmd.AddCommand(symbolicateCmd.SymbolicateCmd)
 }
@@ -2,9 +2,13 @@ package run
 
 import (
 	"fmt"
+	"os"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/weltschmerzie/omp-cli/internal/runner"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
 )
 
 // RunCmd represents the run command
@@ -12,8 +16,18 @@ var RunCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run the open.mp project",
 	Long: `Run command executes the open.mp project.
-It will look for the compiled project in the current directory
-and run it according to open.mp specifications using config.json.`,
+It will look for the compiled project in the current directory or the
+nearest parent directory containing project.json, and run it according
+to open.mp specifications using config.json.
+
+Inside a workspace (workspace.json), running from the workspace root
+starts a server for every gamemode member side by side; use --member
+to pick one.
+
+When the compiled gamemode has debug information (compiled with -d2 or
+-d3), the AMX backtraces crashdetect prints on a crash or run time error
+show the function, source file and line of every frame. Use
+--no-symbolicate to see them as the server prints them.`,
 	DisableFlagParsing:    false,
 	DisableAutoGenTag:     true,
 	DisableFlagsInUseLine: false,
@@ -22,14 +36,70 @@ and run it according to open.mp specifications using config.json.`,
 		// Get flags
 		debug, _ := cmd.Flags().GetBool("debug")
 		port, _ := cmd.Flags().GetInt("port")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		noSymbolicate, _ := cmd.Flags().GetBool("no-symbolicate")
 
-		// Execute run
-		if err := runner.Run(debug, port); err != nil {
+		// Only override the configured port when asked to
+		if !cmd.Flags().Changed("port") {
+			port = 0
+		}
+
+		// Locate the workspace and the projects to run
+		_, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
 			fmt.Printf("Error running project: %v\n", err)
 			return
 		}
 
-		fmt.Println("Project is running. Press Ctrl+C to stop.")
+		// Only gamemodes can be started as a server
+		var servers []*workspace.Member
+		for _, m := range members {
+			if m.Config.ProjectType() == utils.ProjectTypeGamemode {
+				servers = append(servers, m)
+			}
+		}
+		if len(servers) == 0 {
+			fmt.Println("Error running project: no gamemode project to run")
+			return
+		}
+
+		// Execute run
+		if len(servers) == 1 {
+			opts := runner.Options{Debug: debug, Port: port, Symbolicate: !noSymbolicate}
+			if _, err := runner.Run(cmd.Context(), servers[0].Root, opts); err != nil {
+				fmt.Printf("Error running project: %v\n", err)
+				return
+			}
+			fmt.Println("Project is running. Press Ctrl+C to stop.")
+			return
+		}
+
+		if port != 0 {
+			fmt.Println("Error running project: --port can only be used with a single server (use --member)")
+			return
+		}
+
+		// Run every server side by side with prefixed output
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, m := range servers {
+			wg.Add(1)
+			go func(m *workspace.Member) {
+				defer wg.Done()
+				prefix := "[" + m.Name + "] "
+				opts := runner.Options{
+					Debug:       debug,
+					Stdout:      runner.NewPrefixWriter(os.Stdout, prefix, &mu),
+					Stderr:      runner.NewPrefixWriter(os.Stderr, prefix, &mu),
+					Symbolicate: !noSymbolicate,
+				}
+				if _, err := runner.Run(cmd.Context(), m.Root, opts); err != nil {
+					fmt.Fprintf(opts.Stderr, "Error running project: %v\n", err)
+				}
+			}(m)
+		}
+		wg.Wait()
 	},
 }
 
@@ -37,4 +107,6 @@ func init() {
 	// Add flags
 	RunCmd.Flags().BoolP("debug", "d", false, "Enable debug mode")
 	RunCmd.Flags().IntP("port", "p", 7777, "Port to run the server on")
+	RunCmd.Flags().StringP("member", "m", "", "Run only this workspace member")
+	RunCmd.Flags().Bool("no-symbolicate", false, "Do not map crash backtraces to source lines")
 }
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/configedit"
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/server"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// ServerCmd represents the server command
+var ServerCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Manage open.mp server versions",
+	Long: `Server command installs open.mp server releases into a shared cache and
+pins the version a project runs on.
+
+When project.json sets "server_version", ompcli build stages that server's
+executable, its components/ and its bundled includes into build/, and
+compiles against those includes. Servers live in the servers folder of
+the ompcli cache (OMPCLI_CACHE).`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+}
+
+// InstallCmd represents the server install command
+var InstallCmd = &cobra.Command{
+	Use:   "install <version>",
+	Short: "Install an open.mp server version",
+	Long: `Install command unpacks an open.mp server release into the shared cache.
+The release is read from the archive given with --from, or fetched from
+the "server_mirror" of the user configuration: a directory, file:// or
+http(s):// URL laid out like the upstream release downloads
+(v<version>/open.mp-linux-x86.tar.gz, v<version>/open.mp-win-x86.zip).`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		from, _ := cmd.Flags().GetString("from")
+
+		s, err := server.Install(args[0], from)
+		if err != nil {
+			fmt.Printf("Error installing server: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Installed open.mp server %s to %s\n", s.Version, s.Dir)
+	},
+}
+
+// ListCmd represents the server list command
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed open.mp server versions",
+	Long: `List command prints the installed open.mp server versions, marking the
+one the current project pins with an asterisk.`,
+	Args:                  cobra.NoArgs,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		servers, err := server.List()
+		if err != nil {
+			fmt.Printf("Error listing servers: %v\n", err)
+			return
+		}
+		if len(servers) == 0 {
+			fmt.Println("No servers installed.")
+			return
+		}
+
+		// The pinned version of the current project, if any
+		pinned := ""
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		if root, err := utils.FindProjectRoot(projectDir); err == nil {
+			if config, err := utils.GetProjectConfig(root); err == nil {
+				pinned = config.ServerVersion
+			}
+		}
+
+		for _, s := range servers {
+			marker := " "
+			if pinned != "" {
+				if p, err := server.Find(pinned); err == nil && p.Version == s.Version {
+					marker = "*"
+				}
+			}
+			fmt.Printf("%s %-14s %s\n", marker, s.Version, s.Dir)
+		}
+	},
+}
+
+// UseCmd represents the server use command
+var UseCmd = &cobra.Command{
+	Use:                   "use <version>",
+	Short:                 "Pin the open.mp server version of the project",
+	Long:                  `Use command sets "server_version" in project.json.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		version := args[0]
+
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+		root, err := utils.FindProjectRoot(projectDir)
+		if err != nil {
+			fmt.Printf("Error pinning server: %v\n", err)
+			return
+		}
+
+		file, err := configedit.Open(root, configedit.TargetProject)
+		if err != nil {
+			fmt.Printf("Error pinning server: %v\n", err)
+			return
+		}
+		if err := file.Set(jsonedit.Path{{Key: "server_version"}}, version); err != nil {
+			fmt.Printf("Error pinning server: %v\n", err)
+			return
+		}
+		if err := file.Save(); err != nil {
+			fmt.Printf("Error pinning server: %v\n", err)
+			return
+		}
+		for _, warning := range file.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+
+		fmt.Printf("Using open.mp server %s\n", version)
+		if _, err := server.Find(version); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	// Add subcommands
+	ServerCmd.AddCommand(InstallCmd)
+	ServerCmd.AddCommand(ListCmd)
+	ServerCmd.AddCommand(UseCmd)
+
+	// Add flags
+	InstallCmd.Flags().String("from", "", "Install from this release archive instead of the mirror")
+}
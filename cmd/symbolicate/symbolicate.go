@@ -0,0 +1,110 @@
+package symbolicate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/symbolicate"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// SymbolicateCmd represents the symbolicate command
+var SymbolicateCmd = &cobra.Command{
+	Use:   "symbolicate [log]",
+	Short: "Map crash backtraces in a server log to source lines",
+	Long: `Symbolicate command reads a server log and prints it with the AMX
+backtraces crashdetect writes on a crash or run time error mapped back to
+the source: every frame of the gamemode gets its function, source file and
+line. Frames given as a code address and as a function and offset are both
+understood. Without a log file, the log is read from standard input.
+
+The addresses are looked up in the debug information of the project's
+compiled gamemode, build/<output_file>, which must be compiled with -d2 or
+-d3 and be the build that wrote the log. Use --amx to use another .amx
+file.
+
+ompcli run does the same for the output of the server as it runs.`,
+	Args:                  cobra.MaximumNArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		amxPath, _ := cmd.Flags().GetString("amx")
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		// Find the compiled gamemode of the project
+		if amxPath == "" {
+			path, err := gamemode(projectDir, member)
+			if err != nil {
+				fmt.Printf("Error symbolicating log: %v\n", err)
+				return
+			}
+			amxPath = path
+		}
+
+		s, err := symbolicate.Open(amxPath)
+		if err != nil {
+			fmt.Printf("Error symbolicating log: %v\n", err)
+			return
+		}
+
+		// Read the log from the file or standard input
+		var log io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				fmt.Printf("Error symbolicating log: %v\n", err)
+				return
+			}
+			defer file.Close()
+			log = file
+		}
+
+		if err := s.Copy(os.Stdout, log); err != nil {
+			fmt.Printf("Error symbolicating log: %v\n", err)
+			return
+		}
+	},
+}
+
+// gamemode returns the compiled gamemode of the project in dir
+func gamemode(dir, member string) (string, error) {
+	_, members, err := workspace.Resolve(dir, member)
+	if err != nil {
+		return "", err
+	}
+
+	var gamemodes []*workspace.Member
+	for _, m := range members {
+		if m.Config.ProjectType() == utils.ProjectTypeGamemode {
+			gamemodes = append(gamemodes, m)
+		}
+	}
+	switch len(gamemodes) {
+	case 0:
+		return "", fmt.Errorf("no gamemode project found; use --amx to name the compiled script")
+	case 1:
+	default:
+		return "", fmt.Errorf("the workspace has several gamemodes; use --member or --amx")
+	}
+
+	m := gamemodes[0]
+	path := filepath.Join(m.Root, "build", m.Config.OutputFile)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("compiled gamemode not found at %s. Please run 'ompcli build' first", path)
+	}
+	return path, nil
+}
+
+func init() {
+	// Add flags
+	SymbolicateCmd.Flags().String("amx", "", "Compiled script to take the debug information from (default: the project's build)")
+	SymbolicateCmd.Flags().StringP("member", "m", "", "Use the gamemode of this workspace member")
+}
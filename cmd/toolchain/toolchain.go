@@ -0,0 +1,170 @@
+package toolchain
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/configedit"
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/toolchain"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// ToolchainCmd represents the toolchain command
+var ToolchainCmd = &cobra.Command{
+	Use:   "toolchain",
+	Short: "Manage pawncc compiler versions",
+	Long: `Toolchain command installs pawncc compilers into a user-level toolchain
+directory and pins the version a project is built with.
+
+When project.json sets "pawncc_version", ompcli build uses that installed
+compiler (or the one in pawncc_path) and fails if the compiler reports a
+different version. Toolchains live in the "toolchain_dir" of the user
+configuration, by default in the toolchains folder of the ompcli cache
+(OMPCLI_CACHE).`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+}
+
+// InstallCmd represents the toolchain install command
+var InstallCmd = &cobra.Command{
+	Use:   "install <version>",
+	Short: "Install a pawncc version",
+	Long: `Install command unpacks a pawncc release into the toolchain directory.
+The release is read from the archive given with --from, or fetched from
+the "toolchain_mirror" of the user configuration: a directory, file://
+or http(s):// URL holding the upstream release archives
+(pawnc-<version>-linux.tar.gz, pawnc-<version>-windows.zip, ...).`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		from, _ := cmd.Flags().GetString("from")
+
+		t, err := toolchain.Install(args[0], from)
+		if err != nil {
+			fmt.Printf("Error installing toolchain: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Installed pawncc %s to %s\n", t.Version, t.Dir)
+	},
+}
+
+// ListCmd represents the toolchain list command
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed pawncc versions",
+	Long: `List command prints the installed pawncc versions, marking the one the
+current project (or the user default) selects with an asterisk.`,
+	Args:                  cobra.NoArgs,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		toolchains, err := toolchain.List()
+		if err != nil {
+			fmt.Printf("Error listing toolchains: %v\n", err)
+			return
+		}
+		if len(toolchains) == 0 {
+			fmt.Println("No toolchains installed.")
+			return
+		}
+
+		active := activeVersion(cmd)
+		for _, t := range toolchains {
+			marker := " "
+			if active != "" && toolchain.Matches(active, t.Version) {
+				marker = "*"
+			}
+			fmt.Printf("%s %-10s %s\n", marker, t.Version, t.Dir)
+		}
+	},
+}
+
+// UseCmd represents the toolchain use command
+var UseCmd = &cobra.Command{
+	Use:   "use <version>",
+	Short: "Pin the pawncc version of the project",
+	Long: `Use command sets "pawncc_version" in project.json. With --global it sets
+the "default_toolchain" of the user configuration instead, which applies
+to projects that neither pin a version nor set a "pawncc_path".`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		global, _ := cmd.Flags().GetBool("global")
+		version := args[0]
+
+		// Pick the file to edit
+		root, target, key := "", configedit.TargetUser, "default_toolchain"
+		if !global {
+			projectDir, _ := cmd.Flags().GetString("project-dir")
+			var err error
+			if root, err = utils.FindProjectRoot(projectDir); err != nil {
+				fmt.Printf("Error pinning toolchain: %v\n", err)
+				return
+			}
+			target, key = configedit.TargetProject, "pawncc_version"
+		}
+
+		file, err := configedit.Open(root, target)
+		if err != nil {
+			fmt.Printf("Error pinning toolchain: %v\n", err)
+			return
+		}
+		if err := file.Set(jsonedit.Path{{Key: key}}, version); err != nil {
+			fmt.Printf("Error pinning toolchain: %v\n", err)
+			return
+		}
+		if err := file.Save(); err != nil {
+			fmt.Printf("Error pinning toolchain: %v\n", err)
+			return
+		}
+		for _, warning := range file.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+
+		fmt.Printf("Using pawncc %s\n", version)
+		if _, err := toolchain.Find(version); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	// Add subcommands
+	ToolchainCmd.AddCommand(InstallCmd)
+	ToolchainCmd.AddCommand(ListCmd)
+	ToolchainCmd.AddCommand(UseCmd)
+
+	// Add flags
+	InstallCmd.Flags().String("from", "", "Install from this release archive instead of the mirror")
+	UseCmd.Flags().Bool("global", false, "Set the user default instead of the project's version")
+}
+
+// activeVersion returns the version the current project would be built with
+func activeVersion(cmd *cobra.Command) string {
+	projectDir, _ := cmd.Flags().GetString("project-dir")
+	if root, err := utils.FindProjectRoot(projectDir); err == nil {
+		if config, err := utils.GetProjectConfig(root); err == nil && config.PawnccVersion != "" {
+			return config.PawnccVersion
+		}
+	}
+
+	if config, err := userconfig.Load(); err == nil {
+		return config.DefaultToolchain
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+)
+
+// ValidateCmd represents the validate command
+var ValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the project configuration",
+	Long: `Validate command checks project.json and config.json for invalid
+values and makes sure the files they refer to exist.
+
+Inside a workspace (workspace.json), validating from the workspace root
+checks every member; use --member to pick one. The exit status is non-zero
+when a project is invalid.`,
+	DisableFlagParsing:    false,
+	DisableAutoGenTag:     true,
+	DisableFlagsInUseLine: false,
+	DisableSuggestions:    true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		member, _ := cmd.Flags().GetString("member")
+		projectDir, _ := cmd.Flags().GetString("project-dir")
+
+		// Locate the workspace and the projects to validate
+		ws, members, err := workspace.Resolve(projectDir, member)
+		if err != nil {
+			fmt.Printf("Error validating project: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed := 0
+		for _, m := range members {
+			problems := ws.Validate(m)
+			if len(problems) == 0 {
+				fmt.Printf("%s: OK\n", m.Name)
+				continue
+			}
+
+			failed++
+			fmt.Printf("%s: %d problem(s)\n", m.Name, len(problems))
+			for _, problem := range problems {
+				fmt.Printf("  - %v\n", problem)
+			}
+		}
+
+		if failed > 0 {
+			fmt.Printf("Validation failed for %d of %d project(s).\n", failed, len(members))
+			os.Exit(1)
+		}
+
+		fmt.Println("Project configuration is valid!")
+	},
+}
+
+func init() {
+	// Add flags
+	ValidateCmd.Flags().StringP("member", "m", "", "Validate only this workspace member")
+}
@@ -0,0 +1,270 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive reports whether path has an extension Extract understands
+func IsArchive(path string) bool {
+	return Format(path) != ""
+}
+
+// Format returns "zip" or "tar.gz" for supported archives, or ""
+func Format(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// Extract unpacks a .zip or .tar.gz archive into dest, keeping file modes.
+// Entries that would land outside dest are rejected.
+func Extract(path, dest string) error {
+	switch Format(path) {
+	case "zip":
+		return extractZip(path, dest)
+	case "tar.gz":
+		return extractTarGz(path, dest)
+	}
+	return fmt.Errorf("unsupported archive format: %s", filepath.Base(path))
+}
+
+// extractZip unpacks a zip archive
+func extractZip(path, dest string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		target, err := entryPath(dest, file.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkParents(dest, target, file.Name); err != nil {
+			return err
+		}
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, src, file.Mode().Perm())
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTarGz unpacks a gzip-compressed tar archive
+func extractTarGz(path, dest string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := entryPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkParents(dest, target, header.Name); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkLink(dest, header.Name, header.Linkname); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entryPath returns where an archive entry should be written, refusing
+// entries that would escape dest
+func entryPath(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q points outside the destination", name)
+	}
+	return target, nil
+}
+
+// maxLinks is how many symlinks checkLink follows before giving up
+const maxLinks = 255
+
+// checkParents refuses entries that would be written through a symlink:
+// an earlier entry may have been a link to anywhere, so every directory
+// between dest and target, and target itself, must be a real one
+func checkParents(dest, target, name string) error {
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == "." {
+		return nil
+	}
+
+	dir := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %q is written through a symlink", name)
+		}
+	}
+	return nil
+}
+
+// checkLink refuses a symlink entry whose target is absolute or resolves
+// outside dest, following the links already extracted on the way
+func checkLink(dest, name, linkname string) error {
+	escapes := fmt.Errorf("archive entry %q links outside the destination", name)
+	if filepath.IsAbs(linkname) || filepath.VolumeName(linkname) != "" || strings.HasPrefix(linkname, "/") {
+		return escapes
+	}
+
+	// Walk the target a component at a time from the link's directory,
+	// replacing links by what they point to. The path is not cleaned, as
+	// ".." after a link leaves the link's target.
+	var resolved []string
+	pending := append(splitPath(filepath.Dir(filepath.FromSlash(name))), splitPath(linkname)...)
+	for links := 0; len(pending) > 0; {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return escapes
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, part)
+		path := filepath.Join(append([]string{dest}, resolved...)...)
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if links++; links > maxLinks {
+			return fmt.Errorf("archive entry %q has too many levels of symlinks", name)
+		}
+		next, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(next) || filepath.VolumeName(next) != "" {
+			return escapes
+		}
+		resolved = resolved[:len(resolved)-1]
+		pending = append(splitPath(next), pending...)
+	}
+	return nil
+}
+
+// splitPath splits a relative path into its components
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
+}
+
+// writeFile writes the contents of r to path with the given permissions
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// StripSingleRoot moves the contents of dir up one level when dir holds
+// nothing but a single folder, as archives made from a release folder do
+func StripSingleRoot(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return nil
+	}
+
+	inner := filepath.Join(dir, entries[0].Name())
+	moved := dir + ".strip"
+	if err := os.Rename(inner, moved); err != nil {
+		return err
+	}
+	if err := os.Remove(dir); err != nil {
+		return err
+	}
+	return os.Rename(moved, dir)
+}
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// entry is a file, directory or symlink of a test archive
+type entry struct {
+	name string
+	link string
+	dir  bool
+}
+
+// testArchive writes a .tar.gz archive holding entries
+func testArchive(t *testing.T, entries []entry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len("data"))}
+		switch {
+		case e.dir:
+			header.Typeflag, header.Mode, header.Size = tar.TypeDir, 0755, 0
+		case e.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("data")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractRejectsEscapingSymlinks(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{
+			name:    "absolute target",
+			entries: []entry{{name: "link", link: outside}, {name: "link/pwned"}},
+		},
+		{
+			name:    "relative target",
+			entries: []entry{{name: "link", link: "../../../../../../../../" + outside}, {name: "link/pwned"}},
+		},
+		{
+			name: "chain of links",
+			entries: []entry{
+				{name: "d", dir: true},
+				{name: "l1", link: "d"},
+				{name: "d/l2", link: ".."},
+				{name: "z", link: "l1/l2/../.."},
+			},
+		},
+		{
+			name: "file through a link inside",
+			entries: []entry{
+				{name: "d", dir: true},
+				{name: "l", link: "d"},
+				{name: "l/pwned"},
+			},
+		},
+		{
+			name:    "entry path",
+			entries: []entry{{name: "../pwned"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := testArchive(t, tt.entries)
+			dest := filepath.Join(t.TempDir(), "dest")
+			if err := Extract(path, dest); err == nil {
+				t.Fatal("Extract succeeded, want an error")
+			}
+			if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+				t.Fatal("a file was written outside the destination")
+			}
+		})
+	}
+}
+
+func TestExtractKeepsLinksInside(t *testing.T) {
+	path := testArchive(t, []entry{
+		{name: "d", dir: true},
+		{name: "d/file"},
+		{name: "d/sub", dir: true},
+		{name: "d/sub/link", link: "../file"},
+		{name: "top", link: "d/sub/link"},
+	})
+	dest := t.TempDir()
+	if err := Extract(path, dest); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "top"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("top reads %q, want %q", data, "data")
+	}
+}
@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Entry is a file to put into an archive. Its contents come from Data
+// when set, otherwise from the file at Path.
+type Entry struct {
+	Name string
+	Path string
+	Data []byte
+	Mode os.FileMode
+}
+
+// Create writes entries into a new .zip or .tar.gz archive at path. The
+// output only depends on the entries and mtime: entries are sorted by
+// name, every timestamp is set to mtime and owners are left empty, so the
+// same inputs always produce the same archive.
+func Create(path string, entries []Entry, mtime time.Time) error {
+	sorted := append([]Entry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch Format(path) {
+	case "zip":
+		err = writeZip(file, sorted, mtime)
+	case "tar.gz":
+		err = writeTarGz(file, sorted, mtime)
+	default:
+		err = fmt.Errorf("unsupported archive format: %s", path)
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// writeZip writes entries as a zip archive
+func writeZip(w io.Writer, entries []Entry, mtime time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: mtime}
+		header.SetMode(normalizeMode(entry.Mode))
+
+		dst, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := copyEntry(dst, entry); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeTarGz writes entries as a gzip-compressed tar archive
+func writeTarGz(w io.Writer, entries []Entry, mtime time.Time) error {
+	gz := gzip.NewWriter(w)
+	gz.ModTime = mtime
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		size := int64(len(entry.Data))
+		if entry.Data == nil {
+			info, err := os.Stat(entry.Path)
+			if err != nil {
+				return err
+			}
+			size = info.Size()
+		}
+
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Size:     size,
+			Mode:     int64(normalizeMode(entry.Mode)),
+			ModTime:  mtime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := copyEntry(tw, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// copyEntry writes the contents of an entry to w
+func copyEntry(w io.Writer, entry Entry) error {
+	if entry.Data != nil {
+		_, err := w.Write(entry.Data)
+		return err
+	}
+
+	src, err := os.Open(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// normalizeMode reduces a mode to 0755 for executables and 0644 otherwise,
+// so that umasks do not change the archive
+func normalizeMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HTTPClient fetches remote mirrors and registries. Its timeout covers the
+// whole request, so that a stalled server fails the download instead of
+// hanging it.
+var HTTPClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Download copies the file name from a mirror into a temporary file in dir
+// and returns its path. The mirror may be a directory, a file:// URL or an
+// http(s):// URL; name is a slash-separated path below it. Remote mirrors
+// are refused when offline is set.
+func Download(mirror, name, dir string, offline bool) (string, error) {
+	src, err := openMirror(mirror, name, offline)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	// Keep the extension so the format can still be told from the name
+	file, err := os.CreateTemp(dir, ".download-*-"+path.Base(name))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, src); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// openMirror opens name below a mirror directory or URL
+func openMirror(mirror, name string, offline bool) (io.ReadCloser, error) {
+	u, err := url.Parse(mirror)
+	if err != nil || len(u.Scheme) <= 1 {
+		return os.Open(filepath.Join(mirror, filepath.FromSlash(name)))
+	}
+
+	switch u.Scheme {
+	case "file":
+		return os.Open(filepath.Join(filepath.FromSlash(u.Path), filepath.FromSlash(name)))
+	case "http", "https":
+		if offline {
+			return nil, fmt.Errorf("mirror %s is not available in offline mode", mirror)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mirror scheme %q", u.Scheme)
+	}
+
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	location := strings.TrimRight(mirror, "/") + "/" + strings.Join(segments, "/")
+
+	resp, err := HTTPClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -1,99 +1,342 @@
 package builder
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
-
+	"sync"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/deps"
+	"github.com/weltschmerzie/omp-cli/internal/natives"
+	"github.com/weltschmerzie/omp-cli/internal/server"
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/internal/toolchain"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
 	"github.com/weltschmerzie/omp-cli/pkg/utils"
 )
 
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
 // BuildResult represents the result of a build operation
 type BuildResult struct {
 	Success  bool
 	Errors   []string
 	Warnings []string
+
+	// Project is the name of the project that was built
+	Project string
+
+	// Output is the compiled script in the build directory; empty when
+	// nothing was compiled
+	Output string
+
+	// Compiler is the pawncc executable that was used
+	Compiler string
+
+	// Diagnostics are the errors and warnings pawncc reported, in order
+	Diagnostics []Diagnostic
+
+	// MissingNatives are the natives the script calls that neither
+	// open.mp nor a configured plugin provides
+	MissingNatives []string
+
+	// Skipped is set for libraries without a main file, which have
+	// nothing to compile
+	Skipped bool
+
+	// Staged counts what was staged into the build directory
+	Staged stage.Stats
+
+	// Duration is how long the build took
+	Duration time.Duration
+}
+
+// Diagnostic is an error or warning reported by pawncc. File, Line and
+// Code are zero when the line could not be parsed further.
+type Diagnostic struct {
+	Severity string
+	File     string
+	Line     int
+	EndLine  int
+	Code     int
+	Message  string
+
+	// Raw is the line as pawncc printed it
+	Raw string
+}
+
+// String returns the line pawncc printed, or formats d the way pawncc
+// does when it was not parsed from output
+func (d Diagnostic) String() string {
+	if d.Raw != "" {
+		return d.Raw
+	}
+	if d.File == "" {
+		return d.Message
+	}
+
+	lines := strconv.Itoa(d.Line)
+	if d.EndLine > d.Line {
+		lines += " -- " + strconv.Itoa(d.EndLine)
+	}
+	return fmt.Sprintf("%s(%s) : %s %03d: %s", d.File, lines, d.Severity, d.Code, d.Message)
+}
+
+// Event is something that happened during a build, passed to
+// Options.OnEvent. Events of one build are delivered one at a time, in
+// the order they happened.
+type Event interface {
+	buildEvent()
+}
+
+// TargetStarted is sent when the build of a project starts
+type TargetStarted struct {
+	Project string
+	Root    string
+}
+
+// CompilerInvoked is sent when the compiler starts on the main script
+type CompilerInvoked struct {
+	Project  string
+	Compiler string
+	Source   string
+	Output   string
+}
+
+// DiagnosticReported is sent for every error or warning as soon as the
+// compiler prints it
+type DiagnosticReported struct {
+	Project    string
+	Diagnostic Diagnostic
+}
+
+// FilesStaged is sent once the build directory is up to date
+type FilesStaged struct {
+	Project string
+	Stats   stage.Stats
+}
+
+// Notice is sent for a message the user should see, such as a file that
+// could not be staged
+type Notice struct {
+	Project string
+	Message string
+}
+
+// TargetFinished is sent when the build of a project ends. Err is set when
+// it failed.
+type TargetFinished struct {
+	Project string
+	Result  *BuildResult
+	Err     error
+}
+
+func (TargetStarted) buildEvent()      {}
+func (CompilerInvoked) buildEvent()    {}
+func (DiagnosticReported) buildEvent() {}
+func (FilesStaged) buildEvent()        {}
+func (Notice) buildEvent()             {}
+func (TargetFinished) buildEvent()     {}
+
+// Options controls how a project is built
+type Options struct {
+	// Verbose prints the compiler output as it is produced
+	Verbose bool
+
+	// IncludePaths are extra include directories passed to pawncc
+	IncludePaths []string
+
+	// PawnccPath overrides the compiler directory from project.json
+	PawnccPath string
+
+	// PawnccVersion overrides the compiler version pinned in project.json
+	PawnccVersion string
+
+	// TargetOS is the operating system the server runs on; plugins are
+	// staged for it. Empty means this system.
+	TargetOS string
+
+	// Profile selects the server configuration to stage, config.<profile>.json
+	// when present; empty stages config.json
+	Profile string
+
+	// Stdout and Stderr receive the build's messages and, when verbose,
+	// the compiler's output; nil means the process's own
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// OnEvent is called for every event of the build when set
+	OnEvent func(Event)
+
+	// Compiler compiles the main script; nil runs the pawncc found by
+	// FindPawncc
+	Compiler Compiler
+
+	// FS holds the project and its build directory; nil means the real
+	// file system. Projects with dependencies or a pinned server need the
+	// real one, as those come from the cache on disk.
+	FS vfs.FS
+}
+
+// stdout returns the writer for the build's messages
+func (o Options) stdout() io.Writer {
+	if o.Stdout == nil {
+		return os.Stdout
+	}
+	return o.Stdout
+}
+
+// stderr returns the writer for the compiler's error output
+func (o Options) stderr() io.Writer {
+	if o.Stderr == nil {
+		return os.Stderr
+	}
+	return o.Stderr
+}
+
+// emit passes an event to OnEvent
+func (o Options) emit(e Event) {
+	if o.OnEvent != nil {
+		o.OnEvent(e)
+	}
+}
+
+// Build compiles the open.mp project rooted at root. The result is returned
+// even when the build fails, as far as it got.
+func Build(ctx context.Context, root string, opts Options) (*BuildResult, error) {
+	start := time.Now()
+	result := &BuildResult{Project: filepath.Base(root), Errors: []string{}, Warnings: []string{}}
+	if config, err := utils.GetProjectConfigFS(vfs.Or(opts.FS), root); err == nil {
+		result.Project = config.Name
+	}
+	opts.emit(TargetStarted{Project: result.Project, Root: root})
+
+	err := build(ctx, root, opts, result)
+	result.Duration = time.Since(start)
+	result.Success = err == nil
+	opts.emit(TargetFinished{Project: result.Project, Result: result, Err: err})
+	return result, err
 }
 
-// Build compiles the open.mp project
-func Build(verbose bool) error {
-	// Check if we are in an open.mp project directory
-	if !utils.IsOpenMPProject() {
-		return errors.New("current directory is not an open.mp project")
+// build does the work of Build, filling in result as it goes
+func build(ctx context.Context, root string, opts Options, result *BuildResult) error {
+	verbose := opts.Verbose
+	out := opts.stdout()
+	fsys := vfs.Or(opts.FS)
+	targetOS := opts.TargetOS
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+
+	// Check if root is an open.mp project directory
+	if !utils.IsOpenMPProjectFS(fsys, root) {
+		return fmt.Errorf("%s is not an open.mp project", root)
 	}
 
 	// Get project configuration
-	config, err := utils.GetProjectConfig()
+	config, err := utils.GetProjectConfigFS(fsys, root)
 	if err != nil {
 		return fmt.Errorf("failed to get project configuration: %w", err)
 	}
 
 	// Get server configuration
-	serverConfig, err := utils.GetServerConfig()
+	serverConfig, err := utils.GetServerConfigFS(fsys, root)
 	if err != nil {
 		return fmt.Errorf("failed to get server configuration: %w", err)
 	}
 
 	if verbose {
-		fmt.Println("Building open.mp project...")
-		fmt.Printf("Project name: %s\n", config.Name)
-		fmt.Printf("Project version: %s\n", config.Version)
-		fmt.Printf("Server hostname: %s\n", serverConfig.Hostname)
-		fmt.Printf("Using pawncc from: %s\n", config.PawnccPath)
-		fmt.Printf("Main file: %s\n", config.MainFile)
-		fmt.Printf("Output file: %s\n", config.OutputFile)
+		fmt.Fprintln(out, "Building open.mp project...")
+		fmt.Fprintf(out, "Project root: %s\n", root)
+		fmt.Fprintf(out, "Project name: %s\n", config.Name)
+		fmt.Fprintf(out, "Project version: %s\n", config.Version)
+		fmt.Fprintf(out, "Server hostname: %s\n", serverConfig.Hostname)
+		if opts.Compiler == nil {
+			fmt.Fprintf(out, "Using pawncc from: %s\n", pawnccPath(config, opts))
+		}
+		if version := pawnccVersion(config, opts); version != "" {
+			fmt.Fprintf(out, "Pinned pawncc version: %s\n", version)
+		}
+		fmt.Fprintf(out, "Main file: %s\n", config.MainFile)
+		fmt.Fprintf(out, "Output file: %s\n", config.OutputFile)
+		fmt.Fprintf(out, "Target OS: %s\n", targetOS)
+		if opts.Profile != "" {
+			fmt.Fprintf(out, "Profile: %s\n", opts.Profile)
+		}
+	}
+
+	// Make sure declared dependencies are installed
+	if err := deps.Check(root, config); err != nil {
+		return err
+	}
+	depIncludes, err := deps.IncludePaths(root)
+	if err != nil {
+		return err
+	}
+	includePaths := append(append([]string{}, opts.IncludePaths...), depIncludes...)
+
+	// The pinned server provides the runtime and the includes matching it
+	var srv *server.Server
+	if config.ServerVersion != "" {
+		if srv, err = server.Find(config.ServerVersion); err != nil {
+			return err
+		}
+		if includeDir := srv.IncludeDir(); includeDir != "" {
+			includePaths = append(includePaths, includeDir)
+		}
+	}
+
+	if verbose {
+		for _, dir := range includePaths {
+			fmt.Fprintf(out, "Include path: %s\n", dir)
+		}
+	}
+
+	// Libraries without a main file only provide includes to other projects
+	if config.ProjectType() == utils.ProjectTypeLibrary && config.MainFile == "" {
+		fmt.Fprintf(out, "Nothing to compile for library %s.\n", config.Name)
+		result.Skipped = true
+		return nil
 	}
 
 	// Create build directory if it doesn't exist
-	buildDir := filepath.Join(".", "build")
-	if err := os.MkdirAll(buildDir, 0755); err != nil {
+	buildDir := filepath.Join(root, "build")
+	if err := fsys.MkdirAll(buildDir, 0755); err != nil {
 		return fmt.Errorf("failed to create build directory: %w", err)
 	}
 
 	// Create gamemodes directory in build directory
 	gamemodesDir := filepath.Join(buildDir, "gamemodes")
-	if err := os.MkdirAll(gamemodesDir, 0755); err != nil {
+	if err := fsys.MkdirAll(gamemodesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create gamemodes directory: %w", err)
 	}
 
 	// Determine pawncc executable path
-	var pawnccExe string
-	if config.PawnccPath != "" {
-		if runtime.GOOS == "windows" {
-			pawnccExe = filepath.Join(config.PawnccPath, "pawncc.exe")
-		} else {
-			pawnccExe = filepath.Join(config.PawnccPath, "pawncc")
-		}
-	} else {
-		// Fallback to just "pawncc" and rely on PATH
-		if runtime.GOOS == "windows" {
-			pawnccExe = "pawncc.exe"
-		} else {
-			pawnccExe = "pawncc"
+	compiler := opts.Compiler
+	if compiler == nil {
+		pawnccExe, err := FindPawncc(root, config, opts)
+		if err != nil {
+			return err
 		}
+		compiler = &ExecCompiler{Exe: pawnccExe}
 	}
-
-	// Check if pawncc exists
-	if _, err := os.Stat(pawnccExe); os.IsNotExist(err) && config.PawnccPath != "" {
-		// If not found at specified path, try to find in PATH
-		if verbose {
-			fmt.Printf("Warning: pawncc not found at %s, trying to find in PATH\n", pawnccExe)
-		}
-
-		// Fallback to just "pawncc" and rely on PATH
-		if runtime.GOOS == "windows" {
-			pawnccExe = "pawncc.exe"
-		} else {
-			pawnccExe = "pawncc"
-		}
+	result.Compiler = compiler.Path()
+	if verbose {
+		fmt.Fprintf(out, "Compiler: %s\n", compiler.Path())
 	}
 
 	// Determine output file path
@@ -101,107 +344,336 @@ func Build(verbose bool) error {
 	outputDir := filepath.Dir(outputPath)
 
 	// Make sure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := fsys.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create command with output file option
-	cmd := exec.Command(pawnccExe, "-o"+outputPath, config.MainFile)
-
-	// Create buffers to capture output
-	var stdout, stderr bytes.Buffer
+	// Parse errors and warnings as the compiler prints them. Its two
+	// streams are written concurrently.
+	var mu sync.Mutex
+	report := func(line string) {
+		d, ok := parseLine(line)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if d.Severity == SeverityError {
+			result.Errors = append(result.Errors, d.Raw)
+		} else {
+			result.Warnings = append(result.Warnings, d.Raw)
+		}
+		result.Diagnostics = append(result.Diagnostics, d)
+		opts.emit(DiagnosticReported{Project: result.Project, Diagnostic: d})
+	}
+	stdout, stderr := &lineWriter{fn: report}, &lineWriter{fn: report}
+	job := CompileJob{
+		Dir:          root,
+		Source:       config.MainFile,
+		Output:       outputPath,
+		IncludePaths: includePaths,
+		Stdout:       stdout,
+		Stderr:       stderr,
+	}
 
 	// Set up output streams
 	if verbose {
 		// For verbose mode, we want to see output in real-time and also capture it
-		cmd.Stdout = io.MultiWriter(os.Stdout, &stdout)
-		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
-	} else {
-		// For non-verbose mode, just capture the output
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+		job.Stdout = io.MultiWriter(out, stdout)
+		job.Stderr = io.MultiWriter(opts.stderr(), stderr)
 	}
 
 	// Execute the compiler
-	err = cmd.Run()
-
-	// Parse the output for errors and warnings
-	result := parseBuildOutput(stdout.String(), stderr.String())
+	opts.emit(CompilerInvoked{Project: result.Project, Compiler: compiler.Path(), Source: config.MainFile, Output: outputPath})
+	err = compiler.Compile(ctx, job)
+	stdout.Flush()
+	stderr.Flush()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	// Display the result
 	if len(result.Errors) > 0 {
-		fmt.Printf("\nBuild failed with %d errors and %d warnings.\n", len(result.Errors), len(result.Warnings))
+		fmt.Fprintf(out, "\nBuild failed with %d errors and %d warnings.\n", len(result.Errors), len(result.Warnings))
 
 		if !verbose {
 			// Only show errors and warnings if not in verbose mode (to avoid duplication)
-			fmt.Println("\nErrors:")
+			fmt.Fprintln(out, "\nErrors:")
 			for _, errMsg := range result.Errors {
-				fmt.Printf("  - %s\n", errMsg)
+				fmt.Fprintf(out, "  - %s\n", errMsg)
 			}
 
 			if len(result.Warnings) > 0 {
-				fmt.Println("\nWarnings:")
+				fmt.Fprintln(out, "\nWarnings:")
 				for _, warnMsg := range result.Warnings {
-					fmt.Printf("  - %s\n", warnMsg)
+					fmt.Fprintf(out, "  - %s\n", warnMsg)
 				}
 			}
 		}
 
 		return fmt.Errorf("compilation failed with %d errors", len(result.Errors))
 	} else if len(result.Warnings) > 0 {
-		fmt.Printf("\nBuild completed with %d warnings.\n", len(result.Warnings))
+		fmt.Fprintf(out, "\nBuild completed with %d warnings.\n", len(result.Warnings))
 
 		if !verbose {
 			// Only show warnings if not in verbose mode (to avoid duplication)
-			fmt.Println("\nWarnings:")
+			fmt.Fprintln(out, "\nWarnings:")
 			for _, warnMsg := range result.Warnings {
-				fmt.Printf("  - %s\n", warnMsg)
+				fmt.Fprintf(out, "  - %s\n", warnMsg)
 			}
 		}
 	} else {
-		fmt.Println("\nBuild completed successfully with 0 errors and 0 warnings.")
+		fmt.Fprintln(out, "\nBuild completed successfully with 0 errors and 0 warnings.")
 	}
 
 	if err != nil {
 		return fmt.Errorf("compilation process failed: %w", err)
 	}
+	result.Output = outputPath
+
+	// Check that open.mp or a plugin provides every native the script calls
+	if config.NativeCheck() != natives.CheckOff {
+		check := nativeCheck{
+			fsys:         fsys,
+			root:         root,
+			config:       config,
+			compiler:     compiler,
+			includePaths: includePaths,
+			srv:          srv,
+			targetOS:     targetOS,
+		}
+		if err := checkNatives(check, opts, result); err != nil {
+			return err
+		}
+	}
+
+	// Stage files into the build directory, skipping unchanged ones
+	stagerOpts := config.StagerOptions()
+	stagerOpts.FS = fsys
+	st := stage.New(buildDir, stagerOpts)
+	st.Keep(filepath.ToSlash(config.OutputFile))
+	st.Build = &stage.Build{Profile: opts.Profile, TargetOS: targetOS, PawnccVersion: compilerVersion(compiler)}
+
+	// Stage the server before the project files so those take precedence.
+	// The cached server is built for this system only.
+	if srv != nil && targetOS != runtime.GOOS {
+		message := fmt.Sprintf("not staging the open.mp server, which is built for %s, not %s", runtime.GOOS, targetOS)
+		fmt.Fprintf(out, "Warning: %s\n", message)
+		opts.emit(Notice{Project: result.Project, Message: message})
+	} else if srv != nil {
+		if verbose {
+			fmt.Fprintf(out, "Staging open.mp server %s\n", srv.Version)
+		}
+		if err := srv.Stage(st); err != nil {
+			return err
+		}
+	}
 
 	// Copy necessary files to build directory
-	if err := utils.CopyRequiredFiles(buildDir); err != nil {
+	depPlugins, err := deps.PluginFiles(root, targetOS)
+	if err != nil {
+		return err
+	}
+	depComponents, err := deps.ComponentFiles(root, targetOS)
+	if err != nil {
+		return err
+	}
+	stageOpts := utils.StageOptions{
+		TargetOS:   targetOS,
+		Plugins:    depPlugins,
+		Components: depComponents,
+		Profile:    opts.Profile,
+		FS:         fsys,
+	}
+	if err := utils.CopyRequiredFiles(root, st, stageOpts); err != nil {
 		return fmt.Errorf("failed to copy required files: %w", err)
 	}
 
+	// Remove files that are no longer part of the build
+	if err := st.Finish(); err != nil {
+		return fmt.Errorf("failed to prune stale files: %w", err)
+	}
+	result.Staged = st.Stats
+	opts.emit(FilesStaged{Project: result.Project, Stats: st.Stats})
+	if verbose {
+		fmt.Fprintf(out, "Staged files: %d copied, %d linked, %d unchanged, %d removed\n",
+			st.Stats.Copied, st.Stats.Linked, st.Stats.Skipped, st.Stats.Pruned)
+	}
+
 	return nil
 }
 
-// parseBuildOutput parses the compiler output to extract errors and warnings
-func parseBuildOutput(stdout, stderr string) BuildResult {
-	result := BuildResult{
-		Success:  true,
-		Errors:   []string{},
-		Warnings: []string{},
+// pawnccPath returns the compiler directory to use, preferring the override
+func pawnccPath(config *utils.ProjectConfig, opts Options) string {
+	if opts.PawnccPath != "" {
+		return opts.PawnccPath
 	}
+	return config.PawnccPath
+}
 
-	// Combine stdout and stderr
-	output := stdout + "\n" + stderr
+// pawnccVersion returns the pinned compiler version, preferring the override
+func pawnccVersion(config *utils.ProjectConfig, opts Options) string {
+	if opts.PawnccVersion != "" {
+		return opts.PawnccVersion
+	}
+	return config.PawnccVersion
+}
 
-	// Regular expressions for error and warning detection
-	errorRegex := regexp.MustCompile(`(?i)(error|fatal error|undefined symbol|cannot find|not found).*`)
-	warningRegex := regexp.MustCompile(`(?i)(warning|note|suggestion).*`)
+// FindPawncc returns the compiler to use. A pinned version selects an
+// installed toolchain; otherwise pawncc is looked up in pawncc_path and
+// then in PATH. Whichever compiler is found must report the pinned version.
+// Projects that pin no version and have no pawncc_path use the default
+// toolchain of the user configuration when it is installed.
+func FindPawncc(root string, config *utils.ProjectConfig, opts Options) (string, error) {
+	version := pawnccVersion(config, opts)
+	if version == "" && pawnccPath(config, opts) == "" {
+		if user, err := userconfig.Load(); err == nil && user.DefaultToolchain != "" {
+			t, err := toolchain.Find(user.DefaultToolchain)
+			if err == nil {
+				return t.Pawncc(), nil
+			}
+			if opts.Verbose {
+				fmt.Fprintf(opts.stdout(), "Warning: default toolchain unavailable: %v\n", err)
+			}
+		}
+	}
 
-	// Scan through the output line by line
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
+	var notInstalled error
+	if version != "" {
+		t, err := toolchain.Find(version)
+		if err == nil {
+			return checkVersion(t.Pawncc(), version)
+		}
+		if !errors.Is(err, toolchain.ErrNotInstalled) {
+			return "", err
+		}
+		notInstalled = err
+	}
 
-		// Check for errors
-		if errorRegex.MatchString(line) {
-			result.Errors = append(result.Errors, strings.TrimSpace(line))
-			result.Success = false
-		} else if warningRegex.MatchString(line) {
-			result.Warnings = append(result.Warnings, strings.TrimSpace(line))
+	// Fallback to just "pawncc" and rely on PATH
+	pawnccExe := toolchain.ExeName()
+	if pawnccDir := pawnccPath(config, opts); pawnccDir != "" {
+		if !filepath.IsAbs(pawnccDir) {
+			pawnccDir = filepath.Join(root, pawnccDir)
+		}
+		if _, err := os.Stat(filepath.Join(pawnccDir, pawnccExe)); err == nil {
+			pawnccExe = filepath.Join(pawnccDir, pawnccExe)
+		} else if opts.Verbose {
+			fmt.Fprintf(opts.stdout(), "Warning: pawncc not found in %s, trying to find in PATH\n", pawnccDir)
 		}
 	}
 
-	return result
+	if version == "" {
+		return pawnccExe, nil
+	}
+
+	// Without a usable fallback compiler the missing toolchain is the problem
+	if _, err := toolchain.Probe(pawnccExe); err != nil {
+		return "", notInstalled
+	}
+	return checkVersion(pawnccExe, version)
+}
+
+// compilerVersion returns the version a compiler reports, or an empty
+// string for compilers that cannot be asked
+func compilerVersion(c Compiler) string {
+	exec, ok := c.(*ExecCompiler)
+	if !ok {
+		return ""
+	}
+	version, _ := toolchain.Probe(exec.Exe)
+	return version
+}
+
+// checkVersion fails unless the compiler at exe reports the pinned version
+func checkVersion(exe, version string) (string, error) {
+	probed, err := toolchain.Probe(exe)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify the compiler version: %w", err)
+	}
+	if !toolchain.Matches(version, probed) {
+		return "", fmt.Errorf("pawncc version mismatch: %s is %s, but the project requires %s (install it with 'ompcli toolchain install %s')", exe, probed, version, version)
+	}
+	return exe, nil
+}
+
+// diagnosticLine matches a pawncc diagnostic such as
+// "file.pwn(12) : error 017: undefined symbol" or a line range "(10 -- 12)"
+var diagnosticLine = regexp.MustCompile(`^(.*?)\((\d+)(?:\s*--\s*(\d+))?\)\s*:\s*(fatal error|error|warning)\s+(\d+)\s*:\s*(.*)$`)
+
+// summaryLine matches the lines pawncc ends with, such as "1 Error.",
+// "2 Warnings." or "Compilation aborted.", which repeat the diagnostics
+var summaryLine = regexp.MustCompile(`(?i)^\s*(?:\d+\s+(?:errors?|warnings?)\.|compilation aborted\.)\s*$`)
+
+// Regular expressions for error and warning detection in lines that are
+// not formatted as a diagnostic
+var (
+	errorRegex   = regexp.MustCompile(`(?i)(error|fatal error|undefined symbol|cannot find|not found).*`)
+	warningRegex = regexp.MustCompile(`(?i)(warning|note|suggestion).*`)
+)
+
+// parseLine returns the error or warning on a line of compiler output
+func parseLine(line string) (Diagnostic, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || summaryLine.MatchString(line) {
+		return Diagnostic{}, false
+	}
+
+	// A diagnostic says what it is; only guess for other lines
+	if m := diagnosticLine.FindStringSubmatch(line); m != nil {
+		return parseDiagnostic(line, m), true
+	}
+	switch {
+	case errorRegex.MatchString(line):
+		return Diagnostic{Severity: SeverityError, Message: line, Raw: line}, true
+	case warningRegex.MatchString(line):
+		return Diagnostic{Severity: SeverityWarning, Message: line, Raw: line}, true
+	}
+	return Diagnostic{}, false
+}
+
+// parseDiagnostic builds a diagnostic from a line diagnosticLine matched.
+// Fatal errors are errors.
+func parseDiagnostic(line string, m []string) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Raw: line}
+	if m[4] == "warning" {
+		d.Severity = SeverityWarning
+	}
+	d.File = strings.TrimSpace(m[1])
+	d.Line, _ = strconv.Atoi(m[2])
+	d.EndLine = d.Line
+	if m[3] != "" {
+		d.EndLine, _ = strconv.Atoi(m[3])
+	}
+	d.Code, _ = strconv.Atoi(m[5])
+	d.Message = m[6]
+	return d
+}
+
+// lineWriter passes every line written to it to fn
+type lineWriter struct {
+	fn  func(string)
+	buf []byte
+}
+
+// Write implements io.Writer
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush passes on a last line without a newline
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(string(w.buf))
+		w.buf = nil
+	}
 }
@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+		want Diagnostic
+	}{
+		{
+			name: "warning",
+			line: `gm.pwn(10) : warning 219: local variable "error" shadows a variable at a preceding level`,
+			ok:   true,
+			want: Diagnostic{File: "gm.pwn", Line: 10, EndLine: 10, Severity: SeverityWarning, Code: 219, Message: `local variable "error" shadows a variable at a preceding level`},
+		},
+		{
+			name: "error",
+			line: "gamemodes/gm.pwn(12) : error 017: undefined symbol \"foo\"\r",
+			ok:   true,
+			want: Diagnostic{File: "gamemodes/gm.pwn", Line: 12, EndLine: 12, Severity: SeverityError, Code: 17, Message: `undefined symbol "foo"`},
+		},
+		{
+			name: "fatal error",
+			line: "gm.pwn(1) : fatal error 100: cannot read from file: \"missing\"",
+			ok:   true,
+			want: Diagnostic{File: "gm.pwn", Line: 1, EndLine: 1, Severity: SeverityError, Code: 100, Message: `cannot read from file: "missing"`},
+		},
+		{
+			name: "line range",
+			line: "gm.pwn(10 -- 12) : warning 203: symbol is never used: \"x\"",
+			ok:   true,
+			want: Diagnostic{File: "gm.pwn", Line: 10, EndLine: 12, Severity: SeverityWarning, Code: 203, Message: `symbol is never used: "x"`},
+		},
+		{
+			name: "unformatted error",
+			line: "cannot find include file",
+			ok:   true,
+			want: Diagnostic{Severity: SeverityError, Message: "cannot find include file"},
+		},
+		{name: "error summary", line: "1 Error."},
+		{name: "errors summary", line: "3 Errors."},
+		{name: "warnings summary", line: "2 Warnings."},
+		{name: "aborted", line: "Compilation aborted."},
+		{name: "banner", line: "Pawn compiler 3.10.10\t\t\tCopyright (c) 1997-2006, ITB CompuPhase"},
+		{name: "empty", line: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			tt.want.Raw = got.Raw
+			if got != tt.want {
+				t.Errorf("parseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// memProject returns an in-memory gamemode project rooted at root
+func memProject(root string) *vfs.Mem {
+	return vfs.NewMem(map[string][]byte{
+		filepath.Join(root, "project.json"): []byte(`{
+  "name": "gm",
+  "version": "1.0.0",
+  "main_file": "gamemodes/gm.pwn",
+  "output_file": "gamemodes/gm.amx",
+  "check_natives": "off"
+}`),
+		filepath.Join(root, "config.json"):         []byte(`{"hostname": "test", "port": 7777, "maxplayers": 50}`),
+		filepath.Join(root, "gamemodes", "gm.pwn"): []byte("main() {}\n"),
+	})
+}
+
+// eventNames returns the type names of events, in order
+func eventNames(events []Event) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = reflect.TypeOf(e).Name()
+	}
+	return names
+}
+
+func TestBuildWithFakeCompiler(t *testing.T) {
+	warning := Diagnostic{Severity: SeverityWarning, File: "gamemodes/gm.pwn", Line: 3, Code: 203, Message: `symbol is never used: "x"`}
+	failure := Diagnostic{Severity: SeverityError, File: "gamemodes/gm.pwn", Line: 5, Code: 17, Message: `undefined symbol "y"`}
+
+	tests := []struct {
+		name        string
+		diagnostics []Diagnostic
+		wantErr     bool
+		wantEvents  []string
+		wantErrors  int
+		wantWarns   int
+	}{
+		{
+			name:        "success with a warning",
+			diagnostics: []Diagnostic{warning},
+			wantEvents:  []string{"TargetStarted", "CompilerInvoked", "DiagnosticReported", "FilesStaged", "TargetFinished"},
+			wantWarns:   1,
+		},
+		{
+			name:        "compile error",
+			diagnostics: []Diagnostic{warning, failure},
+			wantErr:     true,
+			wantEvents:  []string{"TargetStarted", "CompilerInvoked", "DiagnosticReported", "DiagnosticReported", "TargetFinished"},
+			wantErrors:  1,
+			wantWarns:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "gm")
+			fsys := memProject(root)
+			compiler := &FakeCompiler{FS: fsys, Diagnostics: tt.diagnostics, Output: []byte("amx")}
+
+			var events []Event
+			result, err := Build(context.Background(), root, Options{
+				Stdout:   io.Discard,
+				Stderr:   io.Discard,
+				OnEvent:  func(e Event) { events = append(events, e) },
+				Compiler: compiler,
+				FS:       fsys,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := eventNames(events); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", got, tt.wantEvents)
+			}
+			if result.Project != "gm" || result.Success == tt.wantErr {
+				t.Errorf("result = %+v", result)
+			}
+			if len(result.Errors) != tt.wantErrors || len(result.Warnings) != tt.wantWarns {
+				t.Errorf("got %d errors and %d warnings, want %d and %d", len(result.Errors), len(result.Warnings), tt.wantErrors, tt.wantWarns)
+			}
+			if len(result.Diagnostics) != len(tt.diagnostics) || result.Diagnostics[0].Line != 3 {
+				t.Errorf("diagnostics = %+v", result.Diagnostics)
+			}
+
+			jobs := compiler.Jobs()
+			if len(jobs) != 1 || jobs[0].Source != "gamemodes/gm.pwn" {
+				t.Fatalf("jobs = %+v", jobs)
+			}
+			output := filepath.Join(root, "build", "gamemodes", "gm.amx")
+			if jobs[0].Output != output {
+				t.Errorf("output = %s, want %s", jobs[0].Output, output)
+			}
+
+			if tt.wantErr {
+				return
+			}
+			if result.Output != output {
+				t.Errorf("result.Output = %s, want %s", result.Output, output)
+			}
+			files := fsys.Files()
+			if string(files[output]) != "amx" {
+				t.Errorf("compiled script = %q", files[output])
+			}
+			if _, ok := files[filepath.Join(root, "build", "config.json")]; !ok {
+				t.Error("config.json was not staged")
+			}
+
+			// The fake compiler has no version to record
+			var manifest stage.Manifest
+			if err := json.Unmarshal(files[filepath.Join(root, "build", stage.ManifestFile)], &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if want := (stage.Build{TargetOS: runtime.GOOS}); manifest.Build == nil || *manifest.Build != want {
+				t.Errorf("recorded build = %+v, want %+v", manifest.Build, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/weltschmerzie/omp-cli/internal/toolchain"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// Compiler compiles the main script of a project
+type Compiler interface {
+	// Path identifies the compiler, such as the pawncc executable
+	Path() string
+
+	// Compile compiles job.Source into job.Output, writing the compiler's
+	// messages to job.Stdout and job.Stderr. It fails when the compiler
+	// does.
+	Compile(ctx context.Context, job CompileJob) error
+}
+
+// CompileJob is one compilation
+type CompileJob struct {
+	// Dir is the project root, which relative paths resolve against
+	Dir string
+
+	// Source is the main script, relative to Dir, and Output the absolute
+	// path of the compiled script
+	Source string
+	Output string
+
+	// IncludePaths are searched for includes, in order
+	IncludePaths []string
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExecCompiler runs a pawncc executable
+type ExecCompiler struct {
+	Exe string
+}
+
+// Path returns the pawncc executable
+func (c *ExecCompiler) Path() string {
+	return c.Exe
+}
+
+// Compile runs pawncc; cancelling ctx kills it
+func (c *ExecCompiler) Compile(ctx context.Context, job CompileJob) error {
+	// Create command with output file and include path options
+	args := []string{"-o" + job.Output}
+	for _, dir := range job.IncludePaths {
+		args = append(args, "-i"+dir)
+	}
+	args = append(args, job.Source)
+	cmd := exec.CommandContext(ctx, c.Exe, args...)
+	cmd.Env = toolchain.Env(c.Exe)
+
+	// Compile from the project root so relative paths resolve against it
+	cmd.Dir = job.Dir
+	cmd.Stdout = job.Stdout
+	cmd.Stderr = job.Stderr
+	return cmd.Run()
+}
+
+// FakeCompiler stands in for pawncc without running anything. It prints
+// its diagnostics the way pawncc does and, unless one of them is an error,
+// writes Output as the compiled script.
+type FakeCompiler struct {
+	// FS receives the compiled script; nil means the real file system
+	FS vfs.FS
+
+	// Diagnostics are reported by every compilation
+	Diagnostics []Diagnostic
+
+	// Output is the compiled script that is written
+	Output []byte
+
+	// Err, when set, fails every compilation
+	Err error
+
+	mu   sync.Mutex
+	jobs []CompileJob
+}
+
+// Path returns a name for the fake compiler
+func (c *FakeCompiler) Path() string {
+	return "fake-pawncc"
+}
+
+// Compile records the job and reports the canned diagnostics
+func (c *FakeCompiler) Compile(ctx context.Context, job CompileJob) error {
+	c.mu.Lock()
+	c.jobs = append(c.jobs, job)
+	c.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	failed := false
+	for _, d := range c.Diagnostics {
+		if job.Stdout != nil {
+			fmt.Fprintln(job.Stdout, d.String())
+		}
+		failed = failed || d.Severity == SeverityError
+	}
+	if c.Err != nil {
+		return c.Err
+	}
+	if failed {
+		return fmt.Errorf("exit status 1")
+	}
+
+	fsys := vfs.Or(c.FS)
+	if err := fsys.MkdirAll(filepath.Dir(job.Output), 0755); err != nil {
+		return err
+	}
+	return fsys.WriteFile(job.Output, c.Output, 0644)
+}
+
+// Jobs returns the compilations asked for so far
+func (c *FakeCompiler) Jobs() []CompileJob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CompileJob{}, c.jobs...)
+}
@@ -0,0 +1,136 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/deps"
+	"github.com/weltschmerzie/omp-cli/internal/natives"
+	"github.com/weltschmerzie/omp-cli/internal/server"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+	"github.com/weltschmerzie/omp-cli/pkg/amx"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// nativeCheck is what checkNatives needs to know about a build
+type nativeCheck struct {
+	fsys         vfs.FS
+	root         string
+	config       *utils.ProjectConfig
+	compiler     Compiler
+	includePaths []string
+	srv          *server.Server
+	targetOS     string
+}
+
+// checkNatives compares the natives the compiled script calls with those
+// open.mp and the project's plugins provide. Missing natives fail the
+// build or are reported as a warning, as check_natives asks.
+func checkNatives(c nativeCheck, opts Options, result *BuildResult) error {
+	out := opts.stdout()
+	warn := func(message string) {
+		fmt.Fprintf(out, "Warning: %s\n", message)
+		opts.emit(Notice{Project: result.Project, Message: message})
+	}
+
+	data, err := c.fsys.ReadFile(result.Output)
+	if err != nil {
+		return fmt.Errorf("failed to read compiled script: %w", err)
+	}
+	script, err := amx.Parse(data)
+	if err != nil {
+		warn(fmt.Sprintf("cannot check natives: %v", err))
+		return nil
+	}
+
+	project, err := c.project()
+	if err != nil {
+		return err
+	}
+	check, err := natives.Check(project, script.NativeNames())
+	if err != nil {
+		return fmt.Errorf("failed to check natives: %w", err)
+	}
+	if !check.RuntimeFound {
+		warn("cannot check natives: the open.mp includes were not found")
+		return nil
+	}
+	if len(check.Missing) == 0 {
+		return nil
+	}
+
+	described := make([]string, len(check.Missing))
+	declared := false
+	for i, m := range check.Missing {
+		result.MissingNatives = append(result.MissingNatives, m.Name)
+		described[i] = m.String()
+		declared = declared || m.DeclaredIn != ""
+	}
+	message := fmt.Sprintf("the script calls natives that neither open.mp nor a configured plugin provides: %s", strings.Join(described, ", "))
+
+	// Includes are matched to plugins by name, which misses those such as
+	// sscanf2.inc of sscanf
+	if declared {
+		message += `; if a plugin provides them, list the include declaring them with the plugin, as in {"name": "sscanf", "includes": ["sscanf2.inc"]}`
+	}
+	if c.config.NativeCheck() == natives.CheckError {
+		return fmt.Errorf("%s", message)
+	}
+	warn(message)
+	return nil
+}
+
+// project gathers the includes and plugins of the build
+func (c nativeCheck) project() (natives.Project, error) {
+	p := natives.Project{FS: c.fsys, Root: c.root}
+
+	// The compiler searches the include paths it is given, the project's
+	// own and its default include folder
+	p.IncludeDirs = append(p.IncludeDirs, c.includePaths...)
+	for _, dir := range c.config.IncludePaths {
+		p.IncludeDirs = append(p.IncludeDirs, filepath.Join(c.root, filepath.FromSlash(dir)))
+	}
+	if exe := c.compiler.Path(); filepath.IsAbs(exe) {
+		bin := filepath.Dir(exe)
+		p.IncludeDirs = append(p.IncludeDirs, filepath.Join(bin, "include"), filepath.Join(filepath.Dir(bin), "include"))
+	}
+
+	// The includes bundled with the pinned server are all open.mp's
+	if c.srv != nil {
+		if dir := c.srv.IncludeDir(); dir != "" {
+			p.RuntimeDirs = append(p.RuntimeDirs, dir)
+		}
+	}
+
+	for _, plugin := range c.config.Plugins {
+		np := natives.Plugin{Name: plugin.Name, Includes: plugin.Includes}
+		if file, _, err := plugin.ResolveFS(c.fsys, c.root, c.targetOS); err == nil {
+			np.File = filepath.Join(c.root, filepath.FromSlash(file))
+		}
+		p.Plugins = append(p.Plugins, np)
+	}
+
+	// Dependencies shipping plugins or components provide the natives
+	// their includes declare
+	lock, err := deps.LoadLock(c.root)
+	if err != nil || lock == nil {
+		return p, err
+	}
+	for name, locked := range lock.Packages {
+		binaries := append(append([]string{}, locked.Plugins[c.targetOS]...), locked.Components[c.targetOS]...)
+		if len(binaries) == 0 {
+			continue
+		}
+		dir := filepath.Join(c.root, deps.Dir, name)
+		pkg := natives.Package{Name: name}
+		for _, inc := range locked.IncludePaths {
+			pkg.IncludeDirs = append(pkg.IncludeDirs, filepath.Join(dir, filepath.FromSlash(inc)))
+		}
+		for _, file := range binaries {
+			pkg.Files = append(pkg.Files, filepath.Join(dir, filepath.FromSlash(file)))
+		}
+		p.Packages = append(p.Packages, pkg)
+	}
+	return p, nil
+}
@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// nativesProject writes a project whose includes, plugins and dependency
+// provide every native of testdata/natives.amx but Unknown_Native
+func nativesProject(t *testing.T, checkNatives string) string {
+	root := filepath.Join(t.TempDir(), "gm")
+	files := map[string]string{
+		"project.json": fmt.Sprintf(`{
+  "name": "gm",
+  "version": "1.0.0",
+  "main_file": "gamemodes/gm.pwn",
+  "output_file": "gamemodes/gm.amx",
+  "include_paths": ["include"],
+  "plugins": ["streamer", "mysql"],
+  "check_natives": %q
+}`, checkNatives),
+		"config.json":           `{"hostname": "test"}`,
+		"gamemodes/gm.pwn":      "main() {}\n",
+		"include/open.mp.inc":   "native print(const string[]);\nnative SetGameModeText(const string[]);\n",
+		"include/streamer.inc":  "native CreateDynamicObject(modelid, Float:x, Float:y, Float:z);\n",
+		"include/unknown.inc":   "native Unknown_Native();\n",
+		"plugins/streamer.so":   "elf",
+		"plugins/mysql.so":      "elf",
+		"plugins/mysql.natives": "mysql_connect\n",
+		"ompcli.lock": fmt.Sprintf(`{
+  "lockfile_version": 1,
+  "packages": {
+    "discord": {"version": "1.0.0", "include_paths": ["include"], "plugins": {%q: ["plugins/discord.so"]}}
+  }
+}`, runtime.GOOS),
+		"dependencies/discord/include/discord.inc": "native DCC_Connect(const token[]);\n",
+		"dependencies/discord/plugins/discord.so":  "elf",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBuildChecksNatives(t *testing.T) {
+	script, err := os.ReadFile(filepath.Join("testdata", "natives.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := fmt.Sprintf("provides: Unknown_Native (declared in %s)", filepath.Join("include", "unknown.inc"))
+
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{mode: "warn"},
+		{mode: "error", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			root := nativesProject(t, tt.mode)
+			var out strings.Builder
+			var notices []string
+			result, err := Build(context.Background(), root, Options{
+				Stdout: &out,
+				Stderr: io.Discard,
+				OnEvent: func(e Event) {
+					if n, ok := e.(Notice); ok {
+						notices = append(notices, n.Message)
+					}
+				},
+				Compiler: &FakeCompiler{Output: script},
+			})
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), missing) {
+					t.Fatalf("Build error = %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(notices) != 1 || !strings.Contains(notices[0], missing) || !strings.Contains(out.String(), "Warning: ") {
+					t.Errorf("notices = %q", notices)
+				}
+			}
+			if want := []string{"Unknown_Native"}; !reflect.DeepEqual(result.MissingNatives, want) {
+				t.Errorf("missing natives = %v, want %v", result.MissingNatives, want)
+			}
+			if message := fmt.Sprint(err, notices); !strings.Contains(message, `"includes": ["sscanf2.inc"]`) {
+				t.Errorf("no hint about includes: %s", message)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package clean
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+)
+
+// Scopes of what can be cleaned
+const (
+	// ScopeArtifacts is the compiled scripts (.amx) in build/
+	ScopeArtifacts = "artifacts"
+
+	// ScopeStaged is the files staged into build/ by the last build
+	ScopeStaged = "staged"
+
+	// ScopeCache is the project's download cache in .ompcli/cache
+	ScopeCache = "cache"
+
+	// ScopeLogs is the logs the server wrote into build/
+	ScopeLogs = "logs"
+
+	// ScopeAll is everything in build/ and the cache
+	ScopeAll = "all"
+)
+
+// Scopes lists the valid scopes
+var Scopes = []string{ScopeArtifacts, ScopeStaged, ScopeCache, ScopeLogs, ScopeAll}
+
+// DataDirs hold data the server keeps between runs. They are left alone
+// unless Options.IncludeData is set.
+var DataDirs = []string{"scriptfiles"}
+
+// cacheDir is the project's cache, relative to the project root
+const cacheDir = ".ompcli/cache"
+
+// Options controls what Plan selects
+type Options struct {
+	Scopes      []string
+	IncludeData bool
+}
+
+// Plan returns the files and directories a clean of the project rooted at
+// root would remove, sorted
+func Plan(root string, opts Options) ([]string, error) {
+	buildDir := filepath.Join(root, "build")
+	selected := map[string]bool{}
+	for _, scope := range opts.Scopes {
+		if !valid(scope) {
+			return nil, fmt.Errorf("unknown scope %q (use %s)", scope, strings.Join(Scopes, ", "))
+		}
+		selected[scope] = true
+	}
+
+	found := map[string]bool{}
+	add := func(p string) {
+		if _, err := os.Lstat(p); err == nil && (opts.IncludeData || !isData(buildDir, p)) {
+			found[p] = true
+		}
+	}
+
+	if selected[ScopeAll] {
+		entries, _ := os.ReadDir(buildDir)
+		for _, entry := range entries {
+			add(filepath.Join(buildDir, entry.Name()))
+		}
+		add(filepath.Join(root, cacheDir))
+		return sorted(found), nil
+	}
+
+	if selected[ScopeArtifacts] {
+		err := walkFiles(buildDir, func(p string) {
+			if strings.EqualFold(filepath.Ext(p), ".amx") {
+				add(p)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if selected[ScopeStaged] {
+		manifest, err := stage.LoadManifest(buildDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range manifest.Files {
+			add(filepath.Join(buildDir, filepath.FromSlash(file)))
+		}
+		add(filepath.Join(buildDir, stage.ManifestFile))
+	}
+
+	if selected[ScopeCache] {
+		add(filepath.Join(root, cacheDir))
+	}
+
+	if selected[ScopeLogs] {
+		add(filepath.Join(buildDir, "logs"))
+		err := walkFiles(buildDir, func(p string) {
+			name := strings.ToLower(filepath.Base(p))
+			if name == "log.txt" || name == "crashinfo.txt" || filepath.Ext(name) == ".log" {
+				add(p)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted(found), nil
+}
+
+// Remove deletes the paths returned by Plan and then any folders in build/
+// left empty by it
+func Remove(root string, paths []string) error {
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+	}
+
+	buildDir := filepath.Join(root, "build")
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		for dir != buildDir && strings.HasPrefix(dir, buildDir+string(filepath.Separator)) {
+			if os.Remove(dir) != nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+	return nil
+}
+
+// isData reports whether p is, or is inside, one of the data folders of build/
+func isData(buildDir, p string) bool {
+	for _, dir := range DataDirs {
+		data := filepath.Join(buildDir, dir)
+		if p == data || strings.HasPrefix(p, data+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFiles calls fn for every file below dir; a missing dir has none
+func walkFiles(dir string, fn func(string)) error {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			fn(p)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// valid reports whether scope is one of Scopes
+func valid(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns the keys of a set in order
+func sorted(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for p := range set {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,168 @@
+package clean
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+)
+
+// builtProject writes a project whose build staged a script, a plugin and
+// a file in scriptfiles/, and where the server wrote logs and data
+func builtProject(t *testing.T) string {
+	root := t.TempDir()
+	manifest, err := json.Marshal(stage.Manifest{Files: []string{"gamemodes/gm.amx", "plugins/streamer.so", "scriptfiles/spawns.ini"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"build/" + stage.ManifestFile:  string(manifest),
+		"build/gamemodes/gm.amx":       "amx",
+		"build/plugins/streamer.so":    "so",
+		"build/scriptfiles/spawns.ini": "spawns",
+		"build/scriptfiles/player.ini": "player",
+		"build/scriptfiles/backup.amx": "amx",
+		"build/scriptfiles/admin.log":  "log",
+		"build/logs/server.log":        "log",
+		"build/log.txt":                "log",
+		".ompcli/cache/deps/a.tar.gz":  "archive",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// exists reports whether a slash-separated path exists in the project
+func exists(root, name string) bool {
+	_, err := os.Lstat(filepath.Join(root, filepath.FromSlash(name)))
+	return err == nil
+}
+
+func TestCleanKeepsData(t *testing.T) {
+	for _, scope := range Scopes {
+		t.Run(scope, func(t *testing.T) {
+			root := builtProject(t)
+			paths, err := Plan(root, Options{Scopes: []string{scope}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) == 0 {
+				t.Fatal("nothing to clean")
+			}
+			for _, p := range paths {
+				if strings.Contains(filepath.ToSlash(p), "/scriptfiles") {
+					t.Errorf("planned to remove %s", p)
+				}
+			}
+
+			if err := Remove(root, paths); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"spawns.ini", "player.ini", "backup.amx", "admin.log"} {
+				if !exists(root, "build/scriptfiles/"+name) {
+					t.Errorf("scriptfiles/%s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanScopes(t *testing.T) {
+	tests := []struct {
+		scope   string
+		removed []string
+		kept    []string
+	}{
+		{
+			scope:   ScopeArtifacts,
+			removed: []string{"build/gamemodes/gm.amx"},
+			kept:    []string{"build/plugins/streamer.so", "build/logs/server.log", ".ompcli/cache"},
+		},
+		{
+			scope:   ScopeStaged,
+			removed: []string{"build/gamemodes/gm.amx", "build/plugins/streamer.so", "build/" + stage.ManifestFile},
+			kept:    []string{"build/logs/server.log", "build/log.txt", ".ompcli/cache"},
+		},
+		{
+			scope:   ScopeLogs,
+			removed: []string{"build/logs", "build/log.txt"},
+			kept:    []string{"build/gamemodes/gm.amx", ".ompcli/cache"},
+		},
+		{
+			scope:   ScopeCache,
+			removed: []string{".ompcli/cache"},
+			kept:    []string{"build/gamemodes/gm.amx", "build/log.txt"},
+		},
+		{
+			scope:   ScopeAll,
+			removed: []string{"build/gamemodes", "build/plugins", "build/logs", "build/log.txt", ".ompcli/cache"},
+			kept:    []string{"build/scriptfiles/player.ini"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			root := builtProject(t)
+			paths, err := Plan(root, Options{Scopes: []string{tt.scope}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Remove(root, paths); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.removed {
+				if exists(root, name) {
+					t.Errorf("%s was kept", name)
+				}
+			}
+			for _, name := range tt.kept {
+				if !exists(root, name) {
+					t.Errorf("%s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanIncludeData(t *testing.T) {
+	root := builtProject(t)
+	paths, err := Plan(root, Options{Scopes: []string{ScopeAll}, IncludeData: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(root, paths); err != nil {
+		t.Fatal(err)
+	}
+	if exists(root, "build/scriptfiles") {
+		t.Error("scriptfiles was kept with IncludeData")
+	}
+}
+
+func TestPlanRemovesNothing(t *testing.T) {
+	// A dry run only plans
+	root := builtProject(t)
+	if _, err := Plan(root, Options{Scopes: Scopes, IncludeData: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"build/gamemodes/gm.amx", "build/scriptfiles/player.ini", "build/logs/server.log", ".ompcli/cache/deps/a.tar.gz"} {
+		if !exists(root, name) {
+			t.Errorf("%s was removed", name)
+		}
+	}
+}
+
+func TestPlanUnknownScope(t *testing.T) {
+	if _, err := Plan(t.TempDir(), Options{Scopes: []string{"everything"}}); err == nil {
+		t.Error("Plan accepted an unknown scope")
+	}
+}
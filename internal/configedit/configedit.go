@@ -0,0 +1,411 @@
+package configedit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// Target names accepted as path prefixes, e.g. "server:port"
+const (
+	TargetProject = "project"
+	TargetServer  = "server"
+	TargetUser    = "user"
+)
+
+// validator is implemented by the configuration structs in utils
+type validator interface {
+	Validate() error
+}
+
+// File is an editable configuration file bound to its schema
+type File struct {
+	Target string
+	Path   string
+	Doc    *jsonedit.Document
+
+	// Warnings are problems found while editing that do not stop the file
+	// from being saved, such as keys the schema does not know
+	Warnings []string
+
+	// Force allows keys config.json does not know, which are refused
+	// otherwise; the other files only warn about them
+	Force bool
+
+	schema reflect.Type
+	orig   []byte
+}
+
+// SplitTarget splits "server:port" into its target and key path.
+// Paths without a prefix refer to project.json.
+func SplitTarget(arg string) (string, string, error) {
+	target, path, found := strings.Cut(arg, ":")
+	if !found {
+		return TargetProject, arg, nil
+	}
+
+	switch target {
+	case TargetProject, TargetServer, TargetUser:
+		return target, path, nil
+	}
+	return "", "", fmt.Errorf("unknown configuration %q (expected %q, %q or %q)", target, TargetProject, TargetServer, TargetUser)
+}
+
+// Open loads project.json or config.json of the project rooted at root,
+// or the user configuration, for editing
+func Open(root, target string) (*File, error) {
+	f := &File{Target: target}
+	switch target {
+	case TargetProject:
+		f.Path = filepath.Join(root, utils.ProjectFile)
+		f.schema = reflect.TypeOf(utils.ProjectConfig{})
+	case TargetServer:
+		f.Path = filepath.Join(root, "config.json")
+		f.schema = reflect.TypeOf(utils.ServerConfig{})
+	case TargetUser:
+		path, err := userconfig.Path()
+		if err != nil {
+			return nil, err
+		}
+		f.Path = path
+		f.schema = reflect.TypeOf(userconfig.Config{})
+	default:
+		return nil, fmt.Errorf("unknown configuration %q", target)
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) && target == TargetUser {
+		// The user configuration is created on first use
+		data = []byte("{}\n")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+
+	f.Doc, err = jsonedit.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	f.orig = data
+
+	return f, nil
+}
+
+// Get returns the value at path
+func (f *File) Get(path jsonedit.Path) (*jsonedit.Value, error) {
+	return f.Doc.Get(path)
+}
+
+// Set coerces raw to the type expected at path and stores it
+func (f *File) Set(path jsonedit.Path, raw string) error {
+	if err := f.checkKeys(path); err != nil {
+		return err
+	}
+	existing, _ := f.Doc.Get(path)
+	value, err := coerce(raw, fieldType(f.schema, path), existing)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Doc.Set(path, value)
+}
+
+// Append adds raw to the array at path, creating the array if needed
+func (f *File) Append(path jsonedit.Path, raw string) error {
+	if err := f.checkKeys(path); err != nil {
+		return err
+	}
+	arr, err := f.Doc.Get(path)
+	if errors.Is(err, jsonedit.ErrNotFound) {
+		arr = jsonedit.NewArray()
+		if err := f.Doc.Set(path, arr); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if arr.Kind != jsonedit.Array {
+		return fmt.Errorf("%s: cannot append to a %s", path, arr.Kind)
+	}
+
+	var elem reflect.Type
+	if t := fieldType(f.schema, path); t != nil && t.Kind() == reflect.Slice {
+		elem = t.Elem()
+	}
+	var sample *jsonedit.Value
+	if len(arr.Items) > 0 {
+		sample = arr.Items[0]
+	}
+
+	value, err := coerce(raw, elem, sample)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, item := range arr.Items {
+		if item.Equal(value) {
+			return nil
+		}
+	}
+	arr.Items = append(arr.Items, value)
+	return nil
+}
+
+// Remove deletes every element equal to raw from the array at path
+func (f *File) Remove(path jsonedit.Path, raw string) error {
+	arr, err := f.Doc.Get(path)
+	if err != nil {
+		return err
+	}
+	if arr.Kind != jsonedit.Array {
+		return fmt.Errorf("%s: cannot remove from a %s", path, arr.Kind)
+	}
+
+	var sample *jsonedit.Value
+	if len(arr.Items) > 0 {
+		sample = arr.Items[0]
+	}
+	value, err := coerce(raw, nil, sample)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	kept := arr.Items[:0]
+	for _, item := range arr.Items {
+		if !item.Equal(value) {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(arr.Items) {
+		return fmt.Errorf("%s: %s is not in the list", path, raw)
+	}
+	arr.Items = kept
+	return nil
+}
+
+// Unset removes the value at path
+func (f *File) Unset(path jsonedit.Path) error {
+	return f.Doc.Delete(path)
+}
+
+// Validate decodes the document into its schema and checks the values
+func (f *File) Validate() error {
+	return validate(f.schema, f.Doc.Bytes())
+}
+
+// validate decodes data into the schema t and checks the values
+func validate(t reflect.Type, data []byte) error {
+	config := reflect.New(t).Interface()
+	if err := json.Unmarshal(data, config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s must be of type %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return err
+	}
+
+	if v, ok := config.(validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// Save validates the document and writes it back to disk. Only problems
+// the edits introduce stop it: a file that was invalid before is saved
+// with a warning, so that unrelated edits are not blocked by it.
+func (f *File) Save() error {
+	if err := f.Validate(); err != nil {
+		if validate(f.schema, f.orig) == nil {
+			return fmt.Errorf("invalid %s: %w", f.Path, err)
+		}
+		f.Warnings = append(f.Warnings, fmt.Sprintf("%s was already invalid before this change: %v", f.Path, err))
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path, f.Doc.Bytes(), mode)
+}
+
+// checkKeys reports a key of path the schema does not define, which is
+// often a misspelt one: as an error for config.json unless forced, as a
+// warning otherwise. Keys below maps and other values the schema leaves
+// open are not checked.
+func (f *File) checkKeys(path jsonedit.Path) error {
+	t := f.schema
+	for _, seg := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch {
+		case seg.Key == "" && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+			t = t.Elem()
+		case seg.Key != "" && t.Kind() == reflect.Struct:
+			field := structField(t, seg.Key)
+			if field == nil {
+				problem := fmt.Sprintf("%q is not a known setting of %s", seg.Key, filepath.Base(f.Path))
+				if similar := similarField(t, seg.Key); similar != "" {
+					problem += fmt.Sprintf("; did you mean %q?", similar)
+				}
+				if f.Target == TargetServer && !f.Force {
+					return errors.New(problem + " (use --force to set it anyway)")
+				}
+				f.Warnings = append(f.Warnings, problem)
+				return nil
+			}
+			t = field
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// similarField returns the JSON name of a field of the struct t that only
+// differs from name in case, underscores or dashes
+func similarField(t reflect.Type, name string) string {
+	normalize := strings.NewReplacer("_", "", "-", "")
+	want := strings.ToLower(normalize.Replace(name))
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag != "" && tag != "-" && strings.ToLower(normalize.Replace(tag)) == want {
+			return tag
+		}
+	}
+	return ""
+}
+
+// fieldType resolves the Go type a path refers to in the schema, or nil
+// when the path leaves the known schema
+func fieldType(t reflect.Type, path jsonedit.Path) reflect.Type {
+	for _, seg := range path {
+		if t == nil {
+			return nil
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch {
+		case seg.Key == "" && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+			t = t.Elem()
+		case seg.Key != "" && t.Kind() == reflect.Map:
+			t = t.Elem()
+		case seg.Key != "" && t.Kind() == reflect.Struct:
+			t = structField(t, seg.Key)
+		default:
+			return nil
+		}
+	}
+	return t
+}
+
+// structField returns the type of the struct field with the given JSON name
+func structField(t reflect.Type, name string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && field.Name == name) {
+			return field.Type
+		}
+	}
+	return nil
+}
+
+// coerce converts a command line string into a JSON value. The schema type
+// wins when known; otherwise the kind of the existing value is kept, and
+// new keys fall back to inferring the type from the text itself.
+func coerce(raw string, t reflect.Type, existing *jsonedit.Value) (*jsonedit.Value, error) {
+	// Types with custom decoding may accept several kinds, so they take
+	// any JSON literal or a string
+	kind := jsonedit.Null
+	if t != nil {
+		if !reflect.PointerTo(t).Implements(unmarshalerType) {
+			kind = kindOf(t)
+		}
+	} else if existing != nil {
+		kind = existing.Kind
+	}
+
+	switch kind {
+	case jsonedit.String:
+		return jsonedit.NewString(raw), nil
+	case jsonedit.Number:
+		if t != nil && isInteger(t) {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an integer", raw)
+			}
+			return &jsonedit.Value{Kind: jsonedit.Number, Raw: strconv.FormatInt(n, 10)}, nil
+		}
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return &jsonedit.Value{Kind: jsonedit.Number, Raw: strconv.FormatFloat(n, 'f', -1, 64)}, nil
+	case jsonedit.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return &jsonedit.Value{Kind: jsonedit.Bool, Raw: strconv.FormatBool(b)}, nil
+	case jsonedit.Array, jsonedit.Object:
+		value, err := jsonedit.ParseValue([]byte(raw))
+		if err != nil || value.Kind != kind {
+			return nil, fmt.Errorf("expected a JSON %s, got %q", kind, raw)
+		}
+		return value, nil
+	}
+
+	// Unknown key: accept any JSON literal, otherwise treat it as a string
+	if value, err := jsonedit.ParseValue([]byte(raw)); err == nil {
+		return value, nil
+	}
+	return jsonedit.NewString(raw), nil
+}
+
+// unmarshalerType is used to skip coercion for types with custom decoding
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// kindOf maps a Go type to the JSON kind it is encoded as
+func kindOf(t reflect.Type) jsonedit.Kind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return jsonedit.String
+	case reflect.Bool:
+		return jsonedit.Bool
+	case reflect.Slice, reflect.Array:
+		return jsonedit.Array
+	case reflect.Map, reflect.Struct:
+		return jsonedit.Object
+	}
+	if isInteger(t) || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+		return jsonedit.Number
+	}
+	return jsonedit.Null
+}
+
+// isInteger reports whether t is one of Go's integer types
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package configedit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+)
+
+// serverFile writes config.json into a temporary project and opens it
+func serverFile(t *testing.T, data string) *File {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "config.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(root, TargetServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// set parses path and sets it to raw
+func set(t *testing.T, f *File, path, raw string) {
+	t.Helper()
+	p, err := jsonedit.ParsePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(p, raw); err != nil {
+		t.Fatalf("Set(%s, %s): %v", path, raw, err)
+	}
+}
+
+func TestSaveKeepsFormatting(t *testing.T) {
+	input := "{\r\n  \"hostname\": \"test\",\r\n  \"port\":7777,\r\n  \"maxplayers\": 50,\r\n  \"plugins\": [\"a\", \"b\"]\r\n}\r\n"
+	f := serverFile(t, input)
+	set(t, f, "port", "8888")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(input, "7777", "8888", 1); string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+}
+
+func TestSaveValidatesTheEdit(t *testing.T) {
+	f := serverFile(t, `{"port": 7777, "maxplayers": 50}`)
+	set(t, f, "port", "70000")
+	if err := f.Save(); err == nil || !strings.Contains(err.Error(), "port") {
+		t.Fatalf("Save error = %v, want an invalid port", err)
+	}
+	data, _ := os.ReadFile(f.Path)
+	if string(data) != `{"port": 7777, "maxplayers": 50}` {
+		t.Errorf("the invalid edit was saved: %s", data)
+	}
+}
+
+func TestSaveWarnsAboutExistingProblems(t *testing.T) {
+	// maxplayers was out of range before the edit
+	f := serverFile(t, `{"port": 7777, "maxplayers": 5000}`)
+	set(t, f, "hostname", "renamed")
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(f.Warnings) != 1 || !strings.Contains(f.Warnings[0], "maxplayers") {
+		t.Errorf("warnings = %q", f.Warnings)
+	}
+	data, _ := os.ReadFile(f.Path)
+	if !strings.Contains(string(data), `"hostname": "renamed"`) {
+		t.Errorf("saved %s", data)
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "max_players", want: `"max_players" is not a known setting of config.json; did you mean "maxplayers"?`},
+		{path: "rcon.enable", want: `"rcon" is not a known setting of config.json`},
+		{path: "maxplayers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := jsonedit.ParsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Unknown keys of config.json are refused
+			f := serverFile(t, `{"port": 7777, "maxplayers": 50}`)
+			err = f.Set(path, "100")
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("Set: %v", err)
+			case tt.want != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.want+" (use --force")):
+				t.Fatalf("Set error = %v, want %q", err, tt.want)
+			}
+
+			// and only reported when forced
+			f = serverFile(t, `{"port": 7777, "maxplayers": 50}`)
+			f.Force = true
+			set(t, f, tt.path, "100")
+			switch {
+			case tt.want == "" && len(f.Warnings) > 0:
+				t.Errorf("warnings = %q, want none", f.Warnings)
+			case tt.want != "" && (len(f.Warnings) != 1 || f.Warnings[0] != tt.want):
+				t.Errorf("warnings = %q, want %q", f.Warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownProjectKeysWarn(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "project.json"), []byte(`{"name": "gm"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(root, TargetProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set(t, f, "main-file", `"gm.pwn"`)
+	if want := `"main-file" is not a known setting of project.json; did you mean "main_file"?`; len(f.Warnings) != 1 || f.Warnings[0] != want {
+		t.Errorf("warnings = %q, want %q", f.Warnings, want)
+	}
+}
+
+func TestUnknownKeysBelowMaps(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "project.json"), []byte(`{"name": "gm"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(root, TargetProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set(t, f, "dependencies.streamer", "^2.9.0")
+	if len(f.Warnings) > 0 {
+		t.Errorf("warnings = %q, want none", f.Warnings)
+	}
+}
@@ -0,0 +1,172 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/weltschmerzie/omp-cli/internal/clean"
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// DefaultDir is where the build context is written, relative to the
+// project root
+const DefaultDir = "dist/container"
+
+// DefaultBaseImage is the image the server runs on. The open.mp server is
+// a 32-bit Linux program, so the image adds the i386 runtime libraries.
+const DefaultBaseImage = "debian:bookworm-slim"
+
+// ServerDir holds the staged server inside the build context
+const ServerDir = "server"
+
+// ConfigTemplate is the staged config.json, renamed so that the entrypoint
+// can render config.json from it at start-up
+const ConfigTemplate = "config.json.tmpl"
+
+// Options controls the generated build context
+type Options struct {
+	// OutputDir is where the context is written; empty means dist/container
+	OutputDir string
+
+	// BaseImage replaces DefaultBaseImage
+	BaseImage string
+
+	// Port is the port the server listens on; zero reads it from config.json
+	Port int
+}
+
+// Result describes a generated build context
+type Result struct {
+	Dir   string
+	Port  int
+	Stats stage.Stats
+}
+
+// Generate writes a container build context for the project rooted at root
+// from what the last build staged: the server files, a Dockerfile, an
+// entrypoint that renders config.json from the environment and forwards
+// signals to the server, a healthcheck using the query protocol and a
+// .dockerignore. Docker is only needed to build the image.
+func Generate(root string, opts Options) (*Result, error) {
+	config, err := utils.GetProjectConfig(root)
+	if err != nil {
+		return nil, err
+	}
+
+	buildDir := filepath.Join(root, "build")
+	manifest, err := stage.LoadManifest(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("nothing has been built yet, run ompcli build first")
+	}
+	if !contains(manifest.Files, "omp-server") {
+		if contains(manifest.Files, "omp-server.exe") {
+			return nil, fmt.Errorf("the build is for Windows; containers need a Linux build (ompcli build --target-os linux)")
+		}
+		return nil, fmt.Errorf("the build has no omp-server; pin a server with ompcli server use and build again")
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port = 7777
+		if serverConfig, err := utils.GetServerConfig(root); err == nil && serverConfig.Port != 0 {
+			port = serverConfig.Port
+		}
+	}
+	baseImage := opts.BaseImage
+	if baseImage == "" {
+		baseImage = DefaultBaseImage
+	}
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Join(root, filepath.FromSlash(DefaultDir))
+	}
+
+	// Stage the server into the context, skipping files that did not change
+	st := stage.New(filepath.Join(outputDir, ServerDir), config.StagerOptions())
+	for _, file := range manifest.Files {
+		dest := file
+		if file == "config.json" {
+			dest = ConfigTemplate
+		}
+		if err := st.File(filepath.Join(buildDir, filepath.FromSlash(file)), dest); err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", file, err)
+		}
+	}
+	if err := st.Finish(); err != nil {
+		return nil, err
+	}
+
+	// Data folders outlive the container
+	var volumes []string
+	for _, dir := range clean.DataDirs {
+		volumes = append(volumes, path.Join(workDir, dir))
+	}
+
+	data := templateData{
+		Name:      config.Name,
+		Version:   config.Version,
+		BaseImage: baseImage,
+		Port:      port,
+		WorkDir:   workDir,
+		Volumes:   volumes,
+
+		ConfigTemplate: ConfigTemplate,
+	}
+	files := []struct {
+		name string
+		tmpl *template.Template
+		mode os.FileMode
+	}{
+		{"Dockerfile", dockerfileTemplate, 0644},
+		{"entrypoint.sh", entrypointTemplate, 0755},
+		{"healthcheck.sh", healthcheckTemplate, 0755},
+		{".dockerignore", dockerignoreTemplate, 0644},
+	}
+	for _, file := range files {
+		var buf bytes.Buffer
+		if err := file.tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, file.name), buf.Bytes(), file.mode); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		// The umask may have narrowed the mode
+		if err := os.Chmod(filepath.Join(outputDir, file.name), file.mode); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{Dir: outputDir, Port: port, Stats: st.Stats}, nil
+}
+
+// Tag returns the image tag suggested for a project: <name>:<version>
+func Tag(config *utils.ProjectConfig) string {
+	name := strings.ToLower(strings.Trim(tagChars.ReplaceAllString(config.Name, "-"), "-."))
+	if name == "" {
+		name = "omp-server"
+	}
+	version := strings.Trim(tagChars.ReplaceAllString(config.Version, "-"), "-.")
+	if version == "" {
+		version = "latest"
+	}
+	return name + ":" + version
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package container
+
+import (
+	"regexp"
+	"text/template"
+)
+
+// workDir is where the server lives inside the image
+const workDir = "/srv/omp"
+
+// tagChars are the characters not allowed in image tags
+var tagChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// templateData is what the build context templates are rendered with
+type templateData struct {
+	Name      string
+	Version   string
+	BaseImage string
+	Port      int
+	WorkDir   string
+	Volumes   []string
+
+	ConfigTemplate string
+}
+
+var dockerfileTemplate = template.Must(template.New("Dockerfile").Parse(`# Generated by ompcli container from the staged build of {{.Name}}.
+# Regenerate it after building instead of editing it by hand.
+FROM {{.BaseImage}}
+
+# The open.mp server is a 32-bit program
+RUN dpkg --add-architecture i386 \
+ && apt-get update \
+ && apt-get install -y --no-install-recommends libc6:i386 libstdc++6:i386 \
+ && rm -rf /var/lib/apt/lists/* \
+ && useradd --system --home-dir {{.WorkDir}} --shell /usr/sbin/nologin omp \
+ && mkdir -p {{.WorkDir}} \
+ && chown omp:omp {{.WorkDir}}
+
+WORKDIR {{.WorkDir}}
+COPY --chown=omp:omp server/ ./
+COPY entrypoint.sh healthcheck.sh /usr/local/bin/
+
+LABEL org.opencontainers.image.title="{{.Name}}" \
+      org.opencontainers.image.version="{{.Version}}"
+
+ENV OMP_PORT={{.Port}}
+EXPOSE {{.Port}}/udp
+{{- range .Volumes}}
+VOLUME ["{{.}}"]
+{{- end}}
+
+USER omp
+STOPSIGNAL SIGTERM
+HEALTHCHECK --interval=30s --timeout=5s --start-period=30s --retries=3 CMD ["healthcheck.sh"]
+ENTRYPOINT ["entrypoint.sh"]
+`))
+
+var entrypointTemplate = template.Must(template.New("entrypoint.sh").Parse(`#!/bin/bash
+# Generated by ompcli container.
+#
+# Renders config.json from config.json.tmpl, replacing ${VAR} and
+# ${VAR:-default} with environment variables, then runs the server and
+# forwards SIGTERM and SIGINT to it so that it can shut down cleanly.
+set -euo pipefail
+cd {{.WorkDir}}
+
+render() {
+	local text name value pattern='\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}'
+	text=$(<"$1")
+	while [[ $text =~ $pattern ]]; do
+		name=${BASH_REMATCH[1]}
+		value=${!name:-${BASH_REMATCH[3]}}
+		# Keep the result valid JSON inside strings
+		value=${value//\\/\\\\}
+		value=${value//\"/\\\"}
+		value=${value//\$/\\u0024}
+		text=${text//"${BASH_REMATCH[0]}"/"$value"}
+	done
+	printf '%s\n' "$text"
+}
+
+if [[ -f {{.ConfigTemplate}} ]]; then
+	render {{.ConfigTemplate}} > config.json
+fi
+
+./omp-server "$@" &
+pid=$!
+
+forward() {
+	kill -"$1" "$pid" 2>/dev/null || true
+}
+trap 'forward TERM' TERM
+trap 'forward INT' INT
+
+# wait returns early when a signal arrives; wait again for the exit status
+status=0
+wait "$pid" || status=$?
+if kill -0 "$pid" 2>/dev/null; then
+	wait "$pid" || status=$?
+fi
+exit "$status"
+`))
+
+var healthcheckTemplate = template.Must(template.New("healthcheck.sh").Parse(`#!/bin/bash
+# Generated by ompcli container.
+#
+# Sends an information query (opcode 'i') to the server over UDP and
+# succeeds when the server answers with the SA-MP query header.
+set -eu
+port=${OMP_PORT:-{{.Port}}}
+
+exec 3<>/dev/udp/127.0.0.1/"$port"
+printf "SAMP\177\000\000\001\\$(printf %03o $((port & 255)))\\$(printf %03o $((port >> 8)))i" >&3
+reply=$(timeout 3 head -c 4 <&3 2>/dev/null || true)
+exec 3<&-
+
+[[ $reply == SAMP ]]
+`))
+
+var dockerignoreTemplate = template.Must(template.New(".dockerignore").Parse(`# Generated by ompcli container.
+server/.ompcli-staged.json
+server/**/*.log
+server/log.txt
+server/crashinfo.txt
+server/logs
+`))
@@ -0,0 +1,315 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// Layout of a deploy directory
+const (
+	ReleasesDir = "releases"
+	SharedDir   = "shared"
+	CurrentLink = "current"
+)
+
+// releaseFormat names releases by the UTC time they were deployed at, so
+// that sorting their names sorts them by age
+const releaseFormat = "20060102150405"
+
+// Options controls a deploy or rollback
+type Options struct {
+	// Out receives progress and the output of hooks
+	Out io.Writer
+
+	// Release names the new release; empty uses the current time
+	Release string
+}
+
+// Release is a release found on a target
+type Release struct {
+	ID      string
+	Current bool
+}
+
+// Result describes a finished deploy or rollback
+type Result struct {
+	Release  string
+	Previous string
+	Pruned   []string
+}
+
+// Deploy uploads the package archive pkg to the target as a new release,
+// links the shared paths into it, switches current to it and removes the
+// oldest releases beyond the number to keep. The switch replaces the
+// current link in one rename, so the server never sees a half-deployed
+// release.
+func Deploy(target *utils.DeployTarget, pkg string, opts Options) (*Result, error) {
+	out := writer(opts.Out)
+	h := open(target)
+
+	// Hosts reached over ssh unpack the package with tar
+	if target.IsRemote() && archive.Format(pkg) != "tar.gz" {
+		return nil, fmt.Errorf("%s: only .tar.gz packages can be deployed to %s (package with --format tar.gz)", filepath.Base(pkg), target)
+	}
+
+	id := opts.Release
+	if id == "" {
+		id = time.Now().UTC().Format(releaseFormat)
+	}
+	releaseDir := path.Join(ReleasesDir, id)
+
+	if err := h.MkdirAll(ReleasesDir); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", ReleasesDir, err)
+	}
+	if exists, err := h.Exists(releaseDir); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("release %s already exists on %s", id, target)
+	}
+
+	previous, err := currentRelease(h)
+	if err != nil {
+		return nil, err
+	}
+	env := hookEnv(target, id, previous)
+
+	// Hooks before the upload may stop the server
+	for _, hook := range target.PreDeploy {
+		fmt.Fprintf(out, "Running %s\n", hook)
+		if err := h.Run("", env, hook, out); err != nil {
+			return nil, fmt.Errorf("pre_deploy hook %q failed: %w", hook, err)
+		}
+	}
+
+	// Upload into a temporary folder so that a failed upload leaves no release
+	fmt.Fprintf(out, "Uploading release %s to %s\n", id, target)
+	tmp := path.Join(ReleasesDir, "."+id+".tmp")
+	if err := h.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	if err := h.Extract(pkg, tmp); err != nil {
+		h.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to upload release: %w", err)
+	}
+	if err := linkShared(h, tmp, target.Shared); err != nil {
+		h.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := h.Rename(tmp, releaseDir); err != nil {
+		h.RemoveAll(tmp)
+		return nil, err
+	}
+
+	// Switch to the new release
+	if err := h.Symlink(releaseDir, CurrentLink); err != nil {
+		return nil, fmt.Errorf("failed to switch to release %s: %w", id, err)
+	}
+	fmt.Fprintf(out, "Switched %s to release %s\n", target, id)
+
+	result := &Result{Release: id, Previous: previous}
+	if err := runPostDeploy(h, target, env, out); err != nil {
+		return result, err
+	}
+
+	result.Pruned, err = prune(h, id, target.KeepReleases())
+	return result, err
+}
+
+// Rollback switches the target back to an earlier release: the named one,
+// or the one deployed before the current release
+func Rollback(target *utils.DeployTarget, release string, opts Options) (*Result, error) {
+	out := writer(opts.Out)
+	h := open(target)
+
+	releases, err := List(target)
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentRelease(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if release == "" {
+		for _, r := range releases {
+			if current != "" && r.ID >= current {
+				break
+			}
+			release = r.ID
+		}
+		if release == "" {
+			return nil, fmt.Errorf("there is no release before %s to roll back to", current)
+		}
+	} else if !hasRelease(releases, release) {
+		return nil, fmt.Errorf("release %s does not exist on %s", release, target)
+	}
+	if release == current {
+		return nil, fmt.Errorf("release %s is already current", release)
+	}
+
+	if err := h.Symlink(path.Join(ReleasesDir, release), CurrentLink); err != nil {
+		return nil, fmt.Errorf("failed to switch to release %s: %w", release, err)
+	}
+	fmt.Fprintf(out, "Switched %s back to release %s\n", target, release)
+
+	result := &Result{Release: release, Previous: current}
+	return result, runPostDeploy(h, target, hookEnv(target, release, current), out)
+}
+
+// List returns the releases on a target, oldest first
+func List(target *utils.DeployTarget) ([]Release, error) {
+	h := open(target)
+	names, err := h.List(ReleasesDir)
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentRelease(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		releases = append(releases, Release{ID: name, Current: name == current})
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].ID < releases[j].ID })
+	return releases, nil
+}
+
+// linkShared moves the shared paths of a new release to shared/, the first
+// time they are deployed, and links them back into the release
+func linkShared(h host, releaseDir string, shared []string) error {
+	for _, p := range shared {
+		p = path.Clean(strings.Trim(p, "/"))
+		sharedPath := path.Join(SharedDir, p)
+		releasePath := path.Join(releaseDir, p)
+
+		exists, err := h.Exists(sharedPath)
+		if err != nil {
+			return err
+		}
+		packaged, err := h.Exists(releasePath)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !exists && packaged:
+			// Seed shared/ with the packaged files
+			if err := h.MkdirAll(path.Dir(sharedPath)); err != nil {
+				return err
+			}
+			if err := h.Rename(releasePath, sharedPath); err != nil {
+				return err
+			}
+		case !exists:
+			if err := h.MkdirAll(sharedPath); err != nil {
+				return err
+			}
+		case packaged:
+			if err := h.RemoveAll(releasePath); err != nil {
+				return err
+			}
+		}
+
+		if err := h.MkdirAll(path.Dir(releasePath)); err != nil {
+			return err
+		}
+
+		// Link relative to the release so the deploy directory can move
+		depth := strings.Count(releasePath, "/")
+		link := strings.Repeat("../", depth) + sharedPath
+		if err := h.Symlink(link, releasePath); err != nil {
+			return fmt.Errorf("failed to link shared path %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// prune removes the oldest releases so that keep remain, never removing
+// the current one
+func prune(h host, current string, keep int) ([]string, error) {
+	names, err := h.List(ReleasesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, ".") {
+			releases = append(releases, name)
+		}
+	}
+	sort.Strings(releases)
+
+	var pruned []string
+	for i := 0; i < len(releases)-keep; i++ {
+		if releases[i] == current {
+			continue
+		}
+		if err := h.RemoveAll(path.Join(ReleasesDir, releases[i])); err != nil {
+			return pruned, fmt.Errorf("failed to remove release %s: %w", releases[i], err)
+		}
+		pruned = append(pruned, releases[i])
+	}
+	return pruned, nil
+}
+
+// runPostDeploy runs the post_deploy hooks in the current release
+func runPostDeploy(h host, target *utils.DeployTarget, env []string, out io.Writer) error {
+	for _, hook := range target.PostDeploy {
+		fmt.Fprintf(out, "Running %s\n", hook)
+		if err := h.Run(CurrentLink, env, hook, out); err != nil {
+			return fmt.Errorf("post_deploy hook %q failed: %w", hook, err)
+		}
+	}
+	return nil
+}
+
+// currentRelease returns the release current points at, or "" before the
+// first deploy
+func currentRelease(h host) (string, error) {
+	link, err := h.Readlink(CurrentLink)
+	if err != nil || link == "" {
+		return "", err
+	}
+	return path.Base(strings.TrimRight(link, "/")), nil
+}
+
+// hookEnv describes the deploy to hooks
+func hookEnv(target *utils.DeployTarget, release, previous string) []string {
+	return []string{
+		"OMPCLI_DEPLOY_PATH=" + target.Path,
+		"OMPCLI_RELEASE=" + release,
+		"OMPCLI_PREVIOUS_RELEASE=" + previous,
+	}
+}
+
+// hasRelease reports whether id is one of releases
+func hasRelease(releases []Release, id string) bool {
+	for _, r := range releases {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// writer returns w, or a writer that discards everything when w is nil
+func writer(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
@@ -0,0 +1,173 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// testPackage writes a package archive holding the given files below a
+// top folder, as ompcli package does
+func testPackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var entries []archive.Entry
+	for name, data := range files {
+		entries = append(entries, archive.Entry{Name: "gm-1.0.0/" + name, Data: []byte(data), Mode: 0644})
+	}
+	pkg := filepath.Join(t.TempDir(), "gm-1.0.0-linux.tar.gz")
+	if err := archive.Create(pkg, entries, time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+// readFile returns the contents of a file in the deploy directory
+func readFile(t *testing.T, target *utils.DeployTarget, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(target.Path, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// deploy deploys a package with a script of the given contents as release
+func deploy(t *testing.T, target *utils.DeployTarget, release, script string) *Result {
+	t.Helper()
+	pkg := testPackage(t, map[string]string{
+		"gamemodes/gm.amx":       script,
+		"scriptfiles/player.ini": "packaged",
+	})
+	result, err := Deploy(target, pkg, Options{Release: release})
+	if err != nil {
+		t.Fatalf("Deploy %s: %v", release, err)
+	}
+	return result
+}
+
+// current returns the release current points at
+func current(t *testing.T, target *utils.DeployTarget) string {
+	t.Helper()
+	release, err := currentRelease(open(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func TestDeployAndRollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("releases are switched with symlinks")
+	}
+	target := &utils.DeployTarget{Path: t.TempDir(), Keep: 2, Shared: []string{"scriptfiles"}}
+
+	// The first deploy seeds the shared folder with the packaged files
+	if result := deploy(t, target, "20240101000000", "v1"); result.Previous != "" {
+		t.Errorf("previous = %q, want none", result.Previous)
+	}
+	if got := current(t, target); got != "20240101000000" {
+		t.Fatalf("current = %s", got)
+	}
+	if got := readFile(t, target, "current/gamemodes/gm.amx"); got != "v1" {
+		t.Errorf("current script = %q", got)
+	}
+	if got := readFile(t, target, "shared/scriptfiles/player.ini"); got != "packaged" {
+		t.Errorf("shared file = %q", got)
+	}
+	if info, err := os.Lstat(filepath.Join(target.Path, "current", "scriptfiles")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("scriptfiles is not linked to shared/: %v", err)
+	}
+
+	// What the server writes survives the next deploy
+	if err := os.WriteFile(filepath.Join(target.Path, "current", "scriptfiles", "player.ini"), []byte("saved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := deploy(t, target, "20240102000000", "v2"); result.Previous != "20240101000000" || len(result.Pruned) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if got := readFile(t, target, "current/scriptfiles/player.ini"); got != "saved" {
+		t.Errorf("shared file after a deploy = %q, want saved", got)
+	}
+
+	// Only the newest two releases are kept
+	result := deploy(t, target, "20240103000000", "v3")
+	if want := []string{"20240101000000"}; !reflect.DeepEqual(result.Pruned, want) {
+		t.Errorf("pruned = %v, want %v", result.Pruned, want)
+	}
+	releases, err := List(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Release{{ID: "20240102000000"}, {ID: "20240103000000", Current: true}}
+	if !reflect.DeepEqual(releases, want) {
+		t.Errorf("releases = %+v, want %+v", releases, want)
+	}
+
+	// Rolling back goes to the release before the current one
+	result, err = Rollback(target, "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Release != "20240102000000" || result.Previous != "20240103000000" {
+		t.Errorf("rollback = %+v", result)
+	}
+	if got := readFile(t, target, "current/gamemodes/gm.amx"); got != "v2" {
+		t.Errorf("script after rollback = %q, want v2", got)
+	}
+	if _, err := Rollback(target, "", Options{}); err == nil {
+		t.Error("rolled back past the oldest release")
+	}
+	if _, err := Rollback(target, "20240101000000", Options{}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("rollback to a pruned release: %v", err)
+	}
+
+	if _, err := Deploy(target, testPackage(t, nil), Options{Release: "20240103000000"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("deploying an existing release: %v", err)
+	}
+}
+
+func TestDeployHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are POSIX shell commands here")
+	}
+	pkg := testPackage(t, map[string]string{"gamemodes/gm.amx": "v1"})
+
+	// A failing pre_deploy hook stops the deploy before anything changes
+	target := &utils.DeployTarget{Path: t.TempDir(), PreDeploy: []string{"exit 3"}}
+	if _, err := Deploy(target, pkg, Options{Release: "20240101000000"}); err == nil || !strings.Contains(err.Error(), `pre_deploy hook "exit 3" failed`) {
+		t.Fatalf("Deploy error = %v", err)
+	}
+	if releases, _ := List(target); len(releases) != 0 || current(t, target) != "" {
+		t.Errorf("releases after a failed hook = %+v", releases)
+	}
+
+	// post_deploy hooks run in the new release, which stays current when
+	// they fail
+	target.PreDeploy = nil
+	target.PostDeploy = []string{`echo "$OMPCLI_RELEASE" > hook.txt`, "false"}
+	result, err := Deploy(target, pkg, Options{Release: "20240101000000"})
+	if err == nil || !strings.Contains(err.Error(), `post_deploy hook "false" failed`) {
+		t.Fatalf("Deploy error = %v", err)
+	}
+	if result == nil || result.Release != "20240101000000" || current(t, target) != "20240101000000" {
+		t.Errorf("result = %+v, current = %s", result, current(t, target))
+	}
+	if got := readFile(t, target, "current/hook.txt"); got != "20240101000000\n" {
+		t.Errorf("hook wrote %q", got)
+	}
+}
+
+func TestDeployZipOverSSH(t *testing.T) {
+	target := &utils.DeployTarget{Host: "omp@example.com", Path: "/srv/omp"}
+	_, err := Deploy(target, "dist/gm-1.0.0-linux.zip", Options{})
+	if err == nil || !strings.Contains(err.Error(), "only .tar.gz packages") {
+		t.Fatalf("Deploy error = %v", err)
+	}
+}
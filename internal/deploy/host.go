@@ -0,0 +1,39 @@
+package deploy
+
+import (
+	"io"
+
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// host performs file operations in a deploy directory. Paths are
+// slash-separated and relative to the deploy directory.
+type host interface {
+	// Run runs a shell command in dir with extra environment variables
+	Run(dir string, env []string, command string, out io.Writer) error
+
+	// Extract unpacks a package archive into dir, dropping its top folder
+	Extract(archive, dir string) error
+
+	// List returns the names of the entries of dir; a missing dir has none
+	List(dir string) ([]string, error)
+
+	// Readlink returns the target of a symlink, or "" when it is missing
+	Readlink(link string) (string, error)
+
+	// Symlink points link at target, atomically replacing an existing link
+	Symlink(target, link string) error
+
+	Exists(p string) (bool, error)
+	MkdirAll(dir string) error
+	RemoveAll(p string) error
+	Rename(from, to string) error
+}
+
+// open returns the host of a deploy target
+func open(target *utils.DeployTarget) host {
+	if target.IsRemote() {
+		return &sshHost{target: target}
+	}
+	return &localHost{root: target.Path}
+}
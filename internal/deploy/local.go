@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+)
+
+// localHost deploys to a directory on this machine
+type localHost struct {
+	root string
+}
+
+func (h *localHost) abs(p string) string {
+	return filepath.Join(h.root, filepath.FromSlash(p))
+}
+
+func (h *localHost) Run(dir string, env []string, command string, out io.Writer) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = h.abs(dir)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+func (h *localHost) Extract(path, dir string) error {
+	target := h.abs(dir)
+	if err := archive.Extract(path, target); err != nil {
+		return err
+	}
+	return archive.StripSingleRoot(target)
+}
+
+func (h *localHost) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(h.abs(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+func (h *localHost) Readlink(link string) (string, error) {
+	target, err := os.Readlink(h.abs(link))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return filepath.ToSlash(target), err
+}
+
+func (h *localHost) Symlink(target, link string) error {
+	tmp := h.abs(link) + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.FromSlash(target), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.abs(link)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (h *localHost) Exists(p string) (bool, error) {
+	_, err := os.Lstat(h.abs(p))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *localHost) MkdirAll(dir string) error {
+	return os.MkdirAll(h.abs(dir), 0755)
+}
+
+func (h *localHost) RemoveAll(p string) error {
+	return os.RemoveAll(h.abs(p))
+}
+
+func (h *localHost) Rename(from, to string) error {
+	return os.Rename(h.abs(from), h.abs(to))
+}
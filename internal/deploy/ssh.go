@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// sshHost deploys to a directory on another machine. It runs POSIX shell
+// commands through the ssh client, so keys and known hosts are set up as
+// for any other ssh login; ssh never prompts for a password.
+type sshHost struct {
+	target *utils.DeployTarget
+}
+
+func (h *sshHost) abs(p string) string {
+	return path.Join(h.target.Path, p)
+}
+
+// command returns an ssh command running script on the host
+func (h *sshHost) command(script string) *exec.Cmd {
+	args := []string{"-o", "BatchMode=yes"}
+	if h.target.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.target.Port))
+	}
+	if h.target.Identity != "" {
+		args = append(args, "-i", h.target.Identity)
+	}
+	args = append(args, h.target.Host, "--", "sh -c "+quote(script))
+	return exec.Command("ssh", args...)
+}
+
+// output runs script and returns what it printed
+func (h *sshHost) output(script string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := h.command(script)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", h.target.Host, msg)
+		}
+		return "", fmt.Errorf("%s: %w", h.target.Host, err)
+	}
+	return stdout.String(), nil
+}
+
+func (h *sshHost) Run(dir string, env []string, command string, out io.Writer) error {
+	script := "cd " + quote(h.abs(dir))
+	for _, v := range env {
+		name, value, _ := strings.Cut(v, "=")
+		script += " && export " + name + "=" + quote(value)
+	}
+	script += " && " + command
+
+	cmd := h.command(script)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+func (h *sshHost) Extract(archive, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	target := quote(h.abs(dir))
+	var stderr bytes.Buffer
+	cmd := h.command("mkdir -p " + target + " && tar -xzf - -C " + target + " --strip-components=1")
+	cmd.Stdin = file
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", h.target.Host, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *sshHost) List(dir string) ([]string, error) {
+	out, err := h.output("ls -1A " + quote(h.abs(dir)) + " 2>/dev/null || true")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+func (h *sshHost) Readlink(link string) (string, error) {
+	out, err := h.output("readlink " + quote(h.abs(link)) + " || true")
+	return strings.TrimSpace(out), err
+}
+
+func (h *sshHost) Symlink(target, link string) error {
+	tmp := quote(h.abs(link) + ".tmp")
+	_, err := h.output("ln -sfn " + quote(target) + " " + tmp + " && mv -Tf " + tmp + " " + quote(h.abs(link)))
+	return err
+}
+
+func (h *sshHost) Exists(p string) (bool, error) {
+	target := quote(h.abs(p))
+	out, err := h.output("if [ -e " + target + " ] || [ -L " + target + " ]; then echo yes; fi")
+	return strings.TrimSpace(out) == "yes", err
+}
+
+func (h *sshHost) MkdirAll(dir string) error {
+	_, err := h.output("mkdir -p " + quote(h.abs(dir)))
+	return err
+}
+
+func (h *sshHost) RemoveAll(p string) error {
+	_, err := h.output("rm -rf " + quote(h.abs(p)))
+	return err
+}
+
+func (h *sshHost) Rename(from, to string) error {
+	_, err := h.output("mv " + quote(h.abs(from)) + " " + quote(h.abs(to)))
+	return err
+}
+
+// quote quotes s for a POSIX shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,998 @@
+package deps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+	"github.com/weltschmerzie/omp-cli/internal/configedit"
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/semver"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+const (
+	// LockFile records the resolved dependencies of a project
+	LockFile = "ompcli.lock"
+
+	// Dir is where dependencies are unpacked, relative to the project root
+	Dir = "dependencies"
+
+	// CacheDir keeps downloaded archives, relative to the project root
+	CacheDir = ".ompcli/cache/deps"
+
+	// VendorDir holds vendored package archives laid out as a registry,
+	// relative to the project root
+	VendorDir = "vendor"
+
+	// ManifestFile describes the contents of a package archive
+	ManifestFile = "pawn.json"
+
+	// RegistryEnv overrides the registry configured in project.json
+	RegistryEnv = "OMPCLI_REGISTRY"
+
+	// markerFile remembers which release is unpacked in a dependency folder
+	markerFile = ".ompcli-package"
+
+	lockfileVersion = 1
+)
+
+// Manifest represents the structure of pawn.json inside a package archive
+type Manifest struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"version"`
+	IncludePaths []string            `json:"include_paths"`
+	Plugins      map[string][]string `json:"plugins"`
+	Components   map[string][]string `json:"components"`
+}
+
+// Lock represents the structure of ompcli.lock
+type Lock struct {
+	LockfileVersion int                       `json:"lockfile_version"`
+	Packages        map[string]*LockedPackage `json:"packages"`
+}
+
+// LockedPackage is a resolved dependency as recorded in the lockfile
+type LockedPackage struct {
+	Version      string              `json:"version"`
+	Source       string              `json:"source"`
+	Archive      string              `json:"archive"`
+	SHA256       string              `json:"sha256"`
+	IncludePaths []string            `json:"include_paths"`
+	Plugins      map[string][]string `json:"plugins,omitempty"`
+	Components   map[string][]string `json:"components,omitempty"`
+	Dependencies map[string]string   `json:"dependencies,omitempty"`
+}
+
+// Release returns the registry release a locked package was installed from
+func (p *LockedPackage) Release(name string) Release {
+	return Release{
+		Name:         name,
+		Version:      p.Version,
+		Archive:      p.Archive,
+		SHA256:       p.SHA256,
+		Dependencies: p.Dependencies,
+	}
+}
+
+// InstallOptions controls how Install resolves versions
+type InstallOptions struct {
+	// Update lists packages that may move to newer versions than the
+	// lockfile records; UpdateAll applies this to every package
+	Update    []string
+	UpdateAll bool
+
+	// Frozen fails instead of changing an out-of-date lockfile
+	Frozen bool
+}
+
+// Manager installs the dependencies of a single project
+type Manager struct {
+	Root     string
+	Config   *utils.ProjectConfig
+	Registry Registry
+	Offline  bool
+	Stdout   io.Writer
+
+	releases map[string][]Release
+
+	// edits are the changes Add and Remove made to the dependencies, saved
+	// to project.json once Install succeeds
+	edits []edit
+}
+
+// edit is a pending change to the dependencies of project.json
+type edit struct {
+	apply   func(deps *jsonedit.Value)
+	message string
+}
+
+// NewManager loads the project rooted at root. Packages are looked up, in
+// order, in the project's vendor folder, the user's registry mirror and
+// the registry from the OMPCLI_REGISTRY environment variable or the
+// "registry" key of project.json. In offline mode only local directories
+// are used. Registry is nil when none of these are available.
+func NewManager(root string) (*Manager, error) {
+	config, err := utils.GetProjectConfig(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project configuration: %w", err)
+	}
+
+	user, err := userconfig.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{Root: root, Config: config, Offline: user.Offline, Stdout: os.Stdout}
+
+	var registries Chain
+	vendorDir := filepath.Join(root, VendorDir)
+	if info, err := os.Stat(vendorDir); err == nil && info.IsDir() {
+		registries = append(registries, &FSRegistry{Dir: vendorDir})
+	}
+
+	locations := []string{user.RegistryMirror, os.Getenv(RegistryEnv)}
+	if locations[1] == "" {
+		locations[1] = config.Registry
+	}
+	for _, location := range locations {
+		if location == "" {
+			continue
+		}
+		registry, err := OpenRegistry(location, root)
+		if err != nil {
+			return nil, err
+		}
+		if m.Offline && !Local(registry) {
+			continue
+		}
+		registries = append(registries, registry)
+	}
+
+	if len(registries) > 0 {
+		m.Registry = registries
+	}
+	return m, nil
+}
+
+// Install resolves the project's dependencies, unpacks them into the
+// dependencies folder and writes the lockfile. Dependencies added or
+// removed before are saved to project.json only when it succeeds.
+func (m *Manager) Install(opts InstallOptions) (*Lock, error) {
+	lock, err := LoadLock(m.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected map[string]Release
+	upToDate := lock != nil && lock.satisfies(m.Config.Dependencies)
+	switch {
+	case upToDate && !opts.UpdateAll && len(opts.Update) == 0:
+		// The lockfile already pins everything; no registry lookups needed
+		selected = map[string]Release{}
+		for name, pkg := range lock.Packages {
+			selected[name] = pkg.Release(name)
+		}
+	case opts.Frozen:
+		return nil, fmt.Errorf("%s is out of date with project.json (run 'ompcli install' without --frozen)", LockFile)
+	default:
+		update := map[string]bool{}
+		for _, name := range opts.Update {
+			update[name] = true
+		}
+		keep := func(name string) bool { return !opts.UpdateAll && !update[name] }
+
+		if selected, err = m.resolve(lock, keep); err != nil {
+			return nil, err
+		}
+	}
+
+	newLock := &Lock{LockfileVersion: lockfileVersion, Packages: map[string]*LockedPackage{}}
+	for _, name := range sortedKeys(selected) {
+		pkg, err := m.install(selected[name], lock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s@%s: %w", name, selected[name].Version, err)
+		}
+		newLock.Packages[name] = pkg
+	}
+
+	if err := m.saveEdits(); err != nil {
+		return nil, err
+	}
+	if err := newLock.Save(m.Root); err != nil {
+		return nil, err
+	}
+	if err := m.prune(newLock); err != nil {
+		return nil, err
+	}
+	return newLock, nil
+}
+
+// Add declares a dependency. Without a constraint the newest release is
+// looked up and pinned with a caret constraint. project.json is changed by
+// the next successful Install.
+func (m *Manager) Add(name, constraint string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if constraint == "" {
+		release, err := m.pick(name, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		constraint = "^" + release.Version
+	} else if _, err := semver.ParseConstraint(constraint); err != nil {
+		return err
+	}
+
+	m.edits = append(m.edits, edit{
+		apply: func(deps *jsonedit.Value) {
+			for _, f := range deps.Fields {
+				if f.Key == name {
+					f.Value = jsonedit.NewString(constraint)
+					return
+				}
+			}
+			deps.Fields = append(deps.Fields, &jsonedit.Field{Key: name, Value: jsonedit.NewString(constraint)})
+		},
+		message: fmt.Sprintf("Added %s %s", name, constraint),
+	})
+
+	if m.Config.Dependencies == nil {
+		m.Config.Dependencies = map[string]string{}
+	}
+	m.Config.Dependencies[name] = constraint
+	return nil
+}
+
+// Remove drops a dependency. project.json is changed by the next
+// successful Install.
+func (m *Manager) Remove(name string) error {
+	if _, ok := m.Config.Dependencies[name]; !ok {
+		return fmt.Errorf("%s is not a dependency of this project", name)
+	}
+
+	m.edits = append(m.edits, edit{
+		apply: func(deps *jsonedit.Value) {
+			for i, f := range deps.Fields {
+				if f.Key == name {
+					deps.Fields = append(deps.Fields[:i], deps.Fields[i+1:]...)
+					return
+				}
+			}
+		},
+		message: "Removed " + name,
+	})
+
+	delete(m.Config.Dependencies, name)
+	return nil
+}
+
+// saveEdits applies the pending edits to the "dependencies" object of
+// project.json and saves the file with its formatting intact
+func (m *Manager) saveEdits() error {
+	if len(m.edits) == 0 {
+		return nil
+	}
+
+	file, err := configedit.Open(m.Root, configedit.TargetProject)
+	if err != nil {
+		return err
+	}
+
+	path := jsonedit.Path{{Key: "dependencies"}}
+	deps, err := file.Get(path)
+	if errors.Is(err, jsonedit.ErrNotFound) {
+		deps = jsonedit.NewObject()
+		if err := file.Doc.Set(path, deps); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if deps.Kind != jsonedit.Object {
+		return fmt.Errorf("dependencies in project.json must be an object")
+	}
+
+	for _, e := range m.edits {
+		e.apply(deps)
+	}
+	if len(deps.Fields) == 0 {
+		_ = file.Unset(path)
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+
+	for _, warning := range file.Warnings {
+		fmt.Fprintf(m.Stdout, "Warning: %s\n", warning)
+	}
+	for _, e := range m.edits {
+		fmt.Fprintln(m.Stdout, e.message)
+	}
+	m.edits = nil
+	return nil
+}
+
+// resolve picks a release for every direct and indirect dependency.
+// Packages for which keep returns true stay at their locked version
+// whenever it still satisfies all constraints.
+func (m *Manager) resolve(lock *Lock, keep func(string) bool) (map[string]Release, error) {
+	selected := map[string]Release{}
+
+	// Iterate until the selection no longer changes: picking a release can
+	// add constraints on other packages through its own dependencies
+	for iteration := 0; iteration < 100; iteration++ {
+		requirements := map[string][]requirement{}
+		for name, constraint := range m.Config.Dependencies {
+			if err := ValidateName(name); err != nil {
+				return nil, fmt.Errorf("project.json: %w", err)
+			}
+			requirements[name] = append(requirements[name], requirement{"project.json", constraint})
+		}
+		for _, release := range selected {
+			from := release.Name + "@" + release.Version
+			for name, constraint := range release.Dependencies {
+				if err := ValidateName(name); err != nil {
+					return nil, fmt.Errorf("%s: %w", from, err)
+				}
+				requirements[name] = append(requirements[name], requirement{from, constraint})
+			}
+		}
+
+		changed := false
+		for name := range selected {
+			if _, ok := requirements[name]; !ok {
+				delete(selected, name)
+				changed = true
+			}
+		}
+
+		for _, name := range sortedKeys(requirements) {
+			release, err := m.pick(name, requirements[name], lock, keep)
+			if err != nil {
+				return nil, err
+			}
+			if current, ok := selected[name]; !ok || current.Version != release.Version {
+				selected[name] = release
+				changed = true
+			}
+		}
+
+		if !changed {
+			return selected, nil
+		}
+	}
+
+	return nil, errors.New("dependency resolution did not settle; check for conflicting constraints")
+}
+
+// requirement is a constraint on a package and who imposed it
+type requirement struct {
+	from       string
+	constraint string
+}
+
+// pick returns the best release of name satisfying all requirements
+func (m *Manager) pick(name string, requirements []requirement, lock *Lock, keep func(string) bool) (Release, error) {
+	releases, err := m.releasesOf(name)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var constraints []semver.Constraint
+	for _, req := range requirements {
+		c, err := semver.ParseConstraint(req.constraint)
+		if err != nil {
+			return Release{}, fmt.Errorf("%s (required by %s): %w", name, req.from, err)
+		}
+		constraints = append(constraints, c)
+	}
+
+	var best *Release
+	var bestVersion semver.Version
+	for i, release := range releases {
+		v, err := semver.Parse(release.Version)
+		if err != nil {
+			continue
+		}
+		if !satisfiesAll(constraints, v) {
+			continue
+		}
+		// Without constraints, as when adding a package, only releases
+		// count, as they do for "*"
+		if len(constraints) == 0 && v.Prerelease != "" {
+			continue
+		}
+
+		// Prefer the locked version while it remains acceptable
+		if lock != nil && keep != nil && keep(name) {
+			if locked, ok := lock.Packages[name]; ok && locked.Version == release.Version {
+				return release, nil
+			}
+		}
+
+		if best == nil || v.Compare(bestVersion) > 0 {
+			best, bestVersion = &releases[i], v
+		}
+	}
+
+	if best == nil {
+		var wanted []string
+		for _, req := range requirements {
+			wanted = append(wanted, fmt.Sprintf("%s from %s", req.constraint, req.from))
+		}
+		if len(wanted) == 0 {
+			return Release{}, fmt.Errorf("%s has no stable releases in %s", name, m.Registry)
+		}
+		return Release{}, fmt.Errorf("no release of %s satisfies %s", name, strings.Join(wanted, ", "))
+	}
+	return *best, nil
+}
+
+// releasesOf returns the releases of name, asking the registry only once
+func (m *Manager) releasesOf(name string) ([]Release, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if m.Registry == nil {
+		return nil, m.noRegistry()
+	}
+	if releases, ok := m.releases[name]; ok {
+		return releases, nil
+	}
+
+	releases, err := m.Registry.Releases(name)
+	if err != nil {
+		return nil, err
+	}
+	if m.releases == nil {
+		m.releases = map[string][]Release{}
+	}
+	m.releases[name] = releases
+	return releases, nil
+}
+
+// install makes sure a release is unpacked and returns its lock entry
+func (m *Manager) install(release Release, oldLock *Lock) (*LockedPackage, error) {
+	// A lockfile entry for the same version pins the checksum
+	if oldLock != nil {
+		if locked, ok := oldLock.Packages[release.Name]; ok && locked.Version == release.Version {
+			if release.SHA256 != "" && !strings.EqualFold(release.SHA256, locked.SHA256) {
+				return nil, fmt.Errorf("registry checksum %s does not match %s checksum %s", release.SHA256, LockFile, locked.SHA256)
+			}
+			release.SHA256 = locked.SHA256
+		}
+	}
+
+	dest, err := within(filepath.Join(m.Root, Dir), release.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !installed(dest, release) {
+		archivePath, sum, err := m.download(release)
+		if err != nil {
+			return nil, err
+		}
+		release.SHA256 = sum
+
+		fmt.Fprintf(m.Stdout, "Installing %s@%s\n", release.Name, release.Version)
+		if err := unpack(archivePath, dest, release); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := readManifest(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	source := ""
+	if release.registry != nil {
+		source = release.registry.String()
+	} else if oldLock != nil {
+		if locked, ok := oldLock.Packages[release.Name]; ok && locked.Version == release.Version {
+			source = locked.Source
+		}
+	}
+
+	return &LockedPackage{
+		Version:      release.Version,
+		Source:       source,
+		Archive:      release.Archive,
+		SHA256:       release.SHA256,
+		IncludePaths: manifest.IncludePaths,
+		Plugins:      manifest.Plugins,
+		Components:   manifest.Components,
+		Dependencies: release.Dependencies,
+	}, nil
+}
+
+// download fetches a release archive into the cache, verifying its
+// checksum, and returns the cached path and the archive's SHA-256
+func (m *Manager) download(release Release) (string, string, error) {
+	format := archive.Format(release.Archive)
+	if format == "" {
+		return "", "", fmt.Errorf("unsupported archive %s", release.Archive)
+	}
+
+	cacheDir := filepath.Join(m.Root, CacheDir)
+	cached, err := within(cacheDir, release.Name+"-"+release.Version+"."+format)
+	if err != nil {
+		return "", "", err
+	}
+	if sum, err := FileSHA256(cached); err == nil && (release.SHA256 == "" || strings.EqualFold(sum, release.SHA256)) {
+		return cached, sum, nil
+	}
+
+	if m.Registry == nil {
+		return "", "", m.noRegistry()
+	}
+	src, err := m.Registry.Open(release)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to download %s: %w", release.Archive, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(cacheDir, ".download-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), src); err != nil {
+		tmp.Close()
+		return "", "", fmt.Errorf("failed to download %s: %w", release.Archive, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if release.SHA256 != "" && !strings.EqualFold(sum, release.SHA256) {
+		return "", "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", release.Archive, release.SHA256, sum)
+	}
+
+	if err := os.Rename(tmp.Name(), cached); err != nil {
+		return "", "", err
+	}
+	return cached, sum, nil
+}
+
+// noRegistry explains why no package source is available
+func (m *Manager) noRegistry() error {
+	if m.Offline {
+		return fmt.Errorf("offline mode: no vendor folder or local registry mirror available (run 'ompcli vendor' or set registry_mirror in the user configuration)")
+	}
+	return fmt.Errorf("no package registry configured (set \"registry\" in project.json or %s)", RegistryEnv)
+}
+
+// Vendor copies the archives of all locked packages into dir, laid out as
+// a registry, after verifying them against the checksums in the lockfile.
+// With prune, packages in dir that are no longer locked are removed;
+// otherwise new versions are added next to the ones already there, so
+// dir can serve as a shared mirror.
+func (m *Manager) Vendor(dir string, prune bool) (*Lock, error) {
+	lock, err := LoadLock(m.Root)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil || !lock.satisfies(m.Config.Dependencies) {
+		return nil, fmt.Errorf("%s is missing or out of date (run 'ompcli install' first)", LockFile)
+	}
+
+	for _, name := range sortedKeys(lock.Packages) {
+		pkg := lock.Packages[name]
+		release := pkg.Release(name)
+
+		archivePath, sum, err := m.download(release)
+		if err != nil {
+			return nil, fmt.Errorf("failed to vendor %s@%s: %w", name, pkg.Version, err)
+		}
+		if !strings.EqualFold(sum, pkg.SHA256) {
+			return nil, fmt.Errorf("checksum mismatch for %s@%s: %s records %s, archive has %s", name, pkg.Version, LockFile, pkg.SHA256, sum)
+		}
+
+		pkgDir, err := within(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			return nil, err
+		}
+
+		dest := filepath.Join(pkgDir, ArchiveName(release))
+		if existing, err := FileSHA256(dest); err != nil || !strings.EqualFold(existing, sum) {
+			fmt.Fprintf(m.Stdout, "Vendoring %s@%s\n", name, pkg.Version)
+			if err := copyFile(archivePath, dest); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := writeIndex(pkgDir, name, Release{
+			Version:      pkg.Version,
+			Archive:      ArchiveName(release),
+			SHA256:       sum,
+			Dependencies: pkg.Dependencies,
+		}, !prune); err != nil {
+			return nil, err
+		}
+	}
+
+	if prune {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if _, ok := lock.Packages[entry.Name()]; ok || !entry.IsDir() {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, entry.Name(), IndexFile)); err != nil {
+				continue
+			}
+			fmt.Fprintf(m.Stdout, "Removing %s\n", entry.Name())
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return lock, nil
+}
+
+// writeIndex writes an index.json listing release. With merge, the
+// versions already listed in an existing index are kept.
+func writeIndex(dir, name string, release Release, merge bool) error {
+	index := Index{Name: name}
+	if merge {
+		if data, err := os.ReadFile(filepath.Join(dir, IndexFile)); err == nil {
+			if err := json.Unmarshal(data, &index); err != nil {
+				return fmt.Errorf("failed to parse index of %s: %w", name, err)
+			}
+		}
+	}
+
+	replaced := false
+	for i, existing := range index.Versions {
+		if existing.Version == release.Version {
+			index.Versions[i] = release
+			replaced = true
+		}
+	}
+	if !replaced {
+		index.Versions = append(index.Versions, release)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, IndexFile), append(data, '\n'), 0644)
+}
+
+// copyFile copies src to dst through a temporary file
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// unpack replaces dest with the contents of an archive
+func unpack(archivePath, dest string, release Release) error {
+	tmp := dest + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := archive.Extract(archivePath, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if entries, err := os.ReadDir(tmp); err == nil && len(entries) == 1 && !layoutDirs[entries[0].Name()] {
+		// Archives of a release folder ("streamer-2.9.6/...") are unwrapped
+		if err := archive.StripSingleRoot(tmp); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+
+	marker := release.Version + " " + release.SHA256 + "\n"
+	if err := os.WriteFile(filepath.Join(tmp, markerFile), []byte(marker), 0644); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// ValidateName checks that a package name can serve as a folder and file
+// name: names must not be empty, hold slashes or start with a dot
+func ValidateName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid package name %q", name)
+	}
+	return nil
+}
+
+// within returns dir joined with name, refusing names that leave dir
+func within(dir, name string) (string, error) {
+	p := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s is outside of %s", p, dir)
+	}
+	return p, nil
+}
+
+// layoutDirs are folders a package may consist of on its own, which must
+// not be mistaken for a wrapping release folder
+var layoutDirs = map[string]bool{"include": true, "plugins": true, "components": true}
+
+// installed reports whether dest already holds the given release
+func installed(dest string, release Release) bool {
+	data, err := os.ReadFile(filepath.Join(dest, markerFile))
+	if err != nil {
+		return false
+	}
+	version, sum, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return version == release.Version && (release.SHA256 == "" || strings.EqualFold(sum, release.SHA256))
+}
+
+// prune removes unpacked packages that are no longer locked
+func (m *Manager) prune(lock *Lock) error {
+	entries, err := os.ReadDir(filepath.Join(m.Root, Dir))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if _, ok := lock.Packages[entry.Name()]; ok || !entry.IsDir() {
+			continue
+		}
+		// Only remove folders that were unpacked by ompcli
+		dir := filepath.Join(m.Root, Dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, markerFile)); err != nil {
+			continue
+		}
+		fmt.Fprintf(m.Stdout, "Removing %s\n", entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readManifest reads pawn.json from an unpacked package. Packages without
+// one expose their include/ folder, or their root when there is none.
+func readManifest(dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(manifest.IncludePaths) == 0 {
+		if info, err := os.Stat(filepath.Join(dir, "include")); err == nil && info.IsDir() {
+			manifest.IncludePaths = []string{"include"}
+		} else {
+			manifest.IncludePaths = []string{"."}
+		}
+	}
+	return manifest, nil
+}
+
+// LoadLock reads the lockfile of the project rooted at root. It returns
+// nil without an error when the project has no lockfile.
+func LoadLock(root string) (*Lock, error) {
+	data, err := os.ReadFile(filepath.Join(root, LockFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LockFile, err)
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+	if lock.LockfileVersion > lockfileVersion {
+		return nil, fmt.Errorf("%s was written by a newer ompcli (lockfile version %d)", LockFile, lock.LockfileVersion)
+	}
+	if lock.Packages == nil {
+		lock.Packages = map[string]*LockedPackage{}
+	}
+	for name := range lock.Packages {
+		if err := ValidateName(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", LockFile, err)
+		}
+	}
+	return &lock, nil
+}
+
+// Save writes the lockfile to the project rooted at root
+func (l *Lock) Save(root string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, LockFile), append(data, '\n'), 0644)
+}
+
+// satisfies reports whether the lockfile pins a matching version for every
+// declared dependency and for every dependency of the locked packages,
+// and holds nothing else
+func (l *Lock) satisfies(declared map[string]string) bool {
+	required := map[string][]string{}
+	for name, constraint := range declared {
+		required[name] = append(required[name], constraint)
+	}
+	for _, pkg := range l.Packages {
+		for name, constraint := range pkg.Dependencies {
+			required[name] = append(required[name], constraint)
+		}
+	}
+	if len(required) != len(l.Packages) {
+		return false
+	}
+
+	for name, constraints := range required {
+		pkg, ok := l.Packages[name]
+		if !ok {
+			return false
+		}
+		v, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return false
+		}
+		for _, text := range constraints {
+			c, err := semver.ParseConstraint(text)
+			if err != nil || !c.Check(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IncludePaths returns the absolute include directories of the installed
+// dependencies of the project rooted at root
+func IncludePaths(root string) ([]string, error) {
+	lock, err := LoadLock(root)
+	if err != nil || lock == nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, name := range sortedKeys(lock.Packages) {
+		for _, p := range lock.Packages[name].IncludePaths {
+			paths = append(paths, filepath.Join(root, Dir, name, filepath.FromSlash(p)))
+		}
+	}
+	return paths, nil
+}
+
+// PluginFiles returns the absolute paths of the legacy plugin binaries the
+// installed dependencies provide for the given operating system
+func PluginFiles(root, goos string) ([]string, error) {
+	return packageFiles(root, func(p *LockedPackage) []string { return p.Plugins[goos] })
+}
+
+// ComponentFiles returns the absolute paths of the open.mp components the
+// installed dependencies provide for the given operating system
+func ComponentFiles(root, goos string) ([]string, error) {
+	return packageFiles(root, func(p *LockedPackage) []string { return p.Components[goos] })
+}
+
+// packageFiles collects files of the installed dependencies
+func packageFiles(root string, files func(*LockedPackage) []string) ([]string, error) {
+	lock, err := LoadLock(root)
+	if err != nil || lock == nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, name := range sortedKeys(lock.Packages) {
+		for _, p := range files(lock.Packages[name]) {
+			paths = append(paths, filepath.Join(root, Dir, name, filepath.FromSlash(p)))
+		}
+	}
+	return paths, nil
+}
+
+// Check reports an error when a project declares dependencies that are
+// not installed, so the build can fail with a useful hint
+func Check(root string, config *utils.ProjectConfig) error {
+	if len(config.Dependencies) == 0 {
+		return nil
+	}
+
+	lock, err := LoadLock(root)
+	if err != nil {
+		return err
+	}
+	if lock == nil || !lock.satisfies(config.Dependencies) {
+		return fmt.Errorf("dependencies are not installed or out of date (run 'ompcli install')")
+	}
+	for name, pkg := range lock.Packages {
+		if !installed(filepath.Join(root, Dir, name), pkg.Release(name)) {
+			return fmt.Errorf("dependency %s is not installed (run 'ompcli install')", name)
+		}
+	}
+	return nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// satisfiesAll reports whether v satisfies every constraint
+func satisfiesAll(constraints []semver.Constraint, v semver.Version) bool {
+	for _, c := range constraints {
+		if !c.Check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,301 @@
+package deps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// writeArchive writes a .tar.gz archive holding files, given as pairs of
+// names and contents
+func writeArchive(t *testing.T, path string, files ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for i := 0; i < len(files); i += 2 {
+		header := &tar.Header{Name: files[i], Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(files[i+1]))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testRegistry writes a local registry holding the given versions of a
+// package, each with a pawn.json and an include
+func testRegistry(t *testing.T, name string, versions ...string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "registry")
+	pkgDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	index := Index{Name: name}
+	for _, version := range versions {
+		manifest, _ := json.Marshal(Manifest{Name: name, Version: version, IncludePaths: []string{"include"}})
+		file := name + "-" + version + ".tar.gz"
+		writeArchive(t, filepath.Join(pkgDir, file),
+			ManifestFile, string(manifest),
+			"include/"+name+".inc", "native "+name+"_version();\n")
+		index.Versions = append(index.Versions, Release{Version: version, Archive: file})
+	}
+
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(pkgDir, IndexFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// testManager returns a manager for a new project using registry
+func testManager(t *testing.T, registry string) *Manager {
+	t.Helper()
+	root := t.TempDir()
+	project := `{
+  "name": "gm",
+  "main_file": "gamemodes/gm.pwn",
+  "output_file": "gamemodes/gm.amx"
+}
+`
+	if err := os.WriteFile(filepath.Join(root, utils.ProjectFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return loadManager(t, root, registry)
+}
+
+// loadManager returns a manager for the project at root
+func loadManager(t *testing.T, root, registry string) *Manager {
+	t.Helper()
+	config, err := utils.GetProjectConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{Root: root, Config: config, Stdout: io.Discard}
+	if registry != "" {
+		m.Registry = &FSRegistry{Dir: registry}
+	}
+	return m
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLockRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	lock := &Lock{
+		LockfileVersion: lockfileVersion,
+		Packages: map[string]*LockedPackage{
+			"streamer": {
+				Version:      "2.9.6",
+				Source:       "https://registry.example.com",
+				Archive:      "streamer-2.9.6.tar.gz",
+				SHA256:       "0123abcd",
+				IncludePaths: []string{"include"},
+				Plugins:      map[string][]string{"linux": {"plugins/streamer.so"}},
+				Dependencies: map[string]string{"pawn-memory": "^1.0"},
+			},
+			"pawn-memory": {Version: "1.1.0", Archive: "pawn-memory-1.1.0.zip", IncludePaths: []string{"."}},
+		},
+	}
+	if err := lock.Save(root); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadLock(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, lock) {
+		t.Errorf("LoadLock = %+v, want %+v", loaded, lock)
+	}
+
+	// Saving again gives the same file
+	before := readFile(t, filepath.Join(root, LockFile))
+	if err := loaded.Save(root); err != nil {
+		t.Fatal(err)
+	}
+	if after := readFile(t, filepath.Join(root, LockFile)); after != before {
+		t.Errorf("lockfile changed on a second save:\n%s\n%s", before, after)
+	}
+}
+
+func TestLoadLockMissing(t *testing.T) {
+	lock, err := LoadLock(t.TempDir())
+	if lock != nil || err != nil {
+		t.Fatalf("LoadLock = %v, %v; want nil, nil", lock, err)
+	}
+}
+
+func TestAddInstallsNewest(t *testing.T) {
+	m := testManager(t, testRegistry(t, "streamer", "2.9.5", "2.9.6", "3.0.0-rc.1"))
+	projectFile := filepath.Join(m.Root, utils.ProjectFile)
+	before := readFile(t, projectFile)
+
+	if err := m.Add("streamer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, projectFile) != before {
+		t.Fatal("Add changed project.json before Install")
+	}
+
+	lock, err := m.Install(InstallOptions{Update: []string{"streamer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lock.Packages["streamer"].Version; got != "2.9.6" {
+		t.Errorf("installed streamer %s, want 2.9.6", got)
+	}
+
+	config, err := utils.GetProjectConfig(m.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Dependencies["streamer"]; got != "^2.9.6" {
+		t.Errorf("project.json declares streamer %q, want ^2.9.6", got)
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, Dir, "streamer", "include", "streamer.inc")); err != nil {
+		t.Errorf("include not unpacked: %v", err)
+	}
+	if err := Check(m.Root, config); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
+
+func TestFailedInstallKeepsProject(t *testing.T) {
+	m := testManager(t, testRegistry(t, "streamer", "2.9.6"))
+	projectFile := filepath.Join(m.Root, utils.ProjectFile)
+	before := readFile(t, projectFile)
+
+	if err := m.Add("missing", "^1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Install(InstallOptions{Update: []string{"missing"}}); !errors.Is(err, ErrPackageNotFound) {
+		t.Fatalf("Install error = %v, want %v", err, ErrPackageNotFound)
+	}
+
+	if readFile(t, projectFile) != before {
+		t.Error("project.json changed after a failed install")
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("lockfile written after a failed install: %v", err)
+	}
+}
+
+func TestRemoveWithoutRegistry(t *testing.T) {
+	registry := testRegistry(t, "streamer", "2.9.6")
+	m := testManager(t, registry)
+	if err := m.Add("streamer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Install(InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing needs no registry
+	m = loadManager(t, m.Root, "")
+	if err := m.Remove("streamer"); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := m.Install(InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 0 {
+		t.Errorf("lock still has %v", lock.Packages)
+	}
+
+	config, err := utils.GetProjectConfig(m.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Dependencies) != 0 {
+		t.Errorf("project.json still declares %v", config.Dependencies)
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, Dir, "streamer")); !os.IsNotExist(err) {
+		t.Errorf("dependency folder not pruned: %v", err)
+	}
+}
+
+func TestMaliciousDependencyName(t *testing.T) {
+	registry := testRegistry(t, "a", "1.0.0")
+	index := Index{Name: "a", Versions: []Release{{
+		Version:      "1.0.0",
+		Archive:      "a-1.0.0.tar.gz",
+		Dependencies: map[string]string{"../../victim": "*"},
+	}}}
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(registry, "a", IndexFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := testManager(t, registry)
+	victim := filepath.Join(m.Root, "..", "victim")
+	if err := os.MkdirAll(victim, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(victim, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Add("a", "^1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Install(InstallOptions{}); err == nil || !strings.Contains(err.Error(), `invalid package name "../../victim"`) {
+		t.Fatalf("Install error = %v, want an invalid package name", err)
+	}
+
+	if readFile(t, filepath.Join(victim, "keep.txt")) != "keep" {
+		t.Error("the victim folder was changed")
+	}
+	if _, err := os.Stat(filepath.Join(m.Root, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("lockfile written: %v", err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"streamer", "pawn-memory", "YSI_Data", "v1.2"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", ".hidden", "a/b", `a\b`, "../x", "a..b"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) succeeded", name)
+		}
+	}
+
+	m := testManager(t, "")
+	if err := m.Add("../x", "^1.0.0"); err == nil {
+		t.Error("Add accepted ../x")
+	}
+}
@@ -0,0 +1,267 @@
+package deps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+)
+
+// IndexFile is the name of the per-package index in a registry
+const IndexFile = "index.json"
+
+// ErrPackageNotFound is returned when a registry does not know a package
+var ErrPackageNotFound = errors.New("package not found")
+
+// Release is a published version of a package
+type Release struct {
+	Name         string            `json:"-"`
+	Version      string            `json:"version"`
+	Archive      string            `json:"archive"`
+	SHA256       string            `json:"sha256"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+
+	// registry is where the release was listed, if known
+	registry Registry
+}
+
+// Index represents the structure of <registry>/<package>/index.json
+type Index struct {
+	Name     string    `json:"name"`
+	Versions []Release `json:"versions"`
+}
+
+// Registry is a source of packages. A registry is laid out as one
+// directory per package holding an index.json and the release archives
+// it refers to; archive names are relative to that directory unless they
+// are absolute URLs.
+type Registry interface {
+	// Releases lists the published versions of a package
+	Releases(name string) ([]Release, error)
+
+	// Open returns the archive of a release
+	Open(release Release) (io.ReadCloser, error)
+
+	// String describes the registry for messages and the lockfile
+	String() string
+}
+
+// OpenRegistry returns the registry at location, which may be a directory,
+// a file:// URL or an http(s):// URL. Relative directories are resolved
+// against base.
+func OpenRegistry(location, base string) (Registry, error) {
+	u, err := url.Parse(location)
+	if err == nil && len(u.Scheme) > 1 {
+		switch u.Scheme {
+		case "file":
+			return &FSRegistry{Dir: filepath.FromSlash(u.Path)}, nil
+		case "http", "https":
+			return &HTTPRegistry{BaseURL: strings.TrimRight(location, "/")}, nil
+		}
+		return nil, fmt.Errorf("unsupported registry scheme %q", u.Scheme)
+	}
+
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(base, location)
+	}
+	return &FSRegistry{Dir: location}, nil
+}
+
+// FSRegistry is a registry in a local directory
+type FSRegistry struct {
+	Dir string
+}
+
+// Releases implements Registry
+func (r *FSRegistry) Releases(name string) ([]Release, error) {
+	data, err := os.ReadFile(filepath.Join(r.Dir, name, IndexFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w in %s", name, ErrPackageNotFound, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseIndex(r, name, data)
+}
+
+// Open implements Registry
+func (r *FSRegistry) Open(release Release) (io.ReadCloser, error) {
+	file := release.Archive
+	if strings.Contains(file, "://") {
+		// Mirrors of remote registries keep archives next to the index
+		file = ArchiveName(release)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(r.Dir, release.Name, filepath.FromSlash(file))
+	}
+	return os.Open(file)
+}
+
+// String implements Registry
+func (r *FSRegistry) String() string {
+	return r.Dir
+}
+
+// HTTPRegistry is a registry served over HTTP
+type HTTPRegistry struct {
+	BaseURL string
+
+	// Client makes the requests; nil means archive.HTTPClient
+	Client *http.Client
+}
+
+// Releases implements Registry
+func (r *HTTPRegistry) Releases(name string) ([]Release, error) {
+	body, err := r.get(r.BaseURL + "/" + url.PathEscape(name) + "/" + IndexFile)
+	if errors.Is(err, ErrPackageNotFound) {
+		return nil, fmt.Errorf("%s: %w in %s", name, ErrPackageNotFound, r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseIndex(r, name, data)
+}
+
+// Open implements Registry
+func (r *HTTPRegistry) Open(release Release) (io.ReadCloser, error) {
+	location := release.Archive
+	if !strings.Contains(location, "://") {
+		location = r.BaseURL + "/" + url.PathEscape(release.Name) + "/" + location
+	}
+	return r.get(location)
+}
+
+// String implements Registry
+func (r *HTTPRegistry) String() string {
+	return r.BaseURL
+}
+
+// get fetches a URL and returns its body
+func (r *HTTPRegistry) get(location string) (io.ReadCloser, error) {
+	client := r.Client
+	if client == nil {
+		client = archive.HTTPClient
+	}
+
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrPackageNotFound
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Chain combines several registries. Releases are merged, with earlier
+// registries winning when more than one lists the same version, and
+// archives are fetched from the registry that listed them.
+type Chain []Registry
+
+// Releases implements Registry
+func (c Chain) Releases(name string) ([]Release, error) {
+	var releases []Release
+	seen := map[string]bool{}
+	found := false
+	for _, r := range c {
+		list, err := r.Releases(name)
+		if errors.Is(err, ErrPackageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, release := range list {
+			if !seen[release.Version] {
+				seen[release.Version] = true
+				releases = append(releases, release)
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s: %w in %s", name, ErrPackageNotFound, c)
+	}
+	return releases, nil
+}
+
+// Open implements Registry. Releases that do not remember their registry,
+// such as those read from the lockfile, are tried against each in turn.
+func (c Chain) Open(release Release) (io.ReadCloser, error) {
+	if release.registry != nil {
+		return release.registry.Open(release)
+	}
+
+	var errs []error
+	for _, r := range c {
+		rc, err := r.Open(release)
+		if err == nil {
+			return rc, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", r, err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no package registry available")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// String implements Registry
+func (c Chain) String() string {
+	names := make([]string, len(c))
+	for i, r := range c {
+		names[i] = r.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Local reports whether a registry can be used without network access
+func Local(r Registry) bool {
+	_, ok := r.(*FSRegistry)
+	return ok
+}
+
+// ArchiveName returns the file name of a release archive
+func ArchiveName(release Release) string {
+	return path.Base(release.Archive)
+}
+
+// parseIndex decodes an index.json and fills in the package name
+func parseIndex(r Registry, name string, data []byte) ([]Release, error) {
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index of %s: %w", name, err)
+	}
+
+	for i := range index.Versions {
+		for dep := range index.Versions[i].Dependencies {
+			if err := ValidateName(dep); err != nil {
+				return nil, fmt.Errorf("index of %s: %s: %w", name, index.Versions[i].Version, err)
+			}
+		}
+		index.Versions[i].Name = name
+		index.Versions[i].registry = r
+	}
+	return index.Versions, nil
+}
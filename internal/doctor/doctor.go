@@ -0,0 +1,316 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/builder"
+	"github.com/weltschmerzie/omp-cli/internal/deps"
+	"github.com/weltschmerzie/omp-cli/internal/server"
+	"github.com/weltschmerzie/omp-cli/internal/toolchain"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is the result of a single diagnostic
+type Check struct {
+	Name    string `json:"name"`
+	Project string `json:"project,omitempty"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Report collects the results of all checks along with the environment
+// they ran in
+type Report struct {
+	OS     string  `json:"os"`
+	Arch   string  `json:"arch"`
+	Checks []Check `json:"checks"`
+}
+
+// Count returns how many checks ended with status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// add records a check
+func (r *Report) add(project, name string, status Status, message, hint string) {
+	r.Checks = append(r.Checks, Check{Name: name, Project: project, Status: status, Message: message, Hint: hint})
+}
+
+// Run checks the machine and, when ws is not nil, the given members of the
+// workspace. resolveErr is why the project could not be loaded otherwise.
+func Run(ws *workspace.Workspace, members []*workspace.Member, resolveErr error) *Report {
+	report := &Report{OS: runtime.GOOS, Arch: runtime.GOARCH}
+
+	checkUserConfig(report)
+	if ws == nil {
+		switch {
+		case resolveErr == nil || errors.Is(resolveErr, utils.ErrNoProject):
+			message := "no open.mp project found"
+			if resolveErr != nil {
+				message = resolveErr.Error()
+			}
+			report.add("", "project", Warn, message, "run ompcli doctor inside a project or pass --project-dir")
+		default:
+			report.add("", "project", Fail, resolveErr.Error(), "fix the file named above; the project checks run once it loads")
+		}
+		return report
+	}
+
+	project := func(m *workspace.Member) string {
+		if len(members) > 1 {
+			return m.Name
+		}
+		return ""
+	}
+
+	for _, m := range members {
+		name := project(m)
+		checkConfig(report, name, ws, m)
+		checkCompiler(report, name, ws, m)
+		checkDependencies(report, name, m)
+		checkPlugins(report, name, m)
+		if m.Config.ProjectType() != utils.ProjectTypeLibrary {
+			checkServer(report, name, m)
+			checkPort(report, name, m)
+		}
+	}
+
+	return report
+}
+
+// checkUserConfig makes sure the user configuration can be read
+func checkUserConfig(r *Report) {
+	path, _ := userconfig.Path()
+	if _, err := userconfig.Load(); err != nil {
+		r.add("", "user config", Fail, err.Error(), "fix or remove "+path)
+		return
+	}
+	r.add("", "user config", Pass, path, "")
+}
+
+// checkConfig reports configuration problems found by validation
+func checkConfig(r *Report, project string, ws *workspace.Workspace, m *workspace.Member) {
+	problems := ws.Validate(m)
+	if len(problems) == 0 {
+		r.add(project, "configuration", Pass, "project.json and config.json are valid", "")
+		return
+	}
+
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.Error()
+	}
+	r.add(project, "configuration", Fail, strings.Join(messages, "; "), "run 'ompcli validate' for details")
+}
+
+// checkCompiler makes sure pawncc can be found, runs and has the pinned version
+func checkCompiler(r *Report, project string, ws *workspace.Workspace, m *workspace.Member) {
+	opts := builder.Options{PawnccPath: ws.PawnccPath(), PawnccVersion: ws.Config.PawnccVersion}
+	exe, err := builder.FindPawncc(m.Root, m.Config, opts)
+	if err != nil {
+		r.add(project, "pawncc", Fail, err.Error(), "install the pinned version with 'ompcli toolchain install <version>'")
+		return
+	}
+
+	version, err := toolchain.Probe(exe)
+	if err != nil {
+		r.add(project, "pawncc", Fail, err.Error(), "set pawncc_path in project.json, add pawncc to PATH or pin a toolchain with 'ompcli toolchain use <version>'")
+		return
+	}
+	if m.Config.PawnccVersion == "" && ws.Config.PawnccVersion == "" {
+		r.add(project, "pawncc", Warn, fmt.Sprintf("pawncc %s at %s, but no version is pinned", version, exe), "pin it with 'ompcli toolchain use "+version+"' so everyone uses the same compiler")
+		return
+	}
+	r.add(project, "pawncc", Pass, fmt.Sprintf("pawncc %s at %s", version, exe), "")
+}
+
+// checkDependencies makes sure declared dependencies are installed
+func checkDependencies(r *Report, project string, m *workspace.Member) {
+	if len(m.Config.Dependencies) == 0 {
+		return
+	}
+	if err := deps.Check(m.Root, m.Config); err != nil {
+		r.add(project, "dependencies", Fail, err.Error(), "run 'ompcli install'")
+		return
+	}
+	r.add(project, "dependencies", Pass, fmt.Sprintf("%d dependencies installed", len(m.Config.Dependencies)), "")
+}
+
+// checkPlugins looks for plugins and components built for another OS
+func checkPlugins(r *Report, project string, m *workspace.Member) {
+	files := make([]string, 0, len(m.Config.Plugins))
+	for _, plugin := range m.Config.Plugins {
+		if file, _, err := plugin.Resolve(m.Root, runtime.GOOS); err == nil {
+			files = append(files, filepath.Join(m.Root, filepath.FromSlash(file)))
+		}
+	}
+	for _, dir := range []string{"plugins", "components"} {
+		entries, _ := os.ReadDir(filepath.Join(m.Root, "build", dir))
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(m.Root, "build", dir, entry.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	var wrong []string
+	for _, file := range files {
+		if format := pluginFormat(file); format != "" && format != nativeFormat() {
+			rel, err := filepath.Rel(m.Root, file)
+			if err != nil {
+				rel = file
+			}
+			wrong = append(wrong, fmt.Sprintf("%s (%s)", rel, format))
+		}
+	}
+
+	if len(wrong) > 0 {
+		r.add(project, "plugins", Fail, "built for another OS: "+strings.Join(wrong, ", "),
+			fmt.Sprintf("use the %s builds of these plugins", nativeExt()))
+		return
+	}
+	r.add(project, "plugins", Pass, fmt.Sprintf("%d plugins and components match %s", len(files), runtime.GOOS), "")
+}
+
+// checkServer makes sure the server binary is staged and executable
+func checkServer(r *Report, project string, m *workspace.Member) {
+	if m.Config.ServerVersion != "" {
+		if _, err := server.Find(m.Config.ServerVersion); err != nil {
+			r.add(project, "server", Fail, err.Error(), "run 'ompcli server install "+m.Config.ServerVersion+"'")
+			return
+		}
+	}
+
+	exe := filepath.Join(m.Root, "build", server.ExeName())
+	info, err := os.Stat(exe)
+	if err != nil {
+		hint := "run 'ompcli build'"
+		if m.Config.ServerVersion == "" {
+			hint = "pin a server with 'ompcli server use <version>' and run 'ompcli build'"
+		}
+		r.add(project, "server", Warn, "build/"+server.ExeName()+" does not exist", hint)
+		return
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		r.add(project, "server", Fail, "build/"+server.ExeName()+" is not executable", "run 'chmod +x "+exe+"'")
+		return
+	}
+	if format := binaryFormat(exe); format != "" && format != nativeFormat() {
+		r.add(project, "server", Fail, "build/"+server.ExeName()+" is a "+format+" binary", "install the "+runtime.GOOS+" release of the server")
+		return
+	}
+	r.add(project, "server", Pass, exe, "")
+}
+
+// checkPort makes sure the configured port is free
+func checkPort(r *Report, project string, m *workspace.Member) {
+	serverConfig, err := utils.GetServerConfig(m.Root)
+	if err != nil {
+		return
+	}
+
+	// open.mp listens on UDP
+	address := ":" + strconv.Itoa(serverConfig.Port)
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		r.add(project, "port", Fail, fmt.Sprintf("UDP port %d is in use", serverConfig.Port),
+			"stop the process using it or change it with 'ompcli config set server:port <port>'")
+		return
+	}
+	conn.Close()
+	r.add(project, "port", Pass, fmt.Sprintf("UDP port %d is free", serverConfig.Port), "")
+}
+
+// binaryFormat returns "ELF", "PE" or "Mach-O" for executables and shared
+// libraries, judging by their magic bytes, or an empty string
+func binaryFormat(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return ""
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte{0x7f, 'E', 'L', 'F'}):
+		return "ELF"
+	case bytes.Equal(magic[:2], []byte{'M', 'Z'}):
+		return "PE"
+	case bytes.Equal(magic, []byte{0xcf, 0xfa, 0xed, 0xfe}), bytes.Equal(magic, []byte{0xce, 0xfa, 0xed, 0xfe}):
+		return "Mach-O"
+	}
+	return ""
+}
+
+// pluginFormat returns the binary format of a plugin, falling back to its
+// extension for files that are not binaries, such as placeholders
+func pluginFormat(path string) string {
+	if format := binaryFormat(path); format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dll":
+		return "PE"
+	case ".so":
+		if runtime.GOOS == "darwin" {
+			return "Mach-O"
+		}
+		return "ELF"
+	case ".dylib":
+		return "Mach-O"
+	}
+	return ""
+}
+
+// nativeFormat returns the binary format of this platform
+func nativeFormat() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "PE"
+	case "darwin":
+		return "Mach-O"
+	}
+	return "ELF"
+}
+
+// nativeExt returns the extension of plugins on this platform
+func nativeExt() string {
+	if runtime.GOOS == "windows" {
+		return ".dll"
+	}
+	return ".so"
+}
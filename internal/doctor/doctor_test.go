@@ -0,0 +1,43 @@
+package doctor
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+func TestRunOutsideProject(t *testing.T) {
+	t.Setenv("OMPCLI_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	tests := []struct {
+		name    string
+		err     error
+		status  Status
+		message string
+	}{
+		{
+			name:    "no project",
+			err:     fmt.Errorf("/tmp is %w (no project.json found)", utils.ErrNoProject),
+			status:  Warn,
+			message: "/tmp is not inside an open.mp project (no project.json found)",
+		},
+		{
+			name:    "broken project",
+			err:     fmt.Errorf("project.json: line 3: unexpected end of input"),
+			status:  Fail,
+			message: "project.json: line 3: unexpected end of input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(nil, nil, tt.err)
+			check := report.Checks[len(report.Checks)-1]
+			if check.Name != "project" || check.Status != tt.status || check.Message != tt.message {
+				t.Errorf("check = %+v", check)
+			}
+		})
+	}
+}
@@ -0,0 +1,762 @@
+package jsonedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the type of a JSON value
+type Kind int
+
+const (
+	Null Kind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+// String returns the JSON name of the kind
+func (k Kind) String() string {
+	switch k {
+	case Null:
+		return "null"
+	case Bool:
+		return "boolean"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Array:
+		return "array"
+	case Object:
+		return "object"
+	}
+	return "unknown"
+}
+
+// Value is a JSON value that remembers the key order of objects and the
+// literal text of scalars, so that unchanged parts are written back as-is
+type Value struct {
+	Kind   Kind
+	Raw    string   // literal text of a scalar value
+	Items  []*Value // elements of an array
+	Fields []*Field // members of an object, in document order
+
+	// src is the parsed form of an array or object, nil for values built
+	// by edits
+	src *source
+}
+
+// source remembers the members a container was parsed with and the text
+// around them: gaps[0] follows the opening bracket, gaps[i] precedes
+// member i and includes the comma, and the last gap precedes the closing
+// bracket
+type source struct {
+	items  []*Value
+	fields []*Field
+	gaps   []string
+}
+
+// Field is a single member of a JSON object
+type Field struct {
+	Key   string
+	Value *Value
+
+	// rawKey is the key as it was parsed and sep the text between it and
+	// the value, such as ": "
+	rawKey string
+	sep    string
+}
+
+// Document is a parsed JSON file together with its formatting. Edits only
+// rewrite the values they change: everything else, including whitespace,
+// compact arrays and line endings, is written back as it was read.
+type Document struct {
+	Root   *Value
+	Indent string
+
+	// prefix and suffix surround the root value, newline is the line
+	// ending of the file
+	prefix  string
+	suffix  string
+	newline string
+}
+
+// Parse parses JSON data into a Document
+func Parse(data []byte) (*Document, error) {
+	p := &parser{data: data}
+	p.skipSpace()
+	start := p.pos
+	root, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, p.errorf("unexpected data after top-level value")
+	}
+
+	newline := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	return &Document{
+		Root:    root,
+		Indent:  detectIndent(data),
+		prefix:  string(data[:start]),
+		suffix:  string(data[end:]),
+		newline: newline,
+	}, nil
+}
+
+// Bytes renders the document. Unchanged parts keep their original text;
+// values added by edits use the document's indentation and line endings.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(d.prefix)
+	d.render(&buf, d.Root, 0, d.Indent)
+	buf.WriteString(d.suffix)
+	return buf.Bytes()
+}
+
+// render writes v at depth. Containers that were parsed keep the text
+// around their members; indent is used for values the edits created.
+func (d *Document) render(buf *bytes.Buffer, v *Value, depth int, indent string) {
+	s := v.src
+	members := len(v.Items) + len(v.Fields)
+	if s == nil || (members > 0 && len(s.items)+len(s.fields) == 0) {
+		writeValue(buf, v, indent, d.newline, depth)
+		return
+	}
+
+	// Members added to a single-line container stay on that line
+	if !s.multiline() {
+		indent = ""
+	}
+	closing := s.gaps[len(s.gaps)-1]
+	if members == 0 {
+		closing = ""
+		if len(s.items)+len(s.fields) == 0 {
+			closing = s.gaps[0]
+		}
+	}
+
+	switch v.Kind {
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v.Items {
+			buf.WriteString(s.gap(i, slices.Index(s.items, item)))
+			d.render(buf, item, depth+1, indent)
+		}
+		buf.WriteString(closing)
+		buf.WriteByte(']')
+	case Object:
+		buf.WriteByte('{')
+		for i, f := range v.Fields {
+			buf.WriteString(s.gap(i, slices.Index(s.fields, f)))
+			if f.rawKey != "" && decodeKey(f.rawKey) == f.Key {
+				buf.WriteString(f.rawKey)
+				buf.WriteString(f.sep)
+			} else {
+				key, _ := marshalNoEscape(f.Key)
+				buf.Write(key)
+				buf.WriteString(s.fieldSep())
+			}
+			d.render(buf, f.Value, depth+1, indent)
+		}
+		buf.WriteString(closing)
+		buf.WriteByte('}')
+	}
+}
+
+// gap returns the text to write before member i, which was parsed as
+// member j or, when j is -1, was added by an edit. Parsed members keep the
+// text they were parsed with.
+func (s *source) gap(i, j int) string {
+	switch {
+	case i == 0:
+		return s.gaps[0]
+	case j > 0:
+		return s.gaps[j]
+	case j == 0 && strings.Contains(s.gaps[0], "\n"):
+		return "," + s.gaps[0]
+	}
+	return s.newGap()
+}
+
+// newGap returns the text before members added by edits: a new line
+// indented like the line of the last member or, in containers on a single
+// line, the separator between their members
+func (s *source) newGap() string {
+	members := len(s.gaps) - 1
+	for i := members - 1; i >= 0; i-- {
+		if n := strings.LastIndex(s.gaps[i], "\n"); n >= 0 {
+			if n > 0 && s.gaps[i][n-1] == '\r' {
+				n--
+			}
+			return "," + s.gaps[i][n:]
+		}
+	}
+	if members > 1 {
+		return s.gaps[1]
+	}
+	return ", "
+}
+
+// fieldSep returns the text between key and value for new members, taken
+// from the last member
+func (s *source) fieldSep() string {
+	if len(s.fields) > 0 {
+		return s.fields[len(s.fields)-1].sep
+	}
+	return ": "
+}
+
+// multiline reports whether the container spans several lines
+func (s *source) multiline() bool {
+	for _, gap := range s.gaps {
+		if strings.Contains(gap, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeKey returns the key a parsed key literal stands for
+func decodeKey(raw string) string {
+	var key string
+	_ = json.Unmarshal([]byte(raw), &key)
+	return key
+}
+
+// Get returns the value at path
+func (d *Document) Get(path Path) (*Value, error) {
+	v := d.Root
+	for i, seg := range path {
+		next, err := v.child(seg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path[:i+1], err)
+		}
+		v = next
+	}
+	return v, nil
+}
+
+// Set replaces the value at path, creating intermediate objects as needed
+func (d *Document) Set(path Path, value *Value) error {
+	if len(path) == 0 {
+		d.Root = value
+		return nil
+	}
+
+	parent := d.Root
+	for i, seg := range path[:len(path)-1] {
+		next, err := parent.child(seg)
+		if errors.Is(err, ErrNotFound) && seg.Key != "" && parent.Kind == Object {
+			next = NewObject()
+			parent.Fields = append(parent.Fields, &Field{Key: seg.Key, Value: next})
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path[:i+1], err)
+		}
+		parent = next
+	}
+
+	last := path[len(path)-1]
+	switch {
+	case last.Key != "" && parent.Kind == Object:
+		for _, f := range parent.Fields {
+			if f.Key == last.Key {
+				f.Value = value
+				return nil
+			}
+		}
+		parent.Fields = append(parent.Fields, &Field{Key: last.Key, Value: value})
+		return nil
+	case last.Key == "" && parent.Kind == Array:
+		if last.Index < 0 || last.Index >= len(parent.Items) {
+			return fmt.Errorf("%s: index out of range", path)
+		}
+		parent.Items[last.Index] = value
+		return nil
+	}
+
+	return fmt.Errorf("%s: cannot set a member of a %s", path, parent.Kind)
+}
+
+// Delete removes the value at path
+func (d *Document) Delete(path Path) error {
+	if len(path) == 0 {
+		return errors.New("cannot delete the document root")
+	}
+
+	parent, err := d.Get(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	switch {
+	case last.Key != "" && parent.Kind == Object:
+		for i, f := range parent.Fields {
+			if f.Key == last.Key {
+				parent.Fields = append(parent.Fields[:i], parent.Fields[i+1:]...)
+				return nil
+			}
+		}
+	case last.Key == "" && parent.Kind == Array:
+		if last.Index >= 0 && last.Index < len(parent.Items) {
+			parent.Items = append(parent.Items[:last.Index], parent.Items[last.Index+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %w", path, ErrNotFound)
+}
+
+// ErrNotFound is returned when a path does not exist in the document
+var ErrNotFound = errors.New("not found")
+
+// child returns the direct child addressed by seg
+func (v *Value) child(seg Segment) (*Value, error) {
+	if seg.Key != "" {
+		if v.Kind != Object {
+			return nil, fmt.Errorf("not an object (found %s)", v.Kind)
+		}
+		for _, f := range v.Fields {
+			if f.Key == seg.Key {
+				return f.Value, nil
+			}
+		}
+		return nil, ErrNotFound
+	}
+
+	if v.Kind != Array {
+		return nil, fmt.Errorf("not an array (found %s)", v.Kind)
+	}
+	if seg.Index < 0 || seg.Index >= len(v.Items) {
+		return nil, ErrNotFound
+	}
+	return v.Items[seg.Index], nil
+}
+
+// Lookup returns the member of an object with the given key, or nil
+func (v *Value) Lookup(key string) *Value {
+	if v == nil || v.Kind != Object {
+		return nil
+	}
+	for _, f := range v.Fields {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// Equal reports whether two values hold the same JSON data
+func (v *Value) Equal(other *Value) bool {
+	var a, b any
+	if json.Unmarshal(v.Bytes(), &a) != nil || json.Unmarshal(other.Bytes(), &b) != nil {
+		return false
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// Bytes renders the value in compact form
+func (v *Value) Bytes() []byte {
+	var buf bytes.Buffer
+	writeValue(&buf, v, "", "\n", 0)
+	return buf.Bytes()
+}
+
+// Indented renders the value using the given indentation
+func (v *Value) Indented(indent string) []byte {
+	var buf bytes.Buffer
+	writeValue(&buf, v, indent, "\n", 0)
+	return buf.Bytes()
+}
+
+// Decode unmarshals the value into out
+func (v *Value) Decode(out any) error {
+	return json.Unmarshal(v.Bytes(), out)
+}
+
+// NewObject returns an empty object value
+func NewObject() *Value {
+	return &Value{Kind: Object, Fields: []*Field{}}
+}
+
+// NewArray returns an empty array value
+func NewArray() *Value {
+	return &Value{Kind: Array, Items: []*Value{}}
+}
+
+// NewString returns a string value
+func NewString(s string) *Value {
+	data, _ := marshalNoEscape(s)
+	return &Value{Kind: String, Raw: string(data)}
+}
+
+// FromGo converts any JSON-marshalable Go value into a Value
+func FromGo(x any) (*Value, error) {
+	data, err := marshalNoEscape(x)
+	if err != nil {
+		return nil, err
+	}
+	return ParseValue(data)
+}
+
+// ParseValue parses a JSON value to insert into a document. Unlike the
+// values of a parsed document, it takes on the formatting of the document
+// it is inserted into.
+func ParseValue(data []byte) (*Value, error) {
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	doc.Root.forget()
+	return doc.Root, nil
+}
+
+// forget drops the parsed formatting of v and its members
+func (v *Value) forget() {
+	v.src = nil
+	for _, item := range v.Items {
+		item.forget()
+	}
+	for _, f := range v.Fields {
+		f.rawKey, f.sep = "", ""
+		f.Value.forget()
+	}
+}
+
+// marshalNoEscape marshals x without escaping HTML characters
+func marshalNoEscape(x any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(x); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Segment is a single step of a Path: an object key or an array index
+type Segment struct {
+	Key   string
+	Index int
+}
+
+// Path addresses a value inside a document
+type Path []Segment
+
+// String renders the path in the syntax accepted by ParsePath
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, seg := range p {
+		if seg.Key != "" {
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(seg.Key)
+		} else {
+			sb.WriteString("[" + strconv.Itoa(seg.Index) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// ParsePath parses a dotted path such as "pawn.legacy_plugins[0]"
+func ParsePath(s string) (Path, error) {
+	var path Path
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("invalid path %q: empty key", s)
+		}
+
+		key := part
+		var indexes string
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, indexes = part[:i], part[i:]
+		}
+		if key != "" {
+			path = append(path, Segment{Key: key})
+		}
+
+		for indexes != "" {
+			end := strings.IndexByte(indexes, ']')
+			if indexes[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid path %q: malformed index", s)
+			}
+			n, err := strconv.Atoi(indexes[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", s, indexes[1:end])
+			}
+			path = append(path, Segment{Index: n})
+			indexes = indexes[end+1:]
+		}
+	}
+	return path, nil
+}
+
+// detectIndent guesses the indentation unit used by a JSON file
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// writeValue renders v in the style of json.MarshalIndent, ending lines
+// with eol
+func writeValue(buf *bytes.Buffer, v *Value, indent, eol string, depth int) {
+	newline := func(d int) {
+		if indent == "" {
+			return
+		}
+		buf.WriteString(eol)
+		buf.WriteString(strings.Repeat(indent, d))
+	}
+
+	switch v.Kind {
+	case Array:
+		if len(v.Items) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, item := range v.Items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeValue(buf, item, indent, eol, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte(']')
+	case Object:
+		if len(v.Fields) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, f := range v.Fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(depth + 1)
+			key, _ := marshalNoEscape(f.Key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if indent != "" {
+				buf.WriteByte(' ')
+			}
+			writeValue(buf, f.Value, indent, eol, depth+1)
+		}
+		newline(depth)
+		buf.WriteByte('}')
+	default:
+		buf.WriteString(v.Raw)
+	}
+}
+
+// parser is a small recursive-descent JSON parser that keeps literal text
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line := bytes.Count(p.data[:p.pos], []byte("\n")) + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) parseValue() (*Value, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '"':
+		raw, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Kind: String, Raw: raw}, nil
+	case c == 't' || c == 'f':
+		return p.parseLiteral(Bool)
+	case c == 'n':
+		return p.parseLiteral(Null)
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseLiteral(Number)
+	default:
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+func (p *parser) parseObject() (*Value, error) {
+	v := NewObject()
+	p.pos++ // '{'
+	last := p.pos
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == '}' {
+		v.src = &source{gaps: []string{string(p.data[last:p.pos])}}
+		p.pos++
+		return v, nil
+	}
+
+	var gaps []string
+	for {
+		p.skipSpace()
+		gaps = append(gaps, string(p.data[last:p.pos]))
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return nil, p.errorf("expected object key")
+		}
+		raw, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal([]byte(raw), &key); err != nil {
+			return nil, p.errorf("invalid object key: %v", err)
+		}
+
+		keyEnd := p.pos
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after object key")
+		}
+		p.pos++
+		p.skipSpace()
+		sep := string(p.data[keyEnd:p.pos])
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		v.Fields = append(v.Fields, &Field{Key: key, Value: value, rawKey: raw, sep: sep})
+
+		last = p.pos
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unexpected end of input in object")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			gaps = append(gaps, string(p.data[last:p.pos]))
+			v.src = &source{fields: append([]*Field{}, v.Fields...), gaps: gaps}
+			p.pos++
+			return v, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *parser) parseArray() (*Value, error) {
+	v := NewArray()
+	p.pos++ // '['
+	last := p.pos
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == ']' {
+		v.src = &source{gaps: []string{string(p.data[last:p.pos])}}
+		p.pos++
+		return v, nil
+	}
+
+	var gaps []string
+	for {
+		p.skipSpace()
+		gaps = append(gaps, string(p.data[last:p.pos]))
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		v.Items = append(v.Items, item)
+
+		last = p.pos
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unexpected end of input in array")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+			gaps = append(gaps, string(p.data[last:p.pos]))
+			v.src = &source{items: append([]*Value{}, v.Items...), gaps: gaps}
+			p.pos++
+			return v, nil
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			raw := string(p.data[start:p.pos])
+			if !json.Valid([]byte(raw)) {
+				return "", p.errorf("invalid string literal")
+			}
+			return raw, nil
+		default:
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) parseLiteral(kind Kind) (*Value, error) {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			break
+		}
+		p.pos++
+	}
+
+	raw := string(p.data[start:p.pos])
+	if !json.Valid([]byte(raw)) {
+		return nil, p.errorf("invalid literal %q", raw)
+	}
+	if kind == Bool && raw != "true" && raw != "false" || kind == Null && raw != "null" {
+		return nil, p.errorf("invalid literal %q", raw)
+	}
+	return &Value{Kind: kind, Raw: raw}, nil
+}
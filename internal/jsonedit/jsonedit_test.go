@@ -0,0 +1,183 @@
+package jsonedit
+
+import (
+	"strings"
+	"testing"
+)
+
+// mustPath parses a path or fails the test
+func mustPath(t *testing.T, s string) Path {
+	t.Helper()
+	path, err := ParsePath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"{\r\n  \"name\": \"gm\",\r\n  \"plugins\": [\"a\", \"b\"]\r\n}\r\n",
+		`{"port":7777,"plugins":[ "a" ,"b" ],"rcon":{"enable" : false}}`,
+		"  {\n\t\"a\": 1.50,\n\t\"b\": [],\n\t\"c\": {  },\n\t\"\\u0064\": null\n}\n\n",
+		`[1, [2, 3], {"x": "\u00e9"}]`,
+	}
+	for _, input := range inputs {
+		doc, err := Parse([]byte(input))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", input, err)
+		}
+		if got := string(doc.Bytes()); got != input {
+			t.Errorf("Bytes() = %q, want %q", got, input)
+		}
+	}
+}
+
+func TestEditKeepsFormatting(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edit  func(t *testing.T, doc *Document)
+		want  string
+	}{
+		{
+			name:  "set a scalar",
+			input: "{\r\n  \"port\":   7777,\r\n  \"plugins\": [\"a\", \"b\"]\r\n}\r\n",
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Set(mustPath(t, "port"), &Value{Kind: Number, Raw: "8888"}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\r\n  \"port\":   8888,\r\n  \"plugins\": [\"a\", \"b\"]\r\n}\r\n",
+		},
+		{
+			name:  "append to a compact array",
+			input: "{\n  \"plugins\": [\"a\", \"b\"],\n  \"port\": 7777\n}\n",
+			edit: func(t *testing.T, doc *Document) {
+				arr, _ := doc.Get(mustPath(t, "plugins"))
+				arr.Items = append(arr.Items, NewString("c"))
+			},
+			want: "{\n  \"plugins\": [\"a\", \"b\", \"c\"],\n  \"port\": 7777\n}\n",
+		},
+		{
+			name:  "remove from a compact array",
+			input: `{"plugins": ["a", "b", "c"]}`,
+			edit: func(t *testing.T, doc *Document) {
+				arr, _ := doc.Get(mustPath(t, "plugins"))
+				arr.Items = append(arr.Items[:1], arr.Items[2:]...)
+			},
+			want: `{"plugins": ["a", "c"]}`,
+		},
+		{
+			name:  "add a key with CRLF line endings",
+			input: "{\r\n    \"name\": \"gm\"\r\n}\r\n",
+			edit: func(t *testing.T, doc *Document) {
+				value, _ := FromGo(map[string]int{"x": 1})
+				if err := doc.Set(mustPath(t, "nested"), value); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\r\n    \"name\": \"gm\",\r\n    \"nested\": {\r\n        \"x\": 1\r\n    }\r\n}\r\n",
+		},
+		{
+			name:  "add a key to a single line object",
+			input: `{"a":1}`,
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Set(mustPath(t, "b.c"), NewString("d")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: `{"a":1, "b":{"c":"d"}}`,
+		},
+		{
+			name:  "add a key after a line of several fields",
+			input: "{\n  \"hostname\": \"x\", \"port\": 7777,\n  \"maxplayers\": 50\n}\n",
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Set(mustPath(t, "language"), NewString("English")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\n  \"hostname\": \"x\", \"port\": 7777,\n  \"maxplayers\": 50,\n  \"language\": \"English\"\n}\n",
+		},
+		{
+			name:  "add a key when the last line holds several fields",
+			input: "{\n\t\"a\" : 1,\n\t\"b\" : 2, \"c\" : 3\n}",
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Set(mustPath(t, "d"), &Value{Kind: Number, Raw: "4"}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\n\t\"a\" : 1,\n\t\"b\" : 2, \"c\" : 3,\n\t\"d\" : 4\n}",
+		},
+		{
+			name:  "delete a key from a line of several fields",
+			input: "{\n  \"hostname\": \"x\", \"port\": 7777,\n  \"maxplayers\": 50\n}\n",
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Delete(mustPath(t, "port")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\n  \"hostname\": \"x\",\n  \"maxplayers\": 50\n}\n",
+		},
+		{
+			name:  "delete a key",
+			input: "{\n  \"a\": 1,\n  \"b\": [1,2],\n  \"c\": 3\n}",
+			edit: func(t *testing.T, doc *Document) {
+				if err := doc.Delete(mustPath(t, "a")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "{\n  \"b\": [1,2],\n  \"c\": 3\n}",
+		},
+		{
+			name:  "fill an empty array",
+			input: "{\n  \"plugins\": []\n}\n",
+			edit: func(t *testing.T, doc *Document) {
+				arr, _ := doc.Get(mustPath(t, "plugins"))
+				arr.Items = append(arr.Items, NewString("a"))
+			},
+			want: "{\n  \"plugins\": [\n    \"a\"\n  ]\n}\n",
+		},
+		{
+			name:  "empty an array",
+			input: "{\n  \"plugins\": [\n    \"a\"\n  ]\n}\n",
+			edit: func(t *testing.T, doc *Document) {
+				arr, _ := doc.Get(mustPath(t, "plugins"))
+				arr.Items = arr.Items[:0]
+			},
+			want: "{\n  \"plugins\": []\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(t, doc)
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+
+			// What was written parses back to the same data
+			again, err := Parse(doc.Bytes())
+			if err != nil {
+				t.Fatalf("the edited document does not parse: %v", err)
+			}
+			if !again.Root.Equal(doc.Root) {
+				t.Errorf("parsed %s, want %s", again.Root.Bytes(), doc.Root.Bytes())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{`{"a": }`, `[1,]`, `{"a" 1}`, `tru`, `{} {}`} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded", input)
+		} else if !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("Parse(%q) error %q has no line number", input, err)
+		}
+	}
+}
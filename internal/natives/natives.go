@@ -0,0 +1,388 @@
+// Package natives checks that the natives a compiled script calls are
+// provided by the open.mp runtime or by one of the project's plugins, so
+// that a missing plugin shows up at build time rather than as "File or
+// function is not found" when the server starts.
+//
+// What provides a native is learnt from the include files declaring it: the
+// includes of the open.mp runtime, those of a plugin (named after it, or
+// listed with the plugin in project.json) and those of a dependency that
+// ships plugins. A plugin can also come with a native manifest, a
+// <plugin>.natives file next to its binary listing one native per line.
+package natives
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// Check modes, set with check_natives in project.json
+const (
+	CheckWarn  = "warn"
+	CheckError = "error"
+	CheckOff   = "off"
+)
+
+// ManifestExt is the extension of native manifests
+const ManifestExt = ".natives"
+
+// Runtime names the provider of the natives built into open.mp
+const Runtime = "open.mp"
+
+// runtimeIncludes are the include files of the open.mp runtime and the
+// Pawn core, besides those starting with omp_
+var runtimeIncludes = map[string]bool{
+	"open.mp.inc": true, "_open_mp.inc": true,
+	"a_samp.inc": true, "a_players.inc": true, "a_vehicles.inc": true, "a_objects.inc": true,
+	"a_actor.inc": true, "a_http.inc": true, "a_npc.inc": true, "a_sampdb.inc": true,
+	"core.inc": true, "float.inc": true, "string.inc": true, "file.inc": true, "time.inc": true,
+	"console.inc": true, "args.inc": true, "datagram.inc": true, "rational.inc": true, "fixed.inc": true,
+}
+
+// Validate checks a check mode
+func Validate(mode string) error {
+	switch mode {
+	case "", CheckWarn, CheckError, CheckOff:
+		return nil
+	}
+	return fmt.Errorf("check_natives must be %q, %q or %q", CheckWarn, CheckError, CheckOff)
+}
+
+// Plugin is a plugin or component the project loads
+type Plugin struct {
+	Name string
+
+	// File is the plugin binary, if it was found
+	File string
+
+	// Includes are include files of the plugin, as file names or paths
+	// relative to the project root, besides <name>.inc and a_<name>.inc
+	Includes []string
+}
+
+// Package is an installed dependency that ships plugins or components
+type Package struct {
+	Name string
+
+	// IncludeDirs hold the package's includes and Files its binaries
+	IncludeDirs []string
+	Files       []string
+}
+
+// Project is what the natives of a script are checked against
+type Project struct {
+	FS   vfs.FS
+	Root string
+
+	// IncludeDirs are the directories the compiler searched
+	IncludeDirs []string
+
+	// RuntimeDirs hold only includes of the open.mp runtime, such as the
+	// includes bundled with the server
+	RuntimeDirs []string
+
+	Plugins  []Plugin
+	Packages []Package
+}
+
+// Missing is a native that nothing provides
+type Missing struct {
+	Name string
+
+	// DeclaredIn is the include file declaring the native, if one was
+	// found; its plugin is probably not configured
+	DeclaredIn string
+}
+
+// String describes the missing native
+func (m Missing) String() string {
+	if m.DeclaredIn == "" {
+		return m.Name
+	}
+	return fmt.Sprintf("%s (declared in %s)", m.Name, m.DeclaredIn)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	// Providers maps each provided native to what provides it
+	Providers map[string]string
+
+	// Missing lists the required natives nothing provides, sorted by name
+	Missing []Missing
+
+	// RuntimeFound reports whether the includes of the open.mp runtime
+	// were found. Without them every native of the runtime is missing, so
+	// the result is not meaningful.
+	RuntimeFound bool
+}
+
+// Check compares the natives a script requires with those provided in p
+func Check(p Project, required []string) (*Result, error) {
+	fsys := vfs.Or(p.FS)
+	result := &Result{Providers: map[string]string{}}
+	declared := map[string]string{}
+
+	provide := func(provider string, names []string) {
+		for _, name := range names {
+			if _, ok := result.Providers[name]; !ok {
+				result.Providers[name] = provider
+			}
+		}
+	}
+
+	// Native manifests of plugins and packages
+	for _, plugin := range p.Plugins {
+		if plugin.File == "" {
+			continue
+		}
+		names, err := manifest(fsys, plugin.File)
+		if err != nil {
+			return nil, err
+		}
+		provide("plugin "+plugin.Name, names)
+	}
+	for _, pkg := range p.Packages {
+		for _, file := range pkg.Files {
+			names, err := manifest(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			provide("package "+pkg.Name, names)
+		}
+	}
+
+	// Includes of packages shipping plugins belong to the package
+	owned := map[string]bool{}
+	for _, pkg := range p.Packages {
+		for _, dir := range pkg.IncludeDirs {
+			err := includes(fsys, dir, func(file string, names []string) {
+				owned[file] = true
+				provide("package "+pkg.Name, names)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, dir := range p.RuntimeDirs {
+		err := includes(fsys, dir, func(file string, names []string) {
+			owned[file] = true
+			result.RuntimeFound = true
+			provide(Runtime, names)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Other includes are matched to the runtime and the plugins by name
+	for _, dir := range p.IncludeDirs {
+		err := includes(fsys, dir, func(file string, names []string) {
+			if owned[file] {
+				return
+			}
+			owned[file] = true
+
+			if isRuntimeInclude(filepath.Base(file)) {
+				result.RuntimeFound = true
+				provide(Runtime, names)
+				return
+			}
+			if plugin := p.owner(file); plugin != "" {
+				provide("plugin "+plugin, names)
+				return
+			}
+			for _, name := range names {
+				if _, ok := declared[name]; !ok {
+					declared[name] = p.rel(file)
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range required {
+		if _, ok := result.Providers[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result.Missing = append(result.Missing, Missing{Name: name, DeclaredIn: declared[name]})
+	}
+	sort.Slice(result.Missing, func(i, j int) bool { return result.Missing[i].Name < result.Missing[j].Name })
+	return result, nil
+}
+
+// owner returns the plugin an include file belongs to, if any
+func (p Project) owner(file string) string {
+	base := strings.ToLower(filepath.Base(file))
+	rel := filepath.ToSlash(p.rel(file))
+	for _, plugin := range p.Plugins {
+		name := strings.ToLower(plugin.Name)
+		if base == name+".inc" || base == "a_"+name+".inc" {
+			return plugin.Name
+		}
+		for _, inc := range plugin.Includes {
+			if strings.EqualFold(inc, base) || path(inc) == rel {
+				return plugin.Name
+			}
+		}
+	}
+	return ""
+}
+
+// rel returns file relative to the project root when it is inside it
+func (p Project) rel(file string) string {
+	if rel, err := filepath.Rel(p.Root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// path cleans a slash-separated path from project.json
+func path(p string) string {
+	return filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+}
+
+// isRuntimeInclude reports whether an include file name is one of the
+// open.mp runtime
+func isRuntimeInclude(base string) bool {
+	base = strings.ToLower(base)
+	return runtimeIncludes[base] || strings.HasPrefix(base, "omp_") && strings.HasSuffix(base, ".inc")
+}
+
+// includes calls fn with the natives declared in every include file below
+// dir. A missing dir is skipped.
+func includes(fsys vfs.FS, dir string, fn func(file string, names []string)) error {
+	if !vfs.Exists(fsys, dir) {
+		return nil
+	}
+	return vfs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isInclude(p) {
+			return nil
+		}
+		data, err := fsys.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fn(filepath.Clean(p), Declared(data))
+		return nil
+	})
+}
+
+// isInclude reports whether a file is a Pawn include
+func isInclude(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".inc", ".pwn", ".p":
+		return true
+	}
+	return false
+}
+
+// manifest reads the native manifest next to a plugin binary, if there is
+// one
+func manifest(fsys vfs.FS, binary string) ([]string, error) {
+	file := strings.TrimSuffix(binary, filepath.Ext(binary)) + ManifestExt
+	data, err := fsys.ReadFile(file)
+	if err != nil {
+		if !vfs.Exists(fsys, file) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseManifest(data), nil
+}
+
+// ParseManifest returns the natives listed in a native manifest: one name
+// per line, with # starting a comment
+func ParseManifest(data []byte) []string {
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names
+}
+
+// nativeDecl matches the start of a native declaration, with an optional
+// tag before the name
+var nativeDecl = regexp.MustCompile(`\bnative\s+(?:[A-Za-z_@][\w@]*\s*:\s*)?([A-Za-z_@][\w@]*)\s*\(`)
+
+// externalName matches the name a native is registered under when it is
+// declared as "native Name(...) = External;"
+var externalName = regexp.MustCompile(`^\s*=\s*"?([A-Za-z_@][\w@]*)"?`)
+
+// Declared returns the natives declared in Pawn source. A native declared
+// with an external name also yields that name.
+func Declared(src []byte) []string {
+	code := stripComments(src)
+	var names []string
+	for _, m := range nativeDecl.FindAllSubmatchIndex(code, -1) {
+		names = append(names, string(code[m[2]:m[3]]))
+
+		// Skip the parameters and look for an external name
+		depth, i := 1, m[1]
+		for ; i < len(code) && depth > 0; i++ {
+			switch code[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		if ext := externalName.FindSubmatch(code[i:]); ext != nil {
+			names = append(names, string(ext[1]))
+		}
+	}
+	return names
+}
+
+// stripComments blanks out comments, so that the word native in them is
+// not taken for a declaration
+func stripComments(src []byte) []byte {
+	out := make([]byte, len(src))
+	copy(out, src)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			for ; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i+1 < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		case out[i] == '"' || out[i] == '\'':
+			// Skip string and character literals, which may hold //
+			quote := out[i]
+			for i++; i < len(out) && out[i] != quote && out[i] != '\n'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return out
+}
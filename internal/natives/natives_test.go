@@ -0,0 +1,180 @@
+package natives
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+func TestDeclared(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "plain",
+			src:  "native SetGameModeText(const string[]);\nnative print(const string[]);",
+			want: []string{"SetGameModeText", "print"},
+		},
+		{
+			name: "tagged",
+			src:  "native Float:GetPlayerHealth(playerid, &Float:health);\nnative bool: IsPlayerConnected(playerid);",
+			want: []string{"GetPlayerHealth", "IsPlayerConnected"},
+		},
+		{
+			name: "external name",
+			src:  "native SSCANF_Init(players, invalid, len) = sscanf_init;\nnative Old(const a[], b = sizeof (a)) = \"New\";",
+			want: []string{"SSCANF_Init", "sscanf_init", "Old", "New"},
+		},
+		{
+			name: "parameters over lines",
+			src:  "native CreateDynamicObject(modelid,\n\tFloat:x, Float:y, Float:z,\n\tworldid = -1);",
+			want: []string{"CreateDynamicObject"},
+		},
+		{
+			name: "comments",
+			src:  "// native Commented(a);\n/* native Blocked(a);\nnative AlsoBlocked(); */\nnative Kept();",
+			want: []string{"Kept"},
+		},
+		{
+			name: "strings",
+			src:  "#define URL \"http://example.com\" // native InComment();\nnative Real();",
+			want: []string{"Real"},
+		},
+		{
+			name: "not a native",
+			src:  "stock nativeish(a) {}\n#define native_name(%0) %0\nforward OnNative();",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Declared([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Declared() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	got := ParseManifest([]byte("# natives of mysql\nmysql_connect\n\n  mysql_query  # since R40\r\n"))
+	if want := []string{"mysql_connect", "mysql_query"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseManifest() = %q, want %q", got, want)
+	}
+}
+
+// checkProject returns a project whose includes declare natives of
+// open.mp, of plugins and of a package
+func checkProject(root string) Project {
+	file := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+	fsys := vfs.NewMem(map[string][]byte{
+		file("qawno/include/open.mp.inc"):                    []byte("native SetGameModeText(const string[]);"),
+		file("qawno/include/omp_player.inc"):                 []byte("native GetPlayerName(playerid, name[], len);"),
+		file("qawno/include/float.inc"):                      []byte("native Float:floatsqroot(Float:value);"),
+		file("server/include/omp_core.inc"):                  []byte("native print(const string[]);"),
+		file("include/streamer.inc"):                         []byte("native CreateDynamicObject(modelid);"),
+		file("include/a_mysql.inc"):                          []byte("native mysql_connect(const host[]);"),
+		file("include/sscanf2.inc"):                          []byte("native sscanf(const data[], const format[], {Float,_}:...);"),
+		file("include/Pawn.RakNet.inc"):                      []byte("native PR_SendPacket(BitStream:bs, playerid);"),
+		file("include/unknown.inc"):                          []byte("native Unknown_Native();"),
+		file("plugins/mysql.so"):                             []byte("elf"),
+		file("plugins/mysql.natives"):                        []byte("mysql_query\nmysql_close\n"),
+		file("dependencies/discord/include/discord.inc"):     []byte("native DCC_Connect(const token[]);"),
+		file("dependencies/discord/plugins/discord.so"):      []byte("elf"),
+		file("dependencies/discord/plugins/discord.natives"): []byte("DCC_Ping"),
+	})
+	return Project{
+		FS:          fsys,
+		Root:        root,
+		IncludeDirs: []string{file("include"), file("qawno/include"), file("dependencies/discord/include")},
+		RuntimeDirs: []string{file("server/include")},
+		Plugins: []Plugin{
+			{Name: "streamer"},
+			{Name: "mysql", File: file("plugins/mysql.so")},
+			{Name: "sscanf", Includes: []string{"sscanf2.inc"}},
+			{Name: "Pawn.RakNet", Includes: []string{"include/Pawn.RakNet.inc"}},
+		},
+		Packages: []Package{{
+			Name:        "discord",
+			IncludeDirs: []string{file("dependencies/discord/include")},
+			Files:       []string{file("dependencies/discord/plugins/discord.so")},
+		}},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "gm")
+	result, err := Check(checkProject(root), []string{
+		"SetGameModeText", "GetPlayerName", "floatsqroot", "print",
+		"CreateDynamicObject", "mysql_connect", "mysql_query", "sscanf", "PR_SendPacket",
+		"DCC_Connect", "DCC_Ping", "Unknown_Native", "Undeclared", "Unknown_Native",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.RuntimeFound {
+		t.Error("the runtime includes were not found")
+	}
+
+	providers := map[string]string{
+		"SetGameModeText":     Runtime,
+		"GetPlayerName":       Runtime,
+		"floatsqroot":         Runtime,
+		"print":               Runtime,
+		"CreateDynamicObject": "plugin streamer",
+		"mysql_connect":       "plugin mysql",
+		"mysql_query":         "plugin mysql",
+		"sscanf":              "plugin sscanf",
+		"PR_SendPacket":       "plugin Pawn.RakNet",
+		"DCC_Connect":         "package discord",
+		"DCC_Ping":            "package discord",
+	}
+	for name, want := range providers {
+		if got := result.Providers[name]; got != want {
+			t.Errorf("%s is provided by %q, want %q", name, got, want)
+		}
+	}
+
+	want := []Missing{
+		{Name: "Undeclared"},
+		{Name: "Unknown_Native", DeclaredIn: filepath.Join("include", "unknown.inc")},
+	}
+	if !reflect.DeepEqual(result.Missing, want) {
+		t.Errorf("missing = %+v, want %+v", result.Missing, want)
+	}
+}
+
+func TestCheckPluginIncludeNames(t *testing.T) {
+	// sscanf's include is sscanf2.inc, so without includes its natives
+	// are not attributed to it
+	root := filepath.Join(t.TempDir(), "gm")
+	p := checkProject(root)
+	p.Plugins[2].Includes = nil
+
+	result, err := Check(p, []string{"sscanf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Missing{{Name: "sscanf", DeclaredIn: filepath.Join("include", "sscanf2.inc")}}
+	if !reflect.DeepEqual(result.Missing, want) {
+		t.Errorf("missing = %+v, want %+v", result.Missing, want)
+	}
+}
+
+func TestCheckWithoutRuntime(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "gm")
+	p := checkProject(root)
+	p.IncludeDirs = []string{filepath.Join(root, "include")}
+	p.RuntimeDirs = nil
+
+	result, err := Check(p, []string{"print"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RuntimeFound || len(result.Missing) != 1 {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -0,0 +1,283 @@
+package packager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// ManifestFile describes the package; it sits next to the packaged files
+const ManifestFile = "ompcli-package.json"
+
+// DefaultProfile is the build profile packages are made with
+const DefaultProfile = "production"
+
+// DefaultDir is where packages are written, relative to the project root
+const DefaultDir = "dist"
+
+// SourceDateEnv overrides the timestamp of packaged files, see
+// https://reproducible-builds.org/specs/source-date-epoch/
+const SourceDateEnv = "SOURCE_DATE_EPOCH"
+
+// defaultTime is used when neither SOURCE_DATE_EPOCH nor a git commit gives
+// a timestamp. Zip cannot store times before 1980.
+var defaultTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// unsafeChars are replaced in archive names
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._+-]+`)
+
+// Options controls how a package is made
+type Options struct {
+	// Format is "tar.gz" or "zip"; empty picks zip for Windows servers
+	// and tar.gz otherwise
+	Format string
+
+	// OutputDir is where the archive is written; empty means dist/
+	OutputDir string
+}
+
+// Manifest describes the contents of a package
+type Manifest struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Build   BuildInfo `json:"build"`
+	Files   []File    `json:"files"`
+}
+
+// BuildInfo records how the packaged build was made
+type BuildInfo struct {
+	Profile       string `json:"profile,omitempty"`
+	TargetOS      string `json:"target_os"`
+	PawnccVersion string `json:"pawncc_version,omitempty"`
+	ServerVersion string `json:"server_version,omitempty"`
+	GitCommit     string `json:"git_commit,omitempty"`
+	GitDirty      bool   `json:"git_dirty,omitempty"`
+}
+
+// File is a packaged file and its SHA-256
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BuildNotice returns a note to print before building the project rooted at
+// root with profile for targetOS, when that build replaces one build/ holds
+// made with another profile or for another OS, such as a development build.
+// It returns "" otherwise.
+func BuildNotice(root, profile, targetOS string) string {
+	manifest, err := stage.LoadManifest(filepath.Join(root, "build"))
+	if err != nil || manifest.Build == nil {
+		return ""
+	}
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+	last := manifest.Build
+	if last.Profile == profile && last.TargetOS == targetOS {
+		return ""
+	}
+	return fmt.Sprintf("Note: this replaces the build in build/, made with %s for %s; run ompcli build to rebuild it", configName(last.Profile), last.TargetOS)
+}
+
+// configName returns the server configuration a profile stages
+func configName(profile string) string {
+	if profile == "" {
+		return "config.json"
+	}
+	return "config." + profile + ".json"
+}
+
+// Package archives the build of the project rooted at root and returns the
+// path of the archive. The files are those the last build staged, along
+// with the compiled script; files the server created at runtime, such as
+// logs, are left out. The profile, target OS and compiler version are the
+// ones the build recorded. Entries are sorted and carry the same timestamp, so
+// packaging the same build twice gives identical archives.
+func Package(root string, opts Options) (string, error) {
+	config, err := utils.GetProjectConfig(root)
+	if err != nil {
+		return "", err
+	}
+	if config.Version == "" {
+		return "", fmt.Errorf("project.json has no version to name the package with")
+	}
+
+	staged, err := lastBuild(root, config)
+	if err != nil {
+		return "", err
+	}
+	files := staged.Files
+
+	if opts.Format == "" {
+		opts.Format = "tar.gz"
+		if staged.Build.TargetOS == "windows" {
+			opts.Format = "zip"
+		}
+	}
+	if opts.Format != "tar.gz" && opts.Format != "zip" {
+		return "", fmt.Errorf("unsupported package format %q (use tar.gz or zip)", opts.Format)
+	}
+
+	base := Name(config, staged.Build.TargetOS)
+	manifest := Manifest{
+		Name:    config.Name,
+		Version: config.Version,
+		Build: BuildInfo{
+			Profile:       staged.Build.Profile,
+			TargetOS:      staged.Build.TargetOS,
+			PawnccVersion: staged.Build.PawnccVersion,
+			ServerVersion: config.ServerVersion,
+		},
+		Files: make([]File, 0, len(files)),
+	}
+	manifest.Build.GitCommit, manifest.Build.GitDirty = gitCommit(root)
+
+	entries := make([]archive.Entry, 0, len(files)+1)
+	buildDir := filepath.Join(root, "build")
+	for _, file := range files {
+		src := filepath.Join(buildDir, filepath.FromSlash(file))
+		info, err := os.Stat(src)
+		if err != nil {
+			return "", fmt.Errorf("%s is missing from the build, run ompcli build first", file)
+		}
+		sum, err := hashFile(src)
+		if err != nil {
+			return "", err
+		}
+		manifest.Files = append(manifest.Files, File{Path: file, Size: info.Size(), SHA256: sum})
+		entries = append(entries, archive.Entry{Name: path.Join(base, file), Path: src, Mode: info.Mode()})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	entries = append(entries, archive.Entry{Name: path.Join(base, ManifestFile), Data: append(data, '\n'), Mode: 0644})
+
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Join(root, DefaultDir)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	output := filepath.Join(outputDir, base+"."+opts.Format)
+	if err := archive.Create(output, entries, sourceDate(root)); err != nil {
+		return "", fmt.Errorf("failed to write package: %w", err)
+	}
+	return output, nil
+}
+
+// Name returns the base name of a package: <name>-<version>-<os>
+func Name(config *utils.ProjectConfig, targetOS string) string {
+	name := strings.Trim(unsafeChars.ReplaceAllString(config.Name, "-"), "-")
+	if name == "" {
+		name = "package"
+	}
+	version := strings.Trim(unsafeChars.ReplaceAllString(config.Version, "-"), "-")
+	return name + "-" + version + "-" + targetOS
+}
+
+// lastBuild returns the staging manifest of the last build, which lists
+// its files, sorted, and how it was made
+func lastBuild(root string, config *utils.ProjectConfig) (*stage.Manifest, error) {
+	buildDir := filepath.Join(root, "build")
+	manifest, err := stage.LoadManifest(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("nothing has been built yet, run ompcli build first")
+	}
+	if manifest.Build == nil {
+		return nil, fmt.Errorf("the last build did not record how it was made, run ompcli build first")
+	}
+
+	// The manifest lists the compiled script too; check it is really there
+	output := path.Clean(filepath.ToSlash(config.OutputFile))
+	if _, err := os.Stat(filepath.Join(buildDir, filepath.FromSlash(output))); err != nil {
+		return nil, fmt.Errorf("%s has not been compiled, run ompcli build first", output)
+	}
+
+	return manifest, nil
+}
+
+// sourceDate returns the timestamp given to every packaged file:
+// SOURCE_DATE_EPOCH, else the time of the current git commit, else 1980
+func sourceDate(root string) time.Time {
+	if value := os.Getenv(SourceDateEnv); value != "" {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return clampTime(time.Unix(seconds, 0))
+		}
+	}
+
+	out, err := git(root, "log", "-1", "--format=%ct")
+	if err == nil {
+		if seconds, err := strconv.ParseInt(out, 10, 64); err == nil {
+			return clampTime(time.Unix(seconds, 0))
+		}
+	}
+	return defaultTime
+}
+
+// clampTime keeps t within what zip archives can store
+func clampTime(t time.Time) time.Time {
+	if t.Before(defaultTime) {
+		return defaultTime
+	}
+	return t.UTC()
+}
+
+// gitCommit returns the commit the project is at and whether it has
+// uncommitted changes. Projects outside of git give an empty commit.
+func gitCommit(root string) (string, bool) {
+	commit, err := git(root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false
+	}
+	status, err := git(root, "status", "--porcelain")
+	return commit, err == nil && status != ""
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(p string) (string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
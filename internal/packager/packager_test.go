@@ -0,0 +1,147 @@
+package packager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+)
+
+// builtProject writes a project whose last build staged its script and
+// config.json, recording build in the staging manifest
+func builtProject(t *testing.T, build *stage.Build) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"project.json":           `{"name": "gm", "version": "1.2.0", "main_file": "gamemodes/gm.pwn", "output_file": "gamemodes/gm.amx"}`,
+		"build/gamemodes/gm.amx": "amx",
+		"build/config.json":      `{"port": 7777}`,
+	}
+	manifest, err := json.Marshal(stage.Manifest{Build: build, Files: []string{"config.json", "gamemodes/gm.amx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["build/"+stage.ManifestFile] = string(manifest)
+
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// packageManifest reads ompcli-package.json from a .tar.gz package
+func packageManifest(t *testing.T, path string) Manifest {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("%s not found in the package: %v", ManifestFile, err)
+		}
+		if strings.HasSuffix(header.Name, "/"+ManifestFile) {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			return manifest
+		}
+	}
+}
+
+func TestPackageRecordsBuild(t *testing.T) {
+	t.Setenv(SourceDateEnv, "0")
+	root := builtProject(t, &stage.Build{Profile: "staging", TargetOS: "linux", PawnccVersion: "3.10.10"})
+
+	path, err := Package(root, Options{})
+	if err != nil {
+		t.Fatalf("Package: %v", err)
+	}
+	if want := filepath.Join(root, DefaultDir, "gm-1.2.0-linux.tar.gz"); path != want {
+		t.Errorf("Package() = %s, want %s", path, want)
+	}
+
+	build := packageManifest(t, path).Build
+	if build.Profile != "staging" || build.TargetOS != "linux" || build.PawnccVersion != "3.10.10" {
+		t.Errorf("build = %+v", build)
+	}
+}
+
+func TestPackageWithoutBuildRecord(t *testing.T) {
+	root := builtProject(t, nil)
+	if _, err := Package(root, Options{}); err == nil || !strings.Contains(err.Error(), "run ompcli build first") {
+		t.Fatalf("Package error = %v", err)
+	}
+}
+
+func TestBuildNotice(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    *stage.Build
+		profile  string
+		targetOS string
+		want     string
+	}{
+		{name: "never built", profile: DefaultProfile, targetOS: "linux"},
+		{
+			name:     "development build",
+			build:    &stage.Build{TargetOS: "linux"},
+			profile:  DefaultProfile,
+			targetOS: "linux",
+			want:     "made with config.json for linux",
+		},
+		{
+			name:     "other profile",
+			build:    &stage.Build{Profile: "staging", TargetOS: "linux"},
+			profile:  DefaultProfile,
+			targetOS: "linux",
+			want:     "made with config.staging.json for linux",
+		},
+		{
+			name:     "other OS",
+			build:    &stage.Build{Profile: DefaultProfile, TargetOS: "windows"},
+			profile:  DefaultProfile,
+			targetOS: "linux",
+			want:     "made with config.production.json for windows",
+		},
+		{
+			name:     "same build",
+			build:    &stage.Build{Profile: DefaultProfile, TargetOS: "linux"},
+			profile:  DefaultProfile,
+			targetOS: "linux",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := builtProject(t, tt.build)
+			got := BuildNotice(root, tt.profile, tt.targetOS)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("BuildNotice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
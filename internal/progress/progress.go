@@ -0,0 +1,302 @@
+// Package progress renders the events of a build, either as a live
+// display with a line per project on a terminal or as plain lines for logs
+// and CI.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/builder"
+)
+
+// Progress modes
+const (
+	ModeAuto  = "auto"
+	ModeTTY   = "tty"
+	ModePlain = "plain"
+)
+
+// refreshInterval is how often the live display is redrawn while a
+// project builds
+const refreshInterval = 100 * time.Millisecond
+
+// spinner is drawn next to projects that are building
+const spinner = `-\|/`
+
+// Interactive reports whether mode asks for the live display on f. In
+// ModeAuto that is the case when f is a terminal and no CI variable is set.
+func Interactive(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "", ModeAuto:
+		if os.Getenv("CI") != "" {
+			return false, nil
+		}
+		info, err := f.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	case ModeTTY:
+		return true, nil
+	case ModePlain:
+		return false, nil
+	}
+	return false, fmt.Errorf("progress must be %q, %q or %q", ModeAuto, ModeTTY, ModePlain)
+}
+
+// Renderer shows build events as they arrive. Its Event method can be used
+// as builder.Options.OnEvent.
+type Renderer struct {
+	w   io.Writer
+	tty bool
+
+	mu      sync.Mutex
+	targets []*target
+	byName  map[string]*target
+	width   int
+	drawn   int
+	frame   int
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// target is the state of one project
+type target struct {
+	name     string
+	state    string
+	status   string
+	start    time.Time
+	errors   int
+	warnings int
+}
+
+// Target states
+const (
+	stateWaiting  = "waiting"
+	stateBuilding = "building"
+	stateDone     = "done"
+	stateFailed   = "failed"
+	stateSkipped  = "skipped"
+)
+
+// New returns a renderer writing to w. With tty set it draws a live display
+// listing targets, the projects about to be built, in order; otherwise it
+// prints a line per event. Close must be called once the build is over.
+func New(w io.Writer, tty bool, targets []string) *Renderer {
+	r := &Renderer{w: w, tty: tty, byName: map[string]*target{}, stop: make(chan struct{})}
+	for _, name := range targets {
+		r.add(name)
+	}
+
+	if tty {
+		r.stopped.Add(1)
+		go r.refresh()
+	}
+	return r
+}
+
+// Event shows a build event
+func (r *Renderer) Event(e builder.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e := e.(type) {
+	case builder.TargetStarted:
+		t := r.target(e.Project)
+		t.state, t.status, t.start = stateBuilding, "loading", time.Now()
+		r.plain(t, "building")
+
+	case builder.CompilerInvoked:
+		t := r.target(e.Project)
+		t.status = "compiling " + e.Source
+		r.plain(t, "compiling %s (%s)", e.Source, filepath.Base(e.Compiler))
+
+	case builder.DiagnosticReported:
+		t := r.target(e.Project)
+		if e.Diagnostic.Severity == builder.SeverityError {
+			t.errors++
+		} else {
+			t.warnings++
+		}
+		r.message(t, e.Diagnostic.String())
+
+	case builder.Notice:
+		r.message(r.target(e.Project), "warning: "+e.Message)
+
+	case builder.FilesStaged:
+		t := r.target(e.Project)
+		t.status = "staged"
+		r.plain(t, "staged %s (%d copied, %d linked, %d unchanged, %d removed)",
+			plural(e.Stats.Copied+e.Stats.Linked+e.Stats.Skipped, "file"),
+			e.Stats.Copied, e.Stats.Linked, e.Stats.Skipped, e.Stats.Pruned)
+
+	case builder.TargetFinished:
+		t := r.target(e.Project)
+		switch {
+		case e.Err != nil:
+			t.state = stateFailed
+			t.status = fmt.Sprintf("failed after %s%s", seconds(time.Since(t.start)), r.counts(t))
+		case e.Result != nil && e.Result.Skipped:
+			t.state, t.status = stateSkipped, "nothing to compile"
+		default:
+			t.state = stateDone
+			t.status = fmt.Sprintf("built in %s%s", seconds(time.Since(t.start)), r.counts(t))
+		}
+		r.plain(t, "%s", t.status)
+	}
+
+	r.draw()
+}
+
+// Close stops the live display, leaving its last state on screen
+func (r *Renderer) Close() {
+	if !r.tty {
+		return
+	}
+
+	r.mu.Lock()
+	select {
+	case <-r.stop:
+		r.mu.Unlock()
+		return
+	default:
+		close(r.stop)
+	}
+	r.mu.Unlock()
+	r.stopped.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draw()
+	r.drawn = 0
+}
+
+// refresh redraws the live display so that spinners and times move
+func (r *Renderer) refresh() {
+	defer r.stopped.Done()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.frame++
+			r.draw()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// add appends a target to the display
+func (r *Renderer) add(name string) *target {
+	t := &target{name: name, state: stateWaiting, status: "waiting"}
+	r.targets = append(r.targets, t)
+	r.byName[name] = t
+	r.width = max(r.width, len(name))
+	return t
+}
+
+// target returns the target of a project, adding projects that were not
+// announced
+func (r *Renderer) target(name string) *target {
+	if t, ok := r.byName[name]; ok {
+		return t
+	}
+	return r.add(name)
+}
+
+// plain prints a line for t in plain mode
+func (r *Renderer) plain(t *target, format string, args ...any) {
+	if !r.tty {
+		fmt.Fprintf(r.w, "[%s] %s\n", t.name, fmt.Sprintf(format, args...))
+	}
+}
+
+// message prints a line for t in both modes; the live display shows it
+// above the list of projects
+func (r *Renderer) message(t *target, text string) {
+	if r.tty {
+		r.clear()
+	}
+	fmt.Fprintf(r.w, "[%s] %s\n", t.name, text)
+}
+
+// clear moves the cursor back to the start of the live display
+func (r *Renderer) clear() {
+	if r.drawn > 0 {
+		fmt.Fprintf(r.w, "\x1b[%dA", r.drawn)
+	}
+	fmt.Fprint(r.w, "\x1b[2K")
+	r.drawn = 0
+}
+
+// draw redraws the live display in place
+func (r *Renderer) draw() {
+	if !r.tty {
+		return
+	}
+
+	var b strings.Builder
+	if r.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", r.drawn)
+	}
+	for _, t := range r.targets {
+		fmt.Fprintf(&b, "\x1b[2K %c %-*s  %s\n", r.mark(t), r.width, t.name, r.status(t))
+	}
+	fmt.Fprint(r.w, b.String())
+	r.drawn = len(r.targets)
+}
+
+// mark returns the character shown in front of a target
+func (r *Renderer) mark(t *target) byte {
+	switch t.state {
+	case stateBuilding:
+		return spinner[r.frame%len(spinner)]
+	case stateDone:
+		return '+'
+	case stateFailed:
+		return 'x'
+	}
+	return '.'
+}
+
+// status returns the text shown for a target in the live display
+func (r *Renderer) status(t *target) string {
+	if t.state != stateBuilding {
+		return t.status
+	}
+	return fmt.Sprintf("%s (%s%s)", t.status, seconds(time.Since(t.start)), r.counts(t))
+}
+
+// counts describes the diagnostics of a target so far
+func (r *Renderer) counts(t *target) string {
+	if t.errors == 0 && t.warnings == 0 {
+		if t.state == stateBuilding {
+			return ""
+		}
+		return " with 0 errors and 0 warnings"
+	}
+	if t.state == stateBuilding {
+		return ", " + plural(t.errors, "error") + ", " + plural(t.warnings, "warning")
+	}
+	return " with " + plural(t.errors, "error") + " and " + plural(t.warnings, "warning")
+}
+
+// seconds formats a duration with one decimal
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// plural returns "1 file" or "2 files"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
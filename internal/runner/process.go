@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ServerCommand describes the server process to start
+type ServerCommand struct {
+	// Path is the server executable, Args its arguments and Dir the build
+	// directory it runs in
+	Path string
+	Args []string
+	Dir  string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ServerProcess is the server of a project. Each one is started once.
+type ServerProcess interface {
+	// Start starts the server; cancelling ctx asks it to shut down
+	Start(ctx context.Context, cmd ServerCommand) error
+
+	// PID returns the process id of the started server
+	PID() int
+
+	// Wait waits for the server to exit and returns its exit code, -1
+	// when it was killed
+	Wait() (int, error)
+}
+
+// ExecProcess runs the server executable
+type ExecProcess struct {
+	cmd *exec.Cmd
+}
+
+// Start starts the server. When ctx is cancelled the server is
+// interrupted, and killed if it has not exited after StopTimeout.
+func (p *ExecProcess) Start(ctx context.Context, c ServerCommand) error {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Cancel = func() error { return interrupt(cmd.Process) }
+	cmd.WaitDelay = StopTimeout
+	cmd.Dir = c.Dir
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+
+	p.cmd = cmd
+	return cmd.Start()
+}
+
+// PID returns the process id of the server
+func (p *ExecProcess) PID() int {
+	return p.cmd.Process.Pid
+}
+
+// Wait waits for the server to exit
+func (p *ExecProcess) Wait() (int, error) {
+	err := p.cmd.Wait()
+	return p.cmd.ProcessState.ExitCode(), err
+}
+
+// interrupt asks a process to stop the way Ctrl+C does. Windows has no
+// such signal for other processes, so there it is killed.
+func interrupt(p *os.Process) error {
+	if runtime.GOOS == "windows" {
+		return p.Kill()
+	}
+	return p.Signal(os.Interrupt)
+}
+
+// FakeServer stands in for omp-server without running anything. It prints
+// its log lines once started and runs for Lifetime, or until its context
+// is cancelled, which shuts it down cleanly.
+type FakeServer struct {
+	// Log is written to the server's stdout when it starts
+	Log []string
+
+	// Lifetime is how long the server runs before exiting by itself; zero
+	// runs until the context is cancelled
+	Lifetime time.Duration
+
+	// ExitCode and Err are what the server exits with by itself
+	ExitCode int
+	Err      error
+
+	// StartErr, when set, makes starting the server fail
+	StartErr error
+
+	mu      sync.Mutex
+	started bool
+	command ServerCommand
+	ctx     context.Context
+}
+
+// Start records the command and prints the log
+func (s *FakeServer) Start(ctx context.Context, cmd ServerCommand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("server already started")
+	}
+	if s.StartErr != nil {
+		return s.StartErr
+	}
+	s.started, s.command, s.ctx = true, cmd, ctx
+
+	for _, line := range s.Log {
+		if cmd.Stdout != nil {
+			fmt.Fprintln(cmd.Stdout, line)
+		}
+	}
+	return nil
+}
+
+// PID returns a made-up process id
+func (s *FakeServer) PID() int {
+	return 4242
+}
+
+// Wait waits for the server's lifetime to end or its context to be
+// cancelled
+func (s *FakeServer) Wait() (int, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		return -1, fmt.Errorf("server not started")
+	}
+
+	var timeout <-chan time.Time
+	if s.Lifetime > 0 {
+		timer := time.NewTimer(s.Lifetime)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-timeout:
+		return s.ExitCode, s.Err
+	case <-ctx.Done():
+		return 0, nil
+	}
+}
+
+// Command returns the command the server was started with
+func (s *FakeServer) Command() ServerCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.command
+}
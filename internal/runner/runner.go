@@ -1,40 +1,137 @@
 package runner
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/weltschmerzie/omp-cli/internal/symbolicate"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+	"github.com/weltschmerzie/omp-cli/pkg/amx"
 	"github.com/weltschmerzie/omp-cli/pkg/utils"
 )
 
-// Run executes the open.mp project
-func Run(debug bool, port int) error {
-	// Check if we are in an open.mp project directory
-	if !utils.IsOpenMPProject() {
-		return errors.New("current directory is not an open.mp project")
+// Options controls how a project is run
+type Options struct {
+	// Debug starts the server in debug mode
+	Debug bool
+
+	// Port overrides the port from config.json when non-zero
+	Port int
+
+	// Standard streams of the server; nil means the process's own
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// OnEvent is called when the server starts and exits when set
+	OnEvent func(Event)
+
+	// Symbolicate adds the source function, file and line to the frames of
+	// crashdetect's AMX backtraces in the server output, when the compiled
+	// gamemode has debug information
+	Symbolicate bool
+
+	// Process runs the server; nil runs the server executable
+	Process ServerProcess
+
+	// FS holds the project and its build directory; nil means the real
+	// file system
+	FS vfs.FS
+}
+
+// StopTimeout is how long a server gets to shut down after its context is
+// cancelled before it is killed
+const StopTimeout = 10 * time.Second
+
+// Result describes a server that ran
+type Result struct {
+	// Project is the name of the project that was run
+	Project string
+
+	// PID is the process id of the server and Port the port it listened on
+	PID  int
+	Port int
+
+	// ExitCode is the exit code of the server, -1 when it was killed by a
+	// signal or never started
+	ExitCode int
+
+	// Duration is how long the server ran
+	Duration time.Duration
+}
+
+// Event is something that happened to a server, passed to Options.OnEvent
+type Event interface {
+	runEvent()
+}
+
+// ServerStarted is sent once the server process is running
+type ServerStarted struct {
+	Project string
+	PID     int
+	Port    int
+}
+
+// ServerExited is sent when the server process has ended. Err is set when
+// it did not exit cleanly.
+type ServerExited struct {
+	Project string
+	Result  *Result
+	Err     error
+}
+
+func (ServerStarted) runEvent() {}
+func (ServerExited) runEvent()  {}
+
+// Run executes the open.mp project rooted at root until the server exits
+// or ctx is cancelled, which asks the server to stop
+func Run(ctx context.Context, root string, opts Options) (*Result, error) {
+	result := &Result{Project: filepath.Base(root), ExitCode: -1}
+	debug, port := opts.Debug, opts.Port
+	stdout, stderr, stdin := opts.Stdout, opts.Stderr, opts.Stdin
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	fsys := vfs.Or(opts.FS)
+
+	// Check if root is an open.mp project directory
+	if !utils.IsOpenMPProjectFS(fsys, root) {
+		return result, fmt.Errorf("%s is not an open.mp project", root)
 	}
 
 	// Check if the project is built
-	buildDir := filepath.Join(".", "build")
-	if _, err := os.Stat(buildDir); os.IsNotExist(err) {
-		return errors.New("project is not built. Please run 'ompcli build' first")
+	buildDir := filepath.Join(root, "build")
+	if _, err := fsys.Stat(buildDir); os.IsNotExist(err) {
+		return result, errors.New("project is not built. Please run 'ompcli build' first")
 	}
 
 	// Get project configuration
-	config, err := utils.GetProjectConfig()
+	config, err := utils.GetProjectConfigFS(fsys, root)
 	if err != nil {
-		return fmt.Errorf("failed to get project configuration: %w", err)
+		return result, fmt.Errorf("failed to get project configuration: %w", err)
 	}
+	result.Project = config.Name
 
 	// Get server configuration
-	serverConfig, err := utils.GetServerConfig()
+	serverConfig, err := utils.GetServerConfigFS(fsys, root)
 	if err != nil {
-		return fmt.Errorf("failed to get server configuration: %w", err)
+		return result, fmt.Errorf("failed to get server configuration: %w", err)
 	}
 
 	// Determine server executable based on OS
@@ -45,19 +142,22 @@ func Run(debug bool, port int) error {
 	case "linux", "darwin":
 		serverExe = "omp-server"
 	default:
-		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return result, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
 
 	// Check if server executable exists
 	serverPath := filepath.Join(buildDir, serverExe)
-	if _, err := os.Stat(serverPath); os.IsNotExist(err) {
-		return fmt.Errorf("server executable not found at %s", serverPath)
+	if _, err := fsys.Stat(serverPath); os.IsNotExist(err) {
+		if config.ServerVersion == "" {
+			return result, fmt.Errorf("server executable not found at %s. Pin a server version with 'ompcli server use <version>' and run 'ompcli build'", serverPath)
+		}
+		return result, fmt.Errorf("server executable not found at %s. Please run 'ompcli build' first", serverPath)
 	}
 
 	// Check if the compiled gamemode exists
 	gamemodePath := filepath.Join(buildDir, config.OutputFile)
-	if _, err := os.Stat(gamemodePath); os.IsNotExist(err) {
-		return fmt.Errorf("compiled gamemode not found at %s. Please run 'ompcli build' first", gamemodePath)
+	if _, err := fsys.Stat(gamemodePath); os.IsNotExist(err) {
+		return result, fmt.Errorf("compiled gamemode not found at %s. Please run 'ompcli build' first", gamemodePath)
 	}
 
 	// Prepare command arguments
@@ -82,23 +182,119 @@ func Run(debug bool, port int) error {
 		args = append(args, "--gamemode="+relativeGamemodePath)
 	}
 
-	// Create command
-	cmd := exec.Command(serverPath, args...)
-
-	// Set working directory to build directory
-	cmd.Dir = buildDir
+	// Map crash traces of the gamemode back to its source
+	var flush []*symbolicate.Writer
+	if opts.Symbolicate {
+		stdout, stderr, flush = symbolicateOutput(fsys, gamemodePath, stdout, stderr)
+	}
 
-	// Connect standard I/O
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	// Create command, running in the build directory
+	cmd := ServerCommand{
+		Path:   serverPath,
+		Args:   args,
+		Dir:    buildDir,
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+	process := opts.Process
+	if process == nil {
+		process = &ExecProcess{}
+	}
 
 	// Run the server
-	fmt.Printf("Starting open.mp server on port %d...\n", port)
+	fmt.Fprintf(stdout, "Starting open.mp server on port %d...\n", port)
 	if debug {
-		fmt.Println("Debug mode enabled")
+		fmt.Fprintln(stdout, "Debug mode enabled")
+	}
+	fmt.Fprintf(stdout, "Using gamemode: %s\n", filepath.Base(config.OutputFile))
+
+	result.Port = port
+	start := time.Now()
+	if err := process.Start(ctx, cmd); err != nil {
+		return result, fmt.Errorf("failed to start the server: %w", err)
+	}
+	result.PID = process.PID()
+	if opts.OnEvent != nil {
+		opts.OnEvent(ServerStarted{Project: result.Project, PID: result.PID, Port: port})
+	}
+
+	result.ExitCode, err = process.Wait()
+	result.Duration = time.Since(start)
+	for _, w := range flush {
+		w.Flush()
+	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if opts.OnEvent != nil {
+		opts.OnEvent(ServerExited{Project: result.Project, Result: result, Err: err})
+	}
+	return result, err
+}
+
+// symbolicateOutput wraps the server's output streams so that backtraces
+// of the gamemode are symbolicated. A gamemode without debug information
+// leaves them as they are.
+func symbolicateOutput(fsys vfs.FS, gamemodePath string, stdout, stderr io.Writer) (io.Writer, io.Writer, []*symbolicate.Writer) {
+	data, err := fsys.ReadFile(gamemodePath)
+	if err != nil {
+		return stdout, stderr, nil
+	}
+	script, err := amx.Parse(data)
+	if err != nil || !script.DebugInfo {
+		return stdout, stderr, nil
+	}
+
+	// Each stream keeps track of its own backtrace
+	var writers []*symbolicate.Writer
+	wrap := func(w io.Writer) io.Writer {
+		s, err := symbolicate.New(script, filepath.Base(gamemodePath))
+		if err != nil {
+			return w
+		}
+		sw := symbolicate.NewWriter(w, s)
+		writers = append(writers, sw)
+		return sw
 	}
-	fmt.Printf("Using gamemode: %s\n", filepath.Base(config.OutputFile))
+	stdout, stderr = wrap(stdout), wrap(stderr)
+	return stdout, stderr, writers
+}
+
+// PrefixWriter prefixes every line written to it, so that the output of
+// several servers running side by side can be told apart
+type PrefixWriter struct {
+	mu      *sync.Mutex
+	w       io.Writer
+	prefix  string
+	midLine bool
+}
 
-	return cmd.Run()
+// NewPrefixWriter returns a writer that prefixes each line with prefix.
+// Writers sharing mu never interleave partial lines.
+func NewPrefixWriter(w io.Writer, prefix string, mu *sync.Mutex) *PrefixWriter {
+	return &PrefixWriter{mu: mu, w: w, prefix: prefix}
+}
+
+// Write implements io.Writer
+func (p *PrefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !p.midLine {
+			buf.WriteString(p.prefix)
+		}
+		buf.Write(line)
+		p.midLine = line[len(line)-1] != '\n'
+	}
+
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(data), nil
 }
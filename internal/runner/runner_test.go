@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// builtProject returns an in-memory gamemode project with a build
+func builtProject(root, serverConfig string) *vfs.Mem {
+	exe := "omp-server"
+	if runtime.GOOS == "windows" {
+		exe = "omp-server.exe"
+	}
+	return vfs.NewMem(map[string][]byte{
+		filepath.Join(root, "project.json"):                 []byte(`{"name": "gm", "main_file": "gamemodes/gm.pwn", "output_file": "gamemodes/gm.amx"}`),
+		filepath.Join(root, "config.json"):                  []byte(serverConfig),
+		filepath.Join(root, "build", exe):                   []byte("server"),
+		filepath.Join(root, "build", "gamemodes", "gm.amx"): []byte("amx"),
+	})
+}
+
+func TestRunWithFakeServer(t *testing.T) {
+	tests := []struct {
+		name         string
+		serverConfig string
+		opts         Options
+		wantArgs     []string
+		wantPort     int
+	}{
+		{
+			name:         "defaults",
+			serverConfig: `{"port": 7777, "maxplayers": 50}`,
+			wantArgs:     []string{"--gamemode=" + filepath.Join("gamemodes", "gm.amx")},
+			wantPort:     7777,
+		},
+		{
+			name:         "debug and port",
+			serverConfig: `{"port": 7777, "maxplayers": 50, "gamemode": "gm"}`,
+			opts:         Options{Debug: true, Port: 8888},
+			wantArgs:     []string{"--debug", "--port=8888"},
+			wantPort:     8888,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "gm")
+			server := &FakeServer{Log: []string{"Started server"}, Lifetime: time.Millisecond, ExitCode: 3}
+
+			var out bytes.Buffer
+			var events []string
+			opts := tt.opts
+			opts.FS = builtProject(root, tt.serverConfig)
+			opts.Process = server
+			opts.Stdin = strings.NewReader("")
+			opts.Stdout, opts.Stderr = &out, &out
+			opts.OnEvent = func(e Event) { events = append(events, reflect.TypeOf(e).Name()) }
+
+			result, err := Run(context.Background(), root, opts)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if result.Project != "gm" || result.Port != tt.wantPort || result.PID != 4242 || result.ExitCode != 3 {
+				t.Errorf("result = %+v", result)
+			}
+			if want := []string{"ServerStarted", "ServerExited"}; !reflect.DeepEqual(events, want) {
+				t.Errorf("events = %v, want %v", events, want)
+			}
+
+			cmd := server.Command()
+			if !reflect.DeepEqual(cmd.Args, tt.wantArgs) {
+				t.Errorf("args = %q, want %q", cmd.Args, tt.wantArgs)
+			}
+			if cmd.Dir != filepath.Join(root, "build") {
+				t.Errorf("dir = %s", cmd.Dir)
+			}
+			if !strings.Contains(out.String(), "Started server") {
+				t.Errorf("output = %q", out.String())
+			}
+		})
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "gm")
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &FakeServer{}
+
+	var out bytes.Buffer
+	opts := Options{FS: builtProject(root, `{"port": 7777, "maxplayers": 50}`), Process: server, Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	opts.OnEvent = func(e Event) {
+		if _, ok := e.(ServerStarted); ok {
+			cancel()
+		}
+	}
+
+	_, err := Run(ctx, root, opts)
+	if err != context.Canceled {
+		t.Fatalf("Run error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRunNotBuilt(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "gm")
+	fsys := vfs.NewMem(map[string][]byte{
+		filepath.Join(root, "project.json"): []byte(`{"name": "gm", "output_file": "gamemodes/gm.amx"}`),
+	})
+
+	_, err := Run(context.Background(), root, Options{FS: fsys, Process: &FakeServer{}})
+	if err == nil || !strings.Contains(err.Error(), "not built") {
+		t.Fatalf("Run error = %v, want a not built error", err)
+	}
+}
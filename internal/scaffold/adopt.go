@@ -0,0 +1,249 @@
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+)
+
+// mainFunc finds the entry point of a gamemode
+var mainFunc = regexp.MustCompile(`(?m)^\s*main\s*\(\s*\)`)
+
+// Existing describes the sources found in a folder that init adopts
+type Existing struct {
+	// Scripts are the .pwn files in gamemodes/, filterscripts/ and the
+	// folder itself, slash-separated
+	Scripts []string
+
+	// MainFile is the script chosen as the project's main file and Type
+	// the kind of project it makes
+	MainFile string
+	Type     string
+
+	// Plugins are the plugins named in server.cfg or found in plugins/,
+	// without extension
+	Plugins []string
+
+	// IncludePaths are include folders found next to the sources
+	IncludePaths []string
+
+	// ServerCfg holds the settings of a SA-MP style server.cfg, converted
+	// to config.json keys
+	ServerCfg map[string]any
+}
+
+// Detect looks for existing sources, server.cfg and plugins in dir. It
+// returns nil when there is nothing to adopt.
+func Detect(dir, name string) (*Existing, error) {
+	e := &Existing{}
+	for _, pattern := range []string{"gamemodes/*.pwn", "filterscripts/*.pwn", "*.pwn"} {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+			e.Scripts = append(e.Scripts, filepath.ToSlash(rel))
+		}
+	}
+
+	plugins := map[string]bool{}
+	data, err := os.ReadFile(filepath.Join(dir, "server.cfg"))
+	if err == nil {
+		var cfgPlugins []string
+		e.ServerCfg, cfgPlugins = parseServerCfg(data)
+		for _, plugin := range cfgPlugins {
+			plugins[plugin] = true
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// components/ of a server install holds open.mp's own components,
+	// which the server provides, so only plugins/ is looked at
+	entries, _ := os.ReadDir(filepath.Join(dir, "plugins"))
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".so" || ext == ".dll") {
+			plugins[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = true
+		}
+	}
+	for plugin := range plugins {
+		e.Plugins = append(e.Plugins, plugin)
+	}
+	sort.Strings(e.Plugins)
+
+	if info, err := os.Stat(filepath.Join(dir, "include")); err == nil && info.IsDir() {
+		e.IncludePaths = []string{"include"}
+	}
+
+	if len(e.Scripts) == 0 && e.ServerCfg == nil && len(e.Plugins) == 0 {
+		return nil, nil
+	}
+
+	gamemode, _ := e.ServerCfg["gamemode"].(string)
+	e.MainFile, e.Type = pickMain(dir, e.Scripts, gamemode, name)
+	return e, nil
+}
+
+// Adopt points the rendered project.json and config.json at the existing
+// sources and drops the template's own scripts
+func (e *Existing) Adopt(files []File) ([]File, error) {
+	var adopted []File
+	for _, file := range files {
+		ext := strings.ToLower(path.Ext(file.Path))
+		if e.MainFile != "" && (ext == ".pwn" || ext == ".inc") {
+			continue
+		}
+
+		var err error
+		switch file.Path {
+		case "project.json":
+			file.Data, err = e.adoptProject(file.Data)
+		case "config.json":
+			file.Data, err = setKeys(file.Data, e.ServerCfg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		adopted = append(adopted, file)
+	}
+	return adopted, nil
+}
+
+// adoptProject fills in the detected settings of project.json
+func (e *Existing) adoptProject(data []byte) ([]byte, error) {
+	settings := map[string]any{}
+	if e.MainFile != "" {
+		base := strings.TrimSuffix(path.Base(e.MainFile), path.Ext(e.MainFile))
+		folder := "gamemodes"
+		if e.Type == "filterscript" {
+			folder = "filterscripts"
+		}
+		settings["main_file"] = e.MainFile
+		settings["output_file"] = path.Join(folder, base+".amx")
+		if e.Type != "gamemode" {
+			settings["type"] = e.Type
+		}
+	}
+	if len(e.Plugins) > 0 {
+		settings["plugins"] = e.Plugins
+	}
+	if len(e.IncludePaths) > 0 {
+		settings["include_paths"] = e.IncludePaths
+	}
+	if e.ServerCfg != nil {
+		// The generated config.json replaces server.cfg
+		settings["server_cfg"] = "config.json"
+	}
+	return setKeys(data, settings)
+}
+
+// pickMain chooses the main script: the gamemode server.cfg starts, the
+// gamemode named like the project, a script with main() or the first one
+func pickMain(dir string, scripts []string, gamemode, name string) (string, string) {
+	if len(scripts) == 0 {
+		return "", ""
+	}
+
+	for _, want := range []string{gamemode, name} {
+		for _, script := range scripts {
+			if want != "" && script == path.Join("gamemodes", want+".pwn") {
+				return script, "gamemode"
+			}
+		}
+	}
+
+	for _, script := range scripts {
+		if strings.HasPrefix(script, "filterscripts/") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(script)))
+		if err == nil && mainFunc.Match(data) {
+			return script, "gamemode"
+		}
+	}
+
+	if strings.HasPrefix(scripts[0], "filterscripts/") {
+		return scripts[0], "filterscript"
+	}
+	return scripts[0], "gamemode"
+}
+
+// parseServerCfg reads a SA-MP style server.cfg into config.json keys and
+// the plugins it loads
+func parseServerCfg(data []byte) (map[string]any, []string) {
+	settings := map[string]any{}
+	var plugins []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(key) {
+		case "hostname", "language", "weburl", "password":
+			settings[strings.ToLower(key)] = value
+		case "rcon_password":
+			settings["rcon_password"] = value
+		case "port", "maxplayers":
+			if n, err := strconv.Atoi(value); err == nil {
+				settings[strings.ToLower(key)] = n
+			}
+		case "gamemode0":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				settings["gamemode"] = fields[0]
+			}
+		case "plugins":
+			for _, plugin := range strings.Fields(value) {
+				plugins = append(plugins, strings.TrimSuffix(plugin, filepath.Ext(plugin)))
+			}
+		}
+	}
+	return settings, plugins
+}
+
+// setKeys sets top-level keys of a JSON document, keeping its layout
+func setKeys(data []byte, settings map[string]any) ([]byte, error) {
+	if len(settings) == 0 {
+		return data, nil
+	}
+
+	doc, err := jsonedit.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, err := jsonedit.FromGo(settings[key])
+		if err != nil {
+			return nil, err
+		}
+		if err := doc.Set(jsonedit.Path{{Key: key}}, value); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Bytes(), nil
+}
@@ -0,0 +1,314 @@
+package scaffold
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ManifestFile describes a template; it is not copied into projects
+const ManifestFile = "template.json"
+
+// DefaultTemplate is used when init is not given a template
+const DefaultTemplate = "gamemode"
+
+//go:embed all:templates
+var builtins embed.FS
+
+// placeholder matches {{variable}} in template files and paths
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// identChars are replaced to turn a project name into a Pawn identifier
+var identChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Manifest represents the structure of template.json
+type Manifest struct {
+	Description string `json:"description"`
+
+	// Variables declares extra variables and their default values
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// Hooks are shell commands run in the project after it is created
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+// Template is a folder of files that a new project is created from
+type Template struct {
+	Name     string
+	Manifest Manifest
+
+	fsys    fs.FS
+	cleanup func()
+}
+
+// File is a rendered template file, with a slash-separated path relative
+// to the project
+type File struct {
+	Path string
+	Data []byte
+	Mode fs.FileMode
+}
+
+// Builtins lists the templates that come with ompcli, sorted by name
+func Builtins() ([]*Template, error) {
+	entries, err := fs.ReadDir(builtins, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []*Template
+	for _, entry := range entries {
+		t, err := builtin(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// Load returns the template named by source: a built-in template, a local
+// directory or a git repository. Git sources are URLs or end in .git, and
+// may pick a branch or tag with #ref. Close the template when done.
+func Load(source string) (*Template, error) {
+	if source == "" {
+		source = DefaultTemplate
+	}
+
+	if isGit(source) {
+		return clone(source)
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		return fromFS(source, os.DirFS(source), nil)
+	}
+
+	if _, err := fs.Stat(builtins, path.Join("templates", source)); err == nil && !strings.ContainsAny(source, `/\.`) {
+		return builtin(source)
+	}
+
+	var names []string
+	if templates, err := Builtins(); err == nil {
+		for _, t := range templates {
+			names = append(names, t.Name)
+		}
+	}
+	return nil, fmt.Errorf("unknown template %q (built-in: %s; or give a directory or git URL)", source, strings.Join(names, ", "))
+}
+
+// Close removes the files of a cloned template
+func (t *Template) Close() {
+	if t.cleanup != nil {
+		t.cleanup()
+	}
+}
+
+// Variables returns the values files are rendered with: the template's
+// defaults, overridden by vars. The ident variable is derived from name
+// when not given.
+func (t *Template) Variables(vars map[string]string) map[string]string {
+	values := map[string]string{}
+	for key, value := range t.Manifest.Variables {
+		values[key] = value
+	}
+	for key, value := range vars {
+		values[key] = value
+	}
+	if _, ok := values["ident"]; !ok {
+		values["ident"] = Ident(values["name"])
+	}
+	return values
+}
+
+// Render returns the files of the template with {{variable}} placeholders
+// in their paths and contents replaced. Values are escaped in .json files
+// so that they stay valid. Unknown placeholders are left as they are.
+func (t *Template) Render(vars map[string]string) ([]File, error) {
+	values := t.Variables(vars)
+
+	var files []File
+	err := fs.WalkDir(t.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p == ManifestFile {
+			return nil
+		}
+
+		data, err := fs.ReadFile(t.fsys, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		dest := path.Clean(substitute(p, values, nil))
+		if !Inside(dest) {
+			return fmt.Errorf("template file %s would be created outside of the project", p)
+		}
+
+		var escape func(string) string
+		if strings.EqualFold(path.Ext(dest), ".json") {
+			escape = escapeJSON
+		}
+		files = append(files, File{
+			Path: dest,
+			Data: []byte(substitute(string(data), values, escape)),
+			Mode: info.Mode().Perm() | 0644,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// RunHooks runs the template's hooks in dir. Variables are passed to them
+// as OMPCLI_<NAME> environment variables.
+func (t *Template) RunHooks(dir string, vars map[string]string, out io.Writer) error {
+	values := t.Variables(vars)
+	env := os.Environ()
+	for key, value := range values {
+		env = append(env, "OMPCLI_"+strings.ToUpper(key)+"="+value)
+	}
+
+	for _, hook := range t.Manifest.Hooks {
+		fmt.Fprintf(out, "Running %s\n", hook)
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", hook)
+		} else {
+			cmd = exec.Command("sh", "-c", hook)
+		}
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %q failed: %w", hook, err)
+		}
+	}
+	return nil
+}
+
+// Ident turns a project name into a Pawn identifier
+func Ident(name string) string {
+	ident := strings.Trim(identChars.ReplaceAllString(name, "_"), "_")
+	if ident == "" {
+		return "project"
+	}
+	if ident[0] >= '0' && ident[0] <= '9' {
+		ident = "_" + ident
+	}
+	return ident
+}
+
+// builtin loads a template embedded in ompcli
+func builtin(name string) (*Template, error) {
+	sub, err := fs.Sub(builtins, path.Join("templates", name))
+	if err != nil {
+		return nil, err
+	}
+	return fromFS(name, sub, nil)
+}
+
+// fromFS loads a template rooted at fsys
+func fromFS(name string, fsys fs.FS, cleanup func()) (*Template, error) {
+	t := &Template{Name: name, fsys: fsys, cleanup: cleanup}
+
+	data, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Close()
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &t.Manifest); err != nil {
+			t.Close()
+			return nil, fmt.Errorf("failed to parse %s of template %s: %w", ManifestFile, name, err)
+		}
+	}
+	return t, nil
+}
+
+// Inside reports whether the slash-separated path p names a file inside
+// the project
+func Inside(p string) bool {
+	return p != "." && filepath.IsLocal(filepath.FromSlash(p))
+}
+
+// clone loads a template from a git repository
+func clone(source string) (*Template, error) {
+	url, ref, _ := strings.Cut(source, "#")
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid template branch %q", ref)
+	}
+
+	dir, err := os.MkdirTemp("", "ompcli-template-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", url, dir)
+
+	cmd := exec.Command("git", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to clone template %s: %s", url, strings.TrimSpace(string(out)))
+	}
+	return fromFS(source, os.DirFS(dir), cleanup)
+}
+
+// isGit reports whether a template source is a git repository
+func isGit(source string) bool {
+	url, _, _ := strings.Cut(source, "#")
+	return strings.Contains(url, "://") || strings.HasPrefix(url, "git@") || strings.HasSuffix(url, ".git")
+}
+
+// substitute replaces the known placeholders in s, escaping values with
+// escape when it is set
+func substitute(s string, values map[string]string, escape func(string) string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		key := placeholder.FindStringSubmatch(match)[1]
+		value, ok := values[key]
+		if !ok {
+			return match
+		}
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+}
+
+// escapeJSON escapes s for use inside a JSON string
+func escapeJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
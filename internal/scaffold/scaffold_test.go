@@ -0,0 +1,33 @@
+package scaffold
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInside(t *testing.T) {
+	for _, p := range []string{"project.json", "gamemodes/gm.pwn", "a/../b"} {
+		if !Inside(p) {
+			t.Errorf("Inside(%q) = false", p)
+		}
+	}
+	for _, p := range []string{".", "..", "../x.pwn", "/etc/passwd", "gamemodes/../../x"} {
+		if Inside(p) {
+			t.Errorf("Inside(%q) = true", p)
+		}
+	}
+}
+
+func TestPlanRefusesOutsideFiles(t *testing.T) {
+	_, err := Plan(t.TempDir(), []File{{Path: "../../x.pwn", Data: []byte("main() {}")}}, false)
+	if err == nil || !strings.Contains(err.Error(), "outside of the project") {
+		t.Fatalf("Plan error = %v", err)
+	}
+}
+
+func TestCloneRefusesOptionRef(t *testing.T) {
+	_, err := Load("https://example.com/template.git#--upload-pack=touch x")
+	if err == nil || !strings.Contains(err.Error(), "invalid template branch") {
+		t.Fatalf("Load error = %v", err)
+	}
+}
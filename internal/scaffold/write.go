@@ -0,0 +1,217 @@
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+)
+
+// What Write does with a file
+const (
+	// ActionCreate writes a file that does not exist yet
+	ActionCreate = "create"
+
+	// ActionMerge adds the keys an existing JSON file lacks
+	ActionMerge = "merge"
+
+	// ActionOverwrite replaces an existing file; only with force
+	ActionOverwrite = "overwrite"
+
+	// ActionKeep leaves an existing file alone
+	ActionKeep = "keep"
+)
+
+// backupSuffix and tmpSuffix name the files Write uses while it works
+const (
+	backupSuffix = ".ompcli-backup"
+	tmpSuffix    = ".ompcli-tmp"
+)
+
+// Change is a file Write creates, merges, overwrites or keeps
+type Change struct {
+	File
+	Action string
+
+	// Existing holds the contents of the file already in the project
+	Existing []byte
+}
+
+// Unchanged reports whether writing the change would leave the file as it is
+func (c Change) Unchanged() bool {
+	return c.Action == ActionKeep || (c.Existing != nil && bytes.Equal(c.Existing, c.Data))
+}
+
+// Plan decides what to do with each rendered file given what already is
+// in dir. Existing JSON files get the keys they are missing, keeping their
+// own values; other existing files are kept. With force, existing files
+// are replaced instead.
+func Plan(dir string, files []File, force bool) ([]Change, error) {
+	changes := make([]Change, 0, len(files))
+	for _, file := range files {
+		if !Inside(file.Path) {
+			return nil, fmt.Errorf("%s would be created outside of the project", file.Path)
+		}
+		change := Change{File: file, Action: ActionCreate}
+
+		existing, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case force:
+			change.Action = ActionOverwrite
+			change.Existing = existing
+		case strings.EqualFold(path.Ext(file.Path), ".json"):
+			merged, err := mergeJSON(existing, file.Data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot merge into %s (use --force to replace it): %w", file.Path, err)
+			}
+			change.Action = ActionMerge
+			change.Existing = existing
+			change.Data = merged
+		default:
+			change.Action = ActionKeep
+			change.Existing = existing
+			change.Data = existing
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Write applies the changes in dir as one transaction: every file is
+// written next to its target first, then all of them are moved into place.
+// If anything fails, the files replaced so far are restored and the new
+// ones removed, so the project is left as it was.
+func Write(dir string, changes []Change) error {
+	var pending []Change
+	for _, change := range changes {
+		if !change.Unchanged() {
+			pending = append(pending, change)
+		}
+	}
+
+	// Stage every file next to its target
+	var createdDirs []string
+	var staged []string
+	rollbackStaged := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+		for i := len(createdDirs) - 1; i >= 0; i-- {
+			os.Remove(createdDirs[i])
+		}
+	}
+
+	for _, change := range pending {
+		target := filepath.Join(dir, filepath.FromSlash(change.Path))
+		created, err := mkdirAll(filepath.Dir(target))
+		createdDirs = append(createdDirs, created...)
+		if err != nil {
+			rollbackStaged()
+			return err
+		}
+
+		tmp := target + tmpSuffix
+		if err := os.WriteFile(tmp, change.Data, change.Mode); err != nil {
+			rollbackStaged()
+			return fmt.Errorf("failed to write %s: %w", change.Path, err)
+		}
+		staged = append(staged, tmp)
+	}
+
+	// Move them into place, keeping the replaced files until all are done
+	type moved struct{ target, backup string }
+	var done []moved
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			if done[i].backup != "" {
+				os.Rename(done[i].backup, done[i].target)
+			} else {
+				os.Remove(done[i].target)
+			}
+		}
+		rollbackStaged()
+	}
+
+	for i, change := range pending {
+		target := filepath.Join(dir, filepath.FromSlash(change.Path))
+		m := moved{target: target}
+
+		if _, err := os.Lstat(target); err == nil {
+			m.backup = target + backupSuffix
+			if err := os.Rename(target, m.backup); err != nil {
+				rollback()
+				return fmt.Errorf("failed to replace %s: %w", change.Path, err)
+			}
+		}
+		if err := os.Rename(staged[i], target); err != nil {
+			if m.backup != "" {
+				os.Rename(m.backup, target)
+			}
+			rollback()
+			return fmt.Errorf("failed to write %s: %w", change.Path, err)
+		}
+		done = append(done, m)
+	}
+
+	for _, m := range done {
+		if m.backup != "" {
+			os.Remove(m.backup)
+		}
+	}
+	return nil
+}
+
+// mergeJSON adds the keys of generated that existing lacks, recursing into
+// objects both have. Values existing already has are kept.
+func mergeJSON(existing, generated []byte) ([]byte, error) {
+	doc, err := jsonedit.Parse(existing)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := jsonedit.ParseValue(generated)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Root.Kind != jsonedit.Object || gen.Kind != jsonedit.Object {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+
+	mergeObject(doc.Root, gen)
+	return doc.Bytes(), nil
+}
+
+// mergeObject adds the missing fields of src to dst
+func mergeObject(dst, src *jsonedit.Value) {
+	for _, field := range src.Fields {
+		current := dst.Lookup(field.Key)
+		switch {
+		case current == nil:
+			dst.Fields = append(dst.Fields, &jsonedit.Field{Key: field.Key, Value: field.Value})
+		case current.Kind == jsonedit.Object && field.Value.Kind == jsonedit.Object:
+			mergeObject(current, field.Value)
+		}
+	}
+}
+
+// mkdirAll creates dir and returns the folders it had to create, outermost
+// first
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for p := dir; ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		missing = append([]string{p}, missing...)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	return missing, os.MkdirAll(dir, 0755)
+}
@@ -0,0 +1,309 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. Missing minor and patch numbers are zero,
+// so "2.9" and "v2.9.0" are the same version.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	original   string
+}
+
+// Parse parses a version such as "1.2.3", "v1.2" or "1.0.0-rc1"
+func Parse(s string) (Version, error) {
+	v := Version{original: s}
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	rest, _, _ = strings.Cut(rest, "+") // build metadata is ignored
+	rest, v.Prerelease, _ = strings.Cut(rest, "-")
+
+	parts := strings.Split(rest, ".")
+	if len(parts) == 0 || len(parts) > 3 || parts[0] == "" {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid input
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the version as it was written
+func (v Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or higher than other
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	// A pre-release sorts before the release it precedes
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares pre-release versions the way semver does: by
+// their dot-separated identifiers, numbers numerically and below words,
+// and words in ASCII order. A shorter list of equal identifiers is lower.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := as[i], bs[i]
+		xn, xErr := strconv.ParseUint(x, 10, 64)
+		yn, yErr := strconv.ParseUint(y, 10, 64)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				return cmp(xn < yn)
+			}
+		case xErr == nil:
+			return -1
+		case yErr == nil:
+			return 1
+		case x != y:
+			return cmp(x < y)
+		}
+	}
+	if len(as) != len(bs) {
+		return cmp(len(as) < len(bs))
+	}
+	return 0
+}
+
+// cmp returns -1 when less holds and 1 otherwise
+func cmp(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+// Constraint is a set of version ranges. A version satisfies the
+// constraint when it satisfies every comparison of any one range.
+type Constraint struct {
+	ranges [][]comparison
+	text   string
+}
+
+type comparison struct {
+	op      string
+	version Version
+}
+
+// ParseConstraint parses constraints such as "^2.9.6", "~1.2", ">=1.0 <2.0",
+// "1.2.3 || 1.4.x" and "*". An empty constraint or "latest" matches any
+// release version.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{text: s}
+	for _, alt := range strings.Split(s, "||") {
+		var group []comparison
+		terms := strings.FieldsFunc(alt, func(r rune) bool { return r == ' ' || r == ',' })
+		for i := 0; i < len(terms); i++ {
+			// An operator may be followed by a space: ">= 1.0"
+			term := terms[i]
+			if isOperator(term) && i+1 < len(terms) {
+				i++
+				term += terms[i]
+			}
+			cmps, err := parseTerm(term)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			group = append(group, cmps...)
+		}
+		c.ranges = append(c.ranges, group)
+	}
+	return c, nil
+}
+
+// String returns the constraint as it was written
+func (c Constraint) String() string {
+	return c.text
+}
+
+// Check reports whether v satisfies the constraint. Pre-releases only match
+// comparisons that name a pre-release of the same version.
+func (c Constraint) Check(v Version) bool {
+	for _, group := range c.ranges {
+		if matches(group, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(group []comparison, v Version) bool {
+	allowPre := v.Prerelease == ""
+	for _, cmp := range group {
+		if !cmp.check(v) {
+			return false
+		}
+		if cmp.version.Prerelease != "" && cmp.version.Major == v.Major &&
+			cmp.version.Minor == v.Minor && cmp.version.Patch == v.Patch {
+			allowPre = true
+		}
+	}
+	return allowPre
+}
+
+func (c comparison) check(v Version) bool {
+	d := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return d == 0
+	case "!=":
+		return d != 0
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return false
+}
+
+// operators are the comparison operators, longest first
+var operators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// isOperator reports whether term is an operator without a version
+func isOperator(term string) bool {
+	if term == "^" || term == "~" {
+		return true
+	}
+	for _, op := range operators {
+		if term == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTerm expands a single term into plain comparisons
+func parseTerm(term string) ([]comparison, error) {
+	if term == "*" || term == "latest" || term == "x" {
+		return nil, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(term, op) {
+			v, err := Parse(term[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			return []comparison{{op, v}}, nil
+		}
+	}
+
+	switch term[0] {
+	case '^':
+		v, parts, err := parsePartial(term[1:])
+		if err != nil || parts == 0 {
+			return nil, err
+		}
+		upper := Version{Major: v.Major + 1}
+		switch {
+		case v.Major > 0 || parts == 1:
+		case v.Minor > 0 || parts == 2:
+			upper = Version{Major: 0, Minor: v.Minor + 1}
+		default:
+			upper = Version{Major: 0, Minor: 0, Patch: v.Patch + 1}
+		}
+		return []comparison{{">=", v}, {"<", upper}}, nil
+	case '~':
+		v, parts, err := parsePartial(term[1:])
+		if err != nil || parts == 0 {
+			return nil, err
+		}
+		upper := Version{Major: v.Major, Minor: v.Minor + 1}
+		if parts == 1 {
+			upper = Version{Major: v.Major + 1}
+		}
+		return []comparison{{">=", v}, {"<", upper}}, nil
+	}
+
+	// A bare version, possibly with wildcards: "1.2.3", "1.2", "1.x"
+	v, parts, err := parsePartial(term)
+	if err != nil {
+		return nil, err
+	}
+	switch parts {
+	case 0:
+		return nil, nil
+	case 1:
+		return []comparison{{">=", v}, {"<", Version{Major: v.Major + 1}}}, nil
+	case 2:
+		return []comparison{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	}
+	return []comparison{{"=", v}}, nil
+}
+
+// parsePartial parses a version that may omit or wildcard its trailing
+// parts and returns how many parts were given
+func parsePartial(s string) (Version, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	parts := strings.Split(s, ".")
+	n := len(parts)
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			n = i
+			break
+		}
+	}
+	if n == 0 {
+		return Version{}, 0, nil
+	}
+
+	v, err := Parse(strings.Join(parts[:n], "."))
+	if err != nil {
+		return Version{}, 0, err
+	}
+	if strings.Contains(parts[n-1], "-") {
+		n = 3
+	}
+	return v, n, nil
+}
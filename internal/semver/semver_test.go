@@ -0,0 +1,81 @@
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v2.9", "2.9.0", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+	}
+
+	for _, tt := range tests {
+		a, b := MustParse(tt.a), MustParse(tt.b)
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Compare(a); got != -tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"^2.9.6", []string{"2.9.6", "2.10.0"}, []string{"2.9.5", "3.0.0", "3.0.0-rc.1"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{">=1.0 <2.0", []string{"1.0.0", "1.5.2"}, []string{"0.9.0", "2.0.0"}},
+		{">= 1.0, < 2.0", []string{"1.0.0", "1.9.9"}, []string{"2.0.0"}},
+		{"^ 1.2", []string{"1.2.0", "1.9.0"}, []string{"2.0.0"}},
+		{"1.2.3 || 1.4.x", []string{"1.2.3", "1.4.7"}, []string{"1.2.4", "1.5.0"}},
+		{"1.x", []string{"1.0.0", "1.99.0"}, []string{"2.0.0"}},
+		{"!=1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{"*", []string{"0.0.1", "9.0.0"}, []string{"1.0.0-rc.1"}},
+		{"", []string{"1.0.0"}, nil},
+		{">=1.0.0-rc.2", []string{"1.0.0-rc.10", "1.0.0", "1.1.0"}, []string{"1.0.0-rc.1", "1.1.0-rc.1"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		for _, v := range tt.match {
+			if !c.Check(MustParse(v)) {
+				t.Errorf("%q does not match %s", tt.constraint, v)
+			}
+		}
+		for _, v := range tt.noMatch {
+			if c.Check(MustParse(v)) {
+				t.Errorf("%q matches %s", tt.constraint, v)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{">=", ">=abc", "^1.a", "1.2.3.4"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want an error", s)
+		}
+	}
+}
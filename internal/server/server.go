@@ -0,0 +1,177 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/internal/versions"
+)
+
+// ErrNotInstalled is returned when a pinned server version is missing
+var ErrNotInstalled = errors.New("server not installed")
+
+// Server is an installed open.mp server distribution
+type Server struct {
+	Version string
+	Dir     string
+}
+
+// Exe returns the path of the server executable
+func (s *Server) Exe() string {
+	return filepath.Join(s.Dir, ExeName())
+}
+
+// ComponentsDir returns the folder holding the server's components
+func (s *Server) ComponentsDir() string {
+	return filepath.Join(s.Dir, "components")
+}
+
+// IncludeDir returns the folder with the includes bundled with the server,
+// or an empty string when the distribution has none
+func (s *Server) IncludeDir() string {
+	for _, sub := range []string{"qawno/include", "pawno/include", "include"} {
+		dir := filepath.Join(s.Dir, filepath.FromSlash(sub))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// ExeName returns the file name of the server on this platform
+func ExeName() string {
+	if runtime.GOOS == "windows" {
+		return "omp-server.exe"
+	}
+	return "omp-server"
+}
+
+// Dir returns the shared directory server versions are installed into
+func Dir() (string, error) {
+	cache, err := userconfig.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "servers"), nil
+}
+
+// store holds the installed server versions
+var store = &versions.Store{
+	Name:    "open.mp server",
+	Command: "server",
+	Dir:     Dir,
+	Compare: compareVersions,
+	Mirror: func(config *userconfig.Config) string {
+		return config.ServerMirror
+	},
+	MirrorKey:       "server_mirror",
+	ArchiveName:     ArchiveName,
+	ErrNotInstalled: ErrNotInstalled,
+}
+
+// List returns the installed server versions, newest first
+func List() ([]*Server, error) {
+	installed, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]*Server, len(installed))
+	for i, v := range installed {
+		servers[i] = &Server{Version: v.Version, Dir: v.Dir}
+	}
+	return servers, nil
+}
+
+// Find returns the installed server for version
+func Find(version string) (*Server, error) {
+	version = normalize(version)
+	dir, err := store.Find(version)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Version: version, Dir: dir}, nil
+}
+
+// Install unpacks a server release into the shared server directory. The
+// release comes from the archive at source, or from the server_mirror of
+// the user configuration when source is empty.
+func Install(version, source string) (*Server, error) {
+	version = normalize(version)
+	dir, err := store.Install(version, source, func(dir string) error {
+		if _, err := os.Stat((&Server{Dir: dir}).Exe()); err != nil {
+			return fmt.Errorf("does not contain %s", ExeName())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Version: version, Dir: dir}, nil
+}
+
+// Stage copies the server executable, its components and its bundled
+// includes into a project's runtime directory
+func (s *Server) Stage(st *stage.Stager) error {
+	if err := st.File(s.Exe(), ExeName()); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", ExeName(), err)
+	}
+
+	if _, err := os.Stat(s.ComponentsDir()); err == nil {
+		if err := st.Tree(s.ComponentsDir(), "components"); err != nil {
+			return fmt.Errorf("failed to stage components: %w", err)
+		}
+	}
+
+	if includeDir := s.IncludeDir(); includeDir != "" {
+		rel, err := filepath.Rel(s.Dir, includeDir)
+		if err != nil {
+			return err
+		}
+		if err := st.Tree(includeDir, filepath.ToSlash(rel)); err != nil {
+			return fmt.Errorf("failed to stage includes: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ArchiveName returns the path of a server release below a mirror,
+// following the layout of the upstream release downloads
+// (v<version>/open.mp-linux-x86.tar.gz)
+func ArchiveName(version, goos string) string {
+	name := "open.mp-linux-x86.tar.gz"
+	if goos == "windows" {
+		name = "open.mp-win-x86.zip"
+	}
+	return "v" + version + "/" + name
+}
+
+// normalize strips the "v" prefix of release tags
+func normalize(version string) string {
+	return strings.TrimPrefix(strings.TrimSpace(version), "v")
+}
+
+// compareVersions orders server versions such as 1.2.0.2670 by their
+// numeric parts, falling back to a string comparison
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		va, errA := strconv.Atoi(pa[i])
+		vb, errB := strconv.Atoi(pb[i])
+		if errA != nil || errB != nil {
+			return strings.Compare(a, b)
+		}
+		if va != vb {
+			return va - vb
+		}
+	}
+	return len(pa) - len(pb)
+}
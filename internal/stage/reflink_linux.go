@@ -0,0 +1,38 @@
+package stage
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl, which shares the blocks of one file with
+// another on file systems that support it (btrfs, xfs)
+const ficlone = 0x40049409
+
+// reflink creates dst as a copy-on-write clone of src
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	if cerr := out.Close(); errno == 0 && cerr != nil {
+		return cerr
+	}
+	if errno != 0 {
+		return errno
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package stage
+
+import "errors"
+
+// reflink is only supported on Linux; callers fall back to copying
+func reflink(src, dst string) error {
+	return errors.New("reflinks are not supported on this system")
+}
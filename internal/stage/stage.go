@@ -0,0 +1,397 @@
+package stage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// ManifestFile lists the files staged by the last build, relative to the
+// build directory
+const ManifestFile = ".ompcli-staged.json"
+
+// Link modes
+const (
+	LinkCopy     = "copy"
+	LinkHardlink = "hardlink"
+	LinkReflink  = "reflink"
+)
+
+// DefaultLinkThreshold is the size from which files are linked instead of
+// copied when a link mode is set
+const DefaultLinkThreshold = 1 << 20
+
+// Options controls how files are staged
+type Options struct {
+	// Link is LinkCopy (the default), LinkHardlink or LinkReflink. Files
+	// that cannot be linked, for example across file systems, are copied.
+	Link string
+
+	// LinkThreshold is the size from which files are linked; zero means
+	// DefaultLinkThreshold
+	LinkThreshold int64
+
+	// FS holds both the files staged and the build directory; nil means
+	// the real file system. Files are only linked on the real one.
+	FS vfs.FS
+}
+
+// Stats counts what a Stager did
+type Stats struct {
+	Copied  int
+	Linked  int
+	Skipped int
+	Pruned  int
+}
+
+// Manifest represents the structure of the staging manifest
+type Manifest struct {
+	// Build describes the build that staged the files; builds made before
+	// it was recorded have none
+	Build *Build `json:"build,omitempty"`
+
+	Files []string `json:"files"`
+}
+
+// Build records how the staged files were built
+type Build struct {
+	// Profile is the server configuration profile, empty for config.json
+	Profile string `json:"profile,omitempty"`
+
+	// TargetOS is the operating system plugins were staged for
+	TargetOS string `json:"target_os"`
+
+	// PawnccVersion is the version the compiler reported, when it could
+	// be determined
+	PawnccVersion string `json:"pawncc_version,omitempty"`
+}
+
+// Stager copies files into a build directory, skipping files that are
+// already up to date and remembering what it staged, so that files a later
+// build no longer stages can be removed. Files it did not stage, such as
+// logs written by the server, are never touched.
+type Stager struct {
+	Dir   string
+	Opts  Options
+	Stats Stats
+
+	// Build is recorded in the manifest by Finish when set
+	Build *Build
+
+	staged map[string]bool
+}
+
+// New returns a Stager for the build directory dir
+func New(dir string, opts Options) *Stager {
+	if opts.Link == "" {
+		opts.Link = LinkCopy
+	}
+	if opts.LinkThreshold <= 0 {
+		opts.LinkThreshold = DefaultLinkThreshold
+	}
+	opts.FS = vfs.Or(opts.FS)
+	return &Stager{Dir: dir, Opts: opts, staged: map[string]bool{}}
+}
+
+// Validate checks a link mode
+func Validate(link string) error {
+	switch link {
+	case "", LinkCopy, LinkHardlink, LinkReflink:
+		return nil
+	}
+	return fmt.Errorf("link must be %q, %q or %q", LinkCopy, LinkHardlink, LinkReflink)
+}
+
+// File stages the file src at dest, a slash-separated path relative to the
+// build directory. The file is left alone when it already has the contents
+// and mode of src.
+func (s *Stager) File(src, dest string) error {
+	target, err := s.target(dest)
+	if err != nil {
+		return err
+	}
+
+	srcInfo, err := s.Opts.FS.Stat(src)
+	if err != nil {
+		return err
+	}
+	if srcInfo.IsDir() {
+		return fmt.Errorf("%s is a directory", src)
+	}
+
+	upToDate, err := s.upToDate(src, target, srcInfo)
+	if err != nil {
+		return err
+	}
+	if upToDate {
+		s.Stats.Skipped++
+		return nil
+	}
+
+	if err := s.Opts.FS.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if srcInfo.Size() >= s.Opts.LinkThreshold && s.Opts.Link != LinkCopy && vfs.IsOS(s.Opts.FS) {
+		if err := s.link(src, target); err == nil {
+			s.Stats.Linked++
+			return nil
+		}
+	}
+
+	if err := s.copyFile(src, target, srcInfo); err != nil {
+		return err
+	}
+	s.Stats.Copied++
+	return nil
+}
+
+// Tree stages every file below the directory src under dest
+func (s *Stager) Tree(src, dest string) error {
+	return vfs.WalkDir(s.Opts.FS, src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return s.File(p, path.Join(dest, filepath.ToSlash(rel)))
+	})
+}
+
+// Write stages generated contents at dest, leaving the file alone when it
+// already holds them
+func (s *Stager) Write(dest string, data []byte, mode os.FileMode) error {
+	target, err := s.target(dest)
+	if err != nil {
+		return err
+	}
+
+	if existing, err := s.Opts.FS.ReadFile(target); err == nil && bytes.Equal(existing, data) {
+		s.Stats.Skipped++
+		return nil
+	}
+
+	if err := s.Opts.FS.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := s.writeAtomic(target, bytes.NewReader(data), mode); err != nil {
+		return err
+	}
+	s.Stats.Copied++
+	return nil
+}
+
+// Keep marks a file produced by the build itself, such as the compiled
+// script, as belonging to the build so that it is not pruned
+func (s *Stager) Keep(dest string) {
+	s.staged[path.Clean(dest)] = true
+}
+
+// Finish removes files staged by the previous build that were not staged
+// this time and records the current set of files
+func (s *Stager) Finish() error {
+	previous, err := loadManifest(s.Opts.FS, s.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, dest := range previous.Files {
+		if s.staged[dest] {
+			continue
+		}
+		target, err := s.abs(dest)
+		if err != nil {
+			continue
+		}
+		if err := s.Opts.FS.Remove(target); err == nil {
+			s.Stats.Pruned++
+			s.removeEmptyParents(filepath.Dir(target))
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune %s: %w", dest, err)
+		}
+	}
+
+	manifest := Manifest{Build: s.Build, Files: make([]string, 0, len(s.staged))}
+	for dest := range s.staged {
+		manifest.Files = append(manifest.Files, dest)
+	}
+	sort.Strings(manifest.Files)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.Opts.FS.WriteFile(filepath.Join(s.Dir, ManifestFile), append(data, '\n'), 0644)
+}
+
+// LoadManifest reads the staging manifest of a build directory. A missing
+// manifest yields an empty one.
+func LoadManifest(dir string) (*Manifest, error) {
+	return loadManifest(vfs.OS, dir)
+}
+
+// loadManifest reads the staging manifest of a build directory in fsys
+func loadManifest(fsys vfs.FS, dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+	data, err := fsys.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	return manifest, nil
+}
+
+// target records dest as staged and returns its absolute path
+func (s *Stager) target(dest string) (string, error) {
+	target, err := s.abs(dest)
+	if err != nil {
+		return "", err
+	}
+	s.staged[path.Clean(filepath.ToSlash(dest))] = true
+	return target, nil
+}
+
+// abs returns the absolute path of dest, refusing paths outside the build
+// directory
+func (s *Stager) abs(dest string) (string, error) {
+	dest = path.Clean(filepath.ToSlash(dest))
+	if dest == "." || path.IsAbs(dest) || dest == ".." || strings.HasPrefix(dest, "../") {
+		return "", fmt.Errorf("cannot stage %s outside of the build directory", dest)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(dest)), nil
+}
+
+// upToDate reports whether target already matches src. Files of the same
+// size are compared by hash: a matching modification time proves nothing,
+// as archives made by ompcli give every file the same one.
+func (s *Stager) upToDate(src, target string, srcInfo os.FileInfo) (bool, error) {
+	info, err := s.Opts.FS.Stat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if os.SameFile(srcInfo, info) {
+		return true, nil
+	}
+	if info.Size() != srcInfo.Size() || info.Mode().Perm() != srcInfo.Mode().Perm() {
+		return false, nil
+	}
+
+	srcHash, err := s.fileHash(src)
+	if err != nil {
+		return false, err
+	}
+	targetHash, err := s.fileHash(target)
+	if err != nil || srcHash != targetHash {
+		return false, nil
+	}
+	return true, nil
+}
+
+// link hard-links or reflinks src to target, replacing target
+func (s *Stager) link(src, target string) error {
+	tmp := target + ".tmp"
+	os.Remove(tmp)
+
+	var err error
+	if s.Opts.Link == LinkHardlink {
+		err = os.Link(src, tmp)
+	} else {
+		err = reflink(src, tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// copyFile copies src to target, keeping its mode and modification time
+func (s *Stager) copyFile(src, target string, info os.FileInfo) error {
+	in, err := s.Opts.FS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := s.writeAtomic(target, in, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return s.Opts.FS.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// writeAtomic writes r to target through a temporary file, so that a
+// running server never sees a half-written file
+func (s *Stager) writeAtomic(target string, r io.Reader, mode os.FileMode) error {
+	fsys := s.Opts.FS
+	tmp := target + ".tmp"
+	out, err := fsys.Create(tmp, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		fsys.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+
+	// The umask may have narrowed the mode
+	if err := fsys.Chmod(tmp, mode); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	return fsys.Rename(tmp, target)
+}
+
+// fileHash returns the SHA-256 of a file
+func (s *Stager) fileHash(p string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	file, err := s.Opts.FS.Open(p)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// removeEmptyParents removes dir and its parents up to the build
+// directory while they are empty
+func (s *Stager) removeEmptyParents(dir string) {
+	root := s.Dir
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := s.Opts.FS.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,156 @@
+package stage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeFile writes a file with the given mode, creating its folders
+func writeFile(t *testing.T, path, data string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stageFiles stages the given files of src into dir and finishes
+func stageFiles(t *testing.T, src, dir string, opts Options, files ...string) Stats {
+	t.Helper()
+	st := New(dir, opts)
+	for _, name := range files {
+		if err := st.File(filepath.Join(src, filepath.FromSlash(name)), name); err != nil {
+			t.Fatalf("File(%s): %v", name, err)
+		}
+	}
+	if err := st.Finish(); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	return st.Stats
+}
+
+func TestStageSkipsUnchangedFiles(t *testing.T) {
+	src, dir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "gamemodes", "gm.amx"), "amx", 0644)
+
+	if stats := stageFiles(t, src, dir, Options{}, "gamemodes/gm.amx"); stats.Copied != 1 {
+		t.Errorf("first build: %+v", stats)
+	}
+	if stats := stageFiles(t, src, dir, Options{}, "gamemodes/gm.amx"); stats.Copied != 0 || stats.Skipped != 1 {
+		t.Errorf("second build: %+v", stats)
+	}
+}
+
+func TestStageCopiesChangedContents(t *testing.T) {
+	src, dir := t.TempDir(), t.TempDir()
+	file := filepath.Join(src, "plugins", "streamer.so")
+	mtime := time.Unix(0, 0)
+	writeFile(t, file, "old!", 0644)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	stageFiles(t, src, dir, Options{}, "plugins/streamer.so")
+
+	// Same size and modification time, as from a reproducible archive
+	writeFile(t, file, "new!", 0644)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if stats := stageFiles(t, src, dir, Options{}, "plugins/streamer.so"); stats.Copied != 1 {
+		t.Errorf("stats = %+v, want the file copied", stats)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "plugins", "streamer.so")); string(data) != "new!" {
+		t.Errorf("staged %q, want new!", data)
+	}
+}
+
+func TestStageKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no exec bit on Windows")
+	}
+	src, dir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "plugins", "streamer.so"), "so", 0755)
+	stageFiles(t, src, dir, Options{}, "plugins/streamer.so")
+
+	info, err := os.Stat(filepath.Join(dir, "plugins", "streamer.so"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+
+	// A changed mode alone restages the file
+	if err := os.Chmod(filepath.Join(src, "plugins", "streamer.so"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stats := stageFiles(t, src, dir, Options{}, "plugins/streamer.so"); stats.Copied != 1 {
+		t.Errorf("stats = %+v, want the file copied", stats)
+	}
+}
+
+func TestStagePrunesOnlyStagedFiles(t *testing.T) {
+	src, dir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "gamemodes", "gm.amx"), "amx", 0644)
+	writeFile(t, filepath.Join(src, "scriptfiles", "old", "data.ini"), "data", 0644)
+	stageFiles(t, src, dir, Options{}, "gamemodes/gm.amx", "scriptfiles/old/data.ini")
+
+	// Files the server wrote were never staged
+	writeFile(t, filepath.Join(dir, "logs", "server.log"), "log", 0644)
+	writeFile(t, filepath.Join(dir, "scriptfiles", "player.ini"), "player", 0644)
+
+	if stats := stageFiles(t, src, dir, Options{}, "gamemodes/gm.amx"); stats.Pruned != 1 {
+		t.Errorf("stats = %+v, want one file pruned", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scriptfiles", "old")); !os.IsNotExist(err) {
+		t.Errorf("the pruned file's empty folder was kept: %v", err)
+	}
+	for _, name := range []string{"gamemodes/gm.amx", "logs/server.log", "scriptfiles/player.ini"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0] != "gamemodes/gm.amx" {
+		t.Errorf("manifest = %v", manifest.Files)
+	}
+}
+
+func TestStageLinksLargeFiles(t *testing.T) {
+	src, dir := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "models", "big.dff"), "large enough", 0644)
+
+	opts := Options{Link: LinkHardlink, LinkThreshold: 4}
+	if stats := stageFiles(t, src, dir, opts, "models/big.dff"); stats.Linked != 1 {
+		t.Fatalf("stats = %+v, want the file linked", stats)
+	}
+	a, _ := os.Stat(filepath.Join(src, "models", "big.dff"))
+	b, _ := os.Stat(filepath.Join(dir, "models", "big.dff"))
+	if !os.SameFile(a, b) {
+		t.Error("the staged file is not a link to the source")
+	}
+	if stats := stageFiles(t, src, dir, opts, "models/big.dff"); stats.Skipped != 1 {
+		t.Errorf("second build: %+v", stats)
+	}
+}
+
+func TestStageRefusesPathsOutside(t *testing.T) {
+	st := New(t.TempDir(), Options{})
+	for _, dest := range []string{"../x", "/etc/x", "."} {
+		if err := st.Write(dest, nil, 0644); err == nil {
+			t.Errorf("Write(%s) succeeded", dest)
+		}
+	}
+}
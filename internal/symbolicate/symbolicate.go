@@ -0,0 +1,238 @@
+// Package symbolicate maps the code addresses in crashdetect's traces back
+// to source. When a script crashes the server or hits a run time error,
+// crashdetect prints an AMX backtrace such as
+//
+//	[debug] AMX backtrace:
+//	[debug] #0 0000007c in ?? (0x00000001) in mygamemode.amx
+//	[debug] #1 0000005c in public OnGameModeInit () in mygamemode.amx
+//
+// Without debug information loaded in the server, the frames only carry
+// addresses. Using the debug information of the compiled script, every
+// frame of that script gets its function, source file and line:
+//
+//	[debug] #0 0000007c in Add (0x00000001) at gamemodes/mygamemode.pwn:4
+//
+// Frames given as a function and an offset, such as OnGameModeInit+0x3c,
+// are resolved the same way.
+package symbolicate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/pkg/amx"
+)
+
+// frame matches a backtrace frame: its number, then a code address or a
+// function and an offset, then the rest of the line
+var frame = regexp.MustCompile(`#\d+\s+(?:([0-9a-fA-F]{8,16})|([A-Za-z_@][\w@]*)\+(?:0x)?([0-9a-fA-F]+))\b(.*)$`)
+
+// origin matches the module a frame is in, at the end of the line
+var origin = regexp.MustCompile(`\s+(?:in|from)\s+(\S+)\s*$`)
+
+// scriptName matches a script file name, such as the one in "Server crashed
+// while executing mygamemode.amx"
+var scriptName = regexp.MustCompile(`[^\s"']+\.amx\b`)
+
+// located matches a frame that already has a source location
+var located = regexp.MustCompile(`\sat\s+\S+:\d+`)
+
+// Symbolicator rewrites the backtrace frames of one script. It keeps track
+// of the backtrace it is in, so every stream needs its own.
+type Symbolicator struct {
+	script *amx.File
+	debug  *amx.Debug
+	name   string
+
+	// inTrace is set within an AMX backtrace, and foreign when the lines
+	// before it named another script
+	inTrace bool
+	foreign bool
+}
+
+// New returns a symbolicator for a compiled script the server loads as
+// name. The script must carry debug information.
+func New(script *amx.File, name string) (*Symbolicator, error) {
+	debug, err := script.Debug()
+	if err != nil {
+		return nil, err
+	}
+	if debug == nil {
+		return nil, fmt.Errorf("%s has no debug information; compile it with -d2 or -d3", name)
+	}
+	return &Symbolicator{script: script, debug: debug, name: name}, nil
+}
+
+// Open returns a symbolicator for the compiled script at path
+func Open(path string) (*Symbolicator, error) {
+	script, err := amx.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(script, filepath.Base(path))
+}
+
+// Line returns line with the frame it holds symbolicated. Other lines, and
+// frames of other scripts, natives or the server, are returned as they are.
+func (s *Symbolicator) Line(line string) string {
+	m := frame.FindStringSubmatchIndex(line)
+	if m == nil {
+		if strings.Contains(line, "AMX backtrace:") {
+			s.inTrace = true
+		} else {
+			name := scriptName.FindString(line)
+			s.inTrace, s.foreign = false, name != "" && !s.owns(name)
+		}
+		return line
+	}
+
+	head, rest := line[:m[8]], line[m[8]:m[9]]
+	if located.MatchString(rest) {
+		return line
+	}
+
+	// Frames outside of a backtrace must name the script they are in
+	if o := origin.FindStringSubmatchIndex(rest); o != nil {
+		if !s.owns(rest[o[2]:o[3]]) {
+			return line
+		}
+		rest = rest[:o[0]]
+	} else if !s.inTrace || s.foreign {
+		return line
+	}
+
+	var addr uint64
+	offsetForm := m[2] < 0
+	if offsetForm {
+		start, ok := s.function(line[m[4]:m[5]])
+		if !ok {
+			return line
+		}
+		offset, _ := strconv.ParseUint(line[m[6]:m[7]], 16, 64)
+		addr = start + offset
+	} else {
+		addr, _ = strconv.ParseUint(line[m[2]:m[3]], 16, 64)
+	}
+
+	loc := s.script.Locate(addr)
+	if loc.File == "" && loc.Function == "" {
+		return line
+	}
+
+	// Name the function when crashdetect could not
+	if !offsetForm && loc.Function != "" {
+		switch {
+		case strings.Contains(rest, "??"):
+			rest = strings.Replace(rest, "??", loc.Function, 1)
+		case !strings.Contains(rest, "("):
+			rest = strings.TrimRight(rest, " ") + " in " + loc.Function + " ()"
+		}
+	}
+	if loc.File != "" {
+		rest = strings.TrimRight(rest, " ") + " at " + loc.File
+		if loc.Line > 0 {
+			rest += ":" + strconv.Itoa(loc.Line)
+		}
+	}
+	return head + rest
+}
+
+// owns reports whether the module a frame names is the script
+func (s *Symbolicator) owns(module string) bool {
+	base := filepath.Base(filepath.FromSlash(module))
+	if !strings.EqualFold(filepath.Ext(base), ".amx") {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSuffix(base, filepath.Ext(base)), strings.TrimSuffix(s.name, filepath.Ext(s.name)))
+}
+
+// function returns the code address a function starts at, from the debug
+// information or the publics table
+func (s *Symbolicator) function(name string) (uint64, bool) {
+	for _, sym := range s.debug.Symbols {
+		if sym.Ident == amx.IdentFunction && sym.Name == name {
+			return sym.CodeStart, true
+		}
+	}
+	for _, p := range s.script.Publics {
+		if p.Name == name {
+			return p.Address, true
+		}
+	}
+	if name == "main" && s.script.HasMain {
+		return uint64(s.script.Header.CIP), true
+	}
+	return 0, false
+}
+
+// Copy writes the log read from r to w with its backtraces symbolicated
+func (s *Symbolicator) Copy(w io.Writer, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(w, s.Line(scanner.Text())); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Writer symbolicates the lines written to it before passing them on, such
+// as the output of a running server. A line is held back until it ends;
+// Flush writes what remains.
+type Writer struct {
+	s   *Symbolicator
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter returns a writer that symbolicates lines with s and writes
+// them to w
+func NewWriter(w io.Writer, s *Symbolicator) *Writer {
+	return &Writer{s: s, w: w}
+}
+
+// Write implements io.Writer
+func (w *Writer) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+
+	var out bytes.Buffer
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		// Keep the line ending as the server wrote it
+		line, end := string(w.buf[:i]), "\n"
+		if strings.HasSuffix(line, "\r") {
+			line, end = line[:len(line)-1], "\r\n"
+		}
+		out.WriteString(w.s.Line(line))
+		out.WriteString(end)
+		w.buf = w.buf[i+1:]
+	}
+
+	if out.Len() > 0 {
+		if _, err := w.w.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush writes a last line that did not end
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := w.s.Line(string(w.buf))
+	w.buf = nil
+	_, err := io.WriteString(w.w, line)
+	return err
+}
@@ -0,0 +1,173 @@
+package symbolicate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// open returns a symbolicator for testdata/gm.amx, a copy of the fixture
+// of pkg/amx. Its Add spans 0x08-0x20 and OnGameModeInit 0x20-0x74.
+func open(t *testing.T) *Symbolicator {
+	t.Helper()
+	s, err := Open(filepath.Join("testdata", "gm.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name: "address frames",
+			lines: []string{
+				"[debug] Run time error 4: \"Array index out of bounds\"",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? (0x00000005) in gm.amx",
+				"[debug] #1 0000005c in public OnGameModeInit () in gm.amx",
+			},
+			want: []string{
+				"[debug] Run time error 4: \"Array index out of bounds\"",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add (0x00000005) at gamemodes/gm.pwn:3",
+				"[debug] #1 0000005c in public OnGameModeInit () at gamemodes/gm.pwn:9",
+			},
+		},
+		{
+			name: "address frames without their script",
+			lines: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? (0x00000005)",
+				"[debug] #1 00000038",
+			},
+			want: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add (0x00000005) at gamemodes/gm.pwn:3",
+				"[debug] #1 00000038 in OnGameModeInit () at gamemodes/gm.pwn:8",
+			},
+		},
+		{
+			name: "function and offset",
+			lines: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 Add+0x10 in gm.amx",
+				"[debug] #1 OnGameModeInit+3c (0x00000001) in gm.amx",
+				"[debug] #2 Unknown+0x10 in gm.amx",
+			},
+			want: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 Add+0x10 at gamemodes/gm.pwn:3",
+				"[debug] #1 OnGameModeInit+3c (0x00000001) at gamemodes/gm.pwn:9",
+				"[debug] #2 Unknown+0x10 in gm.amx",
+			},
+		},
+		{
+			name: "frames of natives and other scripts",
+			lines: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 native SetGameModeText () in omp-server",
+				"[debug] #1 00000018 in ?? () in other.amx",
+				"[debug] #2 00000018 in ?? () in gamemodes/gm.amx",
+			},
+			want: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 native SetGameModeText () in omp-server",
+				"[debug] #1 00000018 in ?? () in other.amx",
+				"[debug] #2 00000018 in Add () at gamemodes/gm.pwn:3",
+			},
+		},
+		{
+			name: "backtrace of another script",
+			lines: []string{
+				"[debug] Server crashed while executing other.amx",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? ()",
+				"[debug] Server crashed while executing gm.amx",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? ()",
+			},
+			want: []string{
+				"[debug] Server crashed while executing other.amx",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? ()",
+				"[debug] Server crashed while executing gm.amx",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add () at gamemodes/gm.pwn:3",
+			},
+		},
+		{
+			name: "frames already located",
+			lines: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add (a=5) at gamemodes/gm.pwn:4",
+				"[debug] #1 0000005c in public OnGameModeInit () at C:\\gm\\gamemodes\\gm.pwn:9 in gm.amx",
+			},
+			want: []string{
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add (a=5) at gamemodes/gm.pwn:4",
+				"[debug] #1 0000005c in public OnGameModeInit () at C:\\gm\\gamemodes\\gm.pwn:9 in gm.amx",
+			},
+		},
+		{
+			name: "frames outside of a backtrace",
+			lines: []string{
+				"[debug] #0 00000018 in ?? ()",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in ?? ()",
+				"[debug] Loaded 1 gamemode",
+				"[debug] #0 00000018 in ?? ()",
+			},
+			want: []string{
+				"[debug] #0 00000018 in ?? ()",
+				"[debug] AMX backtrace:",
+				"[debug] #0 00000018 in Add () at gamemodes/gm.pwn:3",
+				"[debug] Loaded 1 gamemode",
+				"[debug] #0 00000018 in ?? ()",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t)
+			for i, line := range tt.lines {
+				if got := s.Line(line); got != tt.want[i] {
+					t.Errorf("Line(%q)\n got %q\nwant %q", line, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out strings.Builder
+	w := NewWriter(&out, open(t))
+	for _, chunk := range []string{"[debug] AMX back", "trace:\r\n[debug] #0 00000018 in ?? ()\r\n[debug] #1 0000", "005c in public OnGameModeInit ()"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "[debug] AMX backtrace:\r\n[debug] #0 00000018 in Add () at gamemodes/gm.pwn:3\r\n"
+	if out.String() != want {
+		t.Errorf("before Flush: %q, want %q", out.String(), want)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want += "[debug] #1 0000005c in public OnGameModeInit () at gamemodes/gm.pwn:9"
+	if out.String() != want {
+		t.Errorf("after Flush: %q, want %q", out.String(), want)
+	}
+}
+
+func TestOpenWithoutDebugInfo(t *testing.T) {
+	_, err := Open(filepath.Join("testdata", "gm-nodebug.amx"))
+	if err == nil || !strings.Contains(err.Error(), "compile it with -d2 or -d3") {
+		t.Errorf("Open error = %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package toolchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/internal/semver"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+	"github.com/weltschmerzie/omp-cli/internal/versions"
+)
+
+// ErrNotInstalled is returned when a pinned compiler version is missing
+var ErrNotInstalled = errors.New("toolchain not installed")
+
+// versionRegex matches the banner pawncc prints, e.g. "Pawn compiler 3.10.10"
+var versionRegex = regexp.MustCompile(`Pawn compiler\s+v?(\d+\.\d+(?:\.\d+)?)`)
+
+// Toolchain is an installed pawncc version
+type Toolchain struct {
+	Version string
+	Dir     string
+}
+
+// Pawncc returns the path of the compiler executable. Release archives put
+// it in bin/, older ones at the top level or in a pawno or qawno folder.
+func (t *Toolchain) Pawncc() string {
+	for _, sub := range []string{"bin", "", "pawno", "qawno"} {
+		exe := filepath.Join(t.Dir, sub, ExeName())
+		if _, err := os.Stat(exe); err == nil {
+			return exe
+		}
+	}
+	return filepath.Join(t.Dir, "bin", ExeName())
+}
+
+// ExeName returns the file name of the compiler on this platform
+func ExeName() string {
+	if runtime.GOOS == "windows" {
+		return "pawncc.exe"
+	}
+	return "pawncc"
+}
+
+// Dir returns the user-level directory toolchains are installed into
+func Dir() (string, error) {
+	config, err := userconfig.Load()
+	if err != nil {
+		return "", err
+	}
+	if config.ToolchainDir != "" {
+		return config.ToolchainDir, nil
+	}
+
+	cache, err := userconfig.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "toolchains"), nil
+}
+
+// store holds the installed toolchains
+var store = &versions.Store{
+	Name:     "pawncc",
+	Command:  "toolchain",
+	Dir:      Dir,
+	Validate: validateVersion,
+	Compare:  compareVersions,
+	Mirror: func(config *userconfig.Config) string {
+		return config.ToolchainMirror
+	},
+	MirrorKey:       "toolchain_mirror",
+	ArchiveName:     ArchiveName,
+	ErrNotInstalled: ErrNotInstalled,
+}
+
+// List returns the installed toolchains, newest first
+func List() ([]*Toolchain, error) {
+	installed, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	toolchains := make([]*Toolchain, len(installed))
+	for i, v := range installed {
+		toolchains[i] = &Toolchain{Version: v.Version, Dir: v.Dir}
+	}
+	return toolchains, nil
+}
+
+// Find returns the installed toolchain for version
+func Find(version string) (*Toolchain, error) {
+	dir, err := store.Find(version)
+	if err != nil {
+		return nil, err
+	}
+	return &Toolchain{Version: version, Dir: dir}, nil
+}
+
+// Install unpacks a compiler release into the toolchain directory. The
+// release comes from the archive at source, or from the toolchain_mirror
+// of the user configuration when source is empty. The unpacked compiler
+// must report the requested version.
+func Install(version, source string) (*Toolchain, error) {
+	dir, err := store.Install(version, source, func(dir string) error {
+		t := &Toolchain{Version: version, Dir: dir}
+		if _, err := os.Stat(t.Pawncc()); err != nil {
+			return fmt.Errorf("does not contain %s", ExeName())
+		}
+
+		// Compilers for another platform cannot be probed, so only reject a
+		// compiler that runs and reports a different version
+		if probed, err := Probe(t.Pawncc()); err == nil && !Matches(version, probed) {
+			return fmt.Errorf("contains pawncc %s, not %s", probed, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Toolchain{Version: version, Dir: dir}, nil
+}
+
+// validateVersion checks that version is a semantic version
+func validateVersion(version string) error {
+	_, err := semver.Parse(version)
+	return err
+}
+
+// Probe runs pawncc without arguments and returns the version from its banner
+func Probe(exe string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = Env(exe)
+
+	// pawncc exits with a non-zero status when printing its usage
+	if err := cmd.Run(); err != nil && output.Len() == 0 {
+		return "", fmt.Errorf("failed to run %s: %w", exe, err)
+	}
+
+	match := versionRegex.FindStringSubmatch(output.String())
+	if match == nil {
+		return "", fmt.Errorf("could not determine the version of %s", exe)
+	}
+	return match[1], nil
+}
+
+// Matches reports whether a probed compiler version satisfies a pinned one
+func Matches(pinned, probed string) bool {
+	return compareVersions(pinned, probed) == 0
+}
+
+// Env returns the environment for running exe. Linux releases ship
+// libpawnc.so next to the compiler or in a sibling lib/ folder.
+func Env(exe string) []string {
+	env := os.Environ()
+	if runtime.GOOS != "linux" || !filepath.IsAbs(exe) {
+		return env
+	}
+
+	dirs := []string{filepath.Dir(exe), filepath.Join(filepath.Dir(exe), "..", "lib")}
+	if current := os.Getenv("LD_LIBRARY_PATH"); current != "" {
+		dirs = append(dirs, current)
+	}
+	return append(env, "LD_LIBRARY_PATH="+strings.Join(dirs, string(os.PathListSeparator)))
+}
+
+// ArchiveName returns the name of the release archive of version for goos,
+// following the naming of the upstream compiler releases
+func ArchiveName(version, goos string) string {
+	switch goos {
+	case "windows":
+		return "pawnc-" + version + "-windows.zip"
+	case "darwin":
+		return "pawnc-" + version + "-macos.zip"
+	}
+	return "pawnc-" + version + "-" + goos + ".tar.gz"
+}
+
+// compareVersions orders two compiler versions, falling back to a string
+// comparison for versions that are not semantic versions
+func compareVersions(a, b string) int {
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
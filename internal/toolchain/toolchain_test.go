@@ -0,0 +1,37 @@
+package toolchain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+)
+
+func TestFindValidatesVersion(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(userconfig.ConfigEnv, filepath.Join(tmp, "config.json"))
+	t.Setenv(userconfig.CacheEnv, filepath.Join(tmp, "cache"))
+
+	// A directory outside the toolchains that a crafted version could name
+	if err := os.MkdirAll(filepath.Join(tmp, "cache", "outside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "cache", "toolchains", "3.10.10"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"../outside", "..", "1.0.0-../../outside", `1.0.0-a\b`, ""} {
+		if _, err := Find(version); err == nil || errors.Is(err, ErrNotInstalled) {
+			t.Errorf("Find(%q) error = %v, want an invalid version", version, err)
+		}
+	}
+
+	if _, err := Find("3.10.11"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Find(3.10.11) error = %v, want ErrNotInstalled", err)
+	}
+	if _, err := Find("3.10.10"); err != nil {
+		t.Errorf("Find(3.10.10): %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package userconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigEnv overrides the location of the user configuration file
+	ConfigEnv = "OMPCLI_CONFIG"
+
+	// OfflineEnv forces offline mode when set to a non-empty value
+	OfflineEnv = "OMPCLI_OFFLINE"
+
+	// CacheEnv overrides the location of the user cache directory
+	CacheEnv = "OMPCLI_CACHE"
+)
+
+// Config represents the structure of the user-level config.json, which
+// holds settings that belong to a machine rather than a project
+type Config struct {
+	// RegistryMirror is a local directory or file:// URL laid out like a
+	// package registry, consulted before the project's registry
+	RegistryMirror string `json:"registry_mirror,omitempty"`
+
+	// Offline restricts package sources to local directories
+	Offline bool `json:"offline,omitempty"`
+
+	// ToolchainDir is where pawncc versions are installed
+	ToolchainDir string `json:"toolchain_dir,omitempty"`
+
+	// ToolchainMirror is a directory or URL holding pawncc release archives
+	ToolchainMirror string `json:"toolchain_mirror,omitempty"`
+
+	// DefaultToolchain is the pawncc version used by projects that set
+	// neither pawncc_version nor pawncc_path
+	DefaultToolchain string `json:"default_toolchain,omitempty"`
+
+	// ServerMirror is a directory or URL holding open.mp server releases
+	ServerMirror string `json:"server_mirror,omitempty"`
+}
+
+// Validate checks the user configuration for invalid values
+func (c *Config) Validate() error {
+	return nil
+}
+
+// Path returns the location of the user configuration file
+func Path() (string, error) {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "ompcli", "config.json"), nil
+}
+
+// Load reads the user configuration. A missing file yields the defaults.
+func Load() (*Config, error) {
+	config := &Config{}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if os.Getenv(OfflineEnv) != "" {
+		config.Offline = true
+	}
+
+	return config, nil
+}
+
+// CacheDir returns the user-level cache directory shared by all projects
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheEnv); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ompcli"), nil
+}
@@ -0,0 +1,189 @@
+// Package versions keeps downloaded releases, such as compilers and server
+// distributions, in a directory with one folder per installed version.
+// Installs are unpacked into a hidden folder next to their final location
+// and renamed into place, so an interrupted install leaves no version
+// behind.
+package versions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/archive"
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+)
+
+// Store is a directory of installed versions of one kind of release
+type Store struct {
+	// Name names the release in messages, such as "pawncc"
+	Name string
+
+	// Command is the ompcli command that installs versions, such as
+	// "toolchain"
+	Command string
+
+	// Dir returns the directory versions are installed into
+	Dir func() (string, error)
+
+	// Validate checks a version beyond it being a valid folder name, or
+	// is nil
+	Validate func(version string) error
+
+	// Compare orders two versions
+	Compare func(a, b string) int
+
+	// Mirror returns the mirror releases are fetched from, and MirrorKey
+	// names its setting in the user configuration
+	Mirror    func(config *userconfig.Config) string
+	MirrorKey string
+
+	// ArchiveName returns the path of the release archive of version for
+	// goos below the mirror
+	ArchiveName func(version, goos string) string
+
+	// ErrNotInstalled is wrapped by Find for missing versions
+	ErrNotInstalled error
+}
+
+// Installed is an installed version and the folder it is in
+type Installed struct {
+	Version string
+	Dir     string
+}
+
+// List returns the installed versions, newest first
+func (s *Store) List() ([]Installed, error) {
+	dir, err := s.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []Installed
+	for _, entry := range entries {
+		// Skip unfinished installs
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		installed = append(installed, Installed{Version: entry.Name(), Dir: filepath.Join(dir, entry.Name())})
+	}
+
+	sort.Slice(installed, func(i, j int) bool {
+		return s.Compare(installed[i].Version, installed[j].Version) > 0
+	})
+	return installed, nil
+}
+
+// Find returns the folder of an installed version
+func (s *Store) Find(version string) (string, error) {
+	if err := s.validate(version); err != nil {
+		return "", err
+	}
+
+	dir, err := s.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, version)
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s %s: %w (run 'ompcli %s install %s')", s.Name, version, s.ErrNotInstalled, s.Command, version)
+	}
+	return target, nil
+}
+
+// Install unpacks a release into the store and returns its folder. The
+// release comes from the archive at source, or from the mirror when source
+// is empty. check is called with the unpacked folder before it is moved
+// into place and rejects releases that are not usable.
+func (s *Store) Install(version, source string, check func(dir string) error) (string, error) {
+	if err := s.validate(version); err != nil {
+		return "", err
+	}
+
+	dir, err := s.Dir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s directory: %w", s.Command, err)
+	}
+
+	// Fetch the archive from the mirror unless one was given
+	if source == "" {
+		downloaded, err := s.fetch(version, dir)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(downloaded)
+		source = downloaded
+	}
+
+	// Unpack next to the final location so a failed install leaves nothing behind
+	tmp, err := os.MkdirTemp(dir, "."+version+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := archive.Extract(source, tmp); err != nil {
+		return "", fmt.Errorf("failed to unpack %s: %w", filepath.Base(source), err)
+	}
+	if err := archive.StripSingleRoot(tmp); err != nil {
+		return "", err
+	}
+	if err := check(tmp); err != nil {
+		return "", fmt.Errorf("%s %w", filepath.Base(source), err)
+	}
+
+	target := filepath.Join(dir, version)
+	if err := os.RemoveAll(target); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// validate checks that version names a folder inside the store
+func (s *Store) validate(version string) error {
+	if version == "" || strings.HasPrefix(version, ".") || strings.ContainsAny(version, `/\:`) {
+		return fmt.Errorf("invalid %s version %q", s.Name, version)
+	}
+	if s.Validate != nil {
+		if err := s.Validate(version); err != nil {
+			return fmt.Errorf("invalid %s version %q: %w", s.Name, version, err)
+		}
+	}
+	return nil
+}
+
+// fetch copies the release archive of version from the mirror into dir
+func (s *Store) fetch(version, dir string) (string, error) {
+	config, err := userconfig.Load()
+	if err != nil {
+		return "", err
+	}
+	mirror := s.Mirror(config)
+	if mirror == "" {
+		return "", fmt.Errorf("no %s configured; pass an archive with --from or set one with 'ompcli config set user:%s <dir or url>'", s.MirrorKey, s.MirrorKey)
+	}
+
+	downloaded, err := archive.Download(mirror, s.ArchiveName(version, runtime.GOOS), dir, config.Offline)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s %s: %w", s.Name, version, err)
+	}
+	return downloaded, nil
+}
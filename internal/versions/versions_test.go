@@ -0,0 +1,146 @@
+package versions
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/userconfig"
+)
+
+var errNotInstalled = errors.New("not installed")
+
+// testStore returns a store in a temporary directory
+func testStore(t *testing.T) (*Store, string) {
+	dir := filepath.Join(t.TempDir(), "store")
+	t.Setenv(userconfig.ConfigEnv, filepath.Join(t.TempDir(), "config.json"))
+	return &Store{
+		Name:            "thing",
+		Command:         "thing",
+		Dir:             func() (string, error) { return dir, nil },
+		Compare:         strings.Compare,
+		Mirror:          func(*userconfig.Config) string { return "" },
+		MirrorKey:       "thing_mirror",
+		ArchiveName:     func(version, goos string) string { return version + ".tar.gz" },
+		ErrNotInstalled: errNotInstalled,
+	}, dir
+}
+
+// release writes a release archive with its files below a root folder
+func release(t *testing.T, files ...string) string {
+	path := filepath.Join(t.TempDir(), "release.tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, name := range files {
+		header := &tar.Header{Name: "release/" + name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(name))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// hasExe rejects releases without an exe file
+func hasExe(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "exe")); err != nil {
+		return errors.New("does not contain exe")
+	}
+	return nil
+}
+
+func TestInstall(t *testing.T) {
+	s, dir := testStore(t)
+
+	for _, version := range []string{"1.0", "2.0"} {
+		got, err := s.Install(version, release(t, "exe", "lib/a"), hasExe)
+		if err != nil {
+			t.Fatalf("Install(%s): %v", version, err)
+		}
+		if want := filepath.Join(dir, version); got != want {
+			t.Errorf("Install(%s) = %s, want %s", version, got, want)
+		}
+		// The single root folder of the archive is stripped
+		if _, err := os.Stat(filepath.Join(got, "lib", "a")); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// An unfinished install is not listed
+	if err := os.Mkdir(filepath.Join(dir, ".3.0-123"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	installed, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Installed{{"2.0", filepath.Join(dir, "2.0")}, {"1.0", filepath.Join(dir, "1.0")}}
+	if !reflect.DeepEqual(installed, want) {
+		t.Errorf("List() = %v, want %v", installed, want)
+	}
+
+	if got, err := s.Find("1.0"); err != nil || got != filepath.Join(dir, "1.0") {
+		t.Errorf("Find(1.0) = %s, %v", got, err)
+	}
+	if _, err := s.Find("3.0"); !errors.Is(err, errNotInstalled) {
+		t.Errorf("Find(3.0) error = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestInstallRejectedRelease(t *testing.T) {
+	s, dir := testStore(t)
+
+	_, err := s.Install("1.0", release(t, "readme"), hasExe)
+	if err == nil || !strings.Contains(err.Error(), "does not contain exe") {
+		t.Fatalf("Install error = %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("the failed install left %v behind", entries)
+	}
+}
+
+func TestInstallWithoutMirror(t *testing.T) {
+	s, _ := testStore(t)
+	if _, err := s.Install("1.0", "", hasExe); err == nil || !strings.Contains(err.Error(), "no thing_mirror configured") {
+		t.Fatalf("Install error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, _ := testStore(t)
+	s.Validate = func(version string) error {
+		if strings.HasPrefix(version, "x") {
+			return errors.New("starts with x")
+		}
+		return nil
+	}
+
+	for _, version := range []string{"", ".", "..", ".hidden", "../x", `a\b`, "c:", "x1"} {
+		if _, err := s.Find(version); err == nil || errors.Is(err, errNotInstalled) {
+			t.Errorf("Find(%q) error = %v, want an invalid version", version, err)
+		}
+		if _, err := s.Install(version, release(t, "exe"), hasExe); err == nil {
+			t.Errorf("Install(%q) succeeded", version)
+		}
+	}
+}
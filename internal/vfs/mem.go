@@ -0,0 +1,318 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem is an in-memory file system. Like the real one, files can only be
+// created in existing directories. The zero value is not usable; create
+// one with NewMem.
+type Mem struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+	now   func() time.Time
+}
+
+// memNode is a file or directory of a Mem
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMem returns an in-memory file system holding files, keyed by path,
+// along with the directories they are in
+func NewMem(files map[string][]byte) *Mem {
+	m := &Mem{nodes: map[string]*memNode{}, now: time.Now}
+	for name, data := range files {
+		m.MkdirAll(filepath.Dir(name), 0755)
+		m.WriteFile(name, data, 0644)
+	}
+	return m
+}
+
+// Files returns the contents of every file, keyed by path
+func (m *Mem) Files() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := map[string][]byte{}
+	for name, node := range m.nodes {
+		if !node.mode.IsDir() {
+			files[name] = append([]byte{}, node.data...)
+		}
+	}
+	return files
+}
+
+// Open opens a file for reading
+func (m *Mem) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{info: memInfo{name: filepath.Base(name), node: *node}, r: bytes.NewReader(node.data)}, nil
+}
+
+// Stat returns the file info of name
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{name: filepath.Base(name), node: *node}, nil
+}
+
+// ReadFile returns the contents of a file
+func (m *Mem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return append([]byte{}, node.data...), nil
+}
+
+// ReadDir returns the entries of a directory sorted by name
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, node, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	var entries []fs.DirEntry
+	for p, child := range m.nodes {
+		if p != dir && filepath.Dir(p) == dir {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: filepath.Base(p), node: *child}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Create creates or truncates a file, whose contents are written when the
+// returned writer is closed
+func (m *Mem) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	if err := m.WriteFile(name, nil, perm); err != nil {
+		return nil, err
+	}
+	return &memWriter{m: m, name: name, perm: perm}, nil
+}
+
+// WriteFile writes a file, creating it with perm when it does not exist
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = clean(name)
+	if err := m.checkParent("open", name); err != nil {
+		return err
+	}
+	if node, ok := m.nodes[name]; ok {
+		if node.mode.IsDir() {
+			return &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+		}
+		node.data = append([]byte{}, data...)
+		node.modTime = m.now()
+		return nil
+	}
+	m.nodes[name] = &memNode{data: append([]byte{}, data...), mode: perm.Perm(), modTime: m.now()}
+	return nil
+}
+
+// MkdirAll creates a directory and its parents
+func (m *Mem) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = clean(name)
+	var missing []string
+	for p := name; ; p = filepath.Dir(p) {
+		if node, ok := m.nodes[p]; ok {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: p, Err: errNotDir}
+			}
+			break
+		}
+		missing = append(missing, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	for _, p := range missing {
+		m.nodes[p] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: m.now()}
+	}
+	return nil
+}
+
+// Remove removes a file or an empty directory
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() {
+		for p := range m.nodes {
+			if p != name && filepath.Dir(p) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+			}
+		}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+// Rename moves a file or directory, replacing a file at newname
+func (m *Mem) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldname, _, err := m.lookup("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newname = clean(newname)
+	if err := m.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if target, ok := m.nodes[newname]; ok && target.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errIsDir}
+	}
+
+	prefix := oldname + string(filepath.Separator)
+	for p, node := range m.nodes {
+		if p == oldname {
+			delete(m.nodes, p)
+			m.nodes[newname] = node
+		} else if strings.HasPrefix(p, prefix) {
+			delete(m.nodes, p)
+			m.nodes[newname+string(filepath.Separator)+strings.TrimPrefix(p, prefix)] = node
+		}
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of a file
+func (m *Mem) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode&fs.ModeType | mode.Perm()
+	return nil
+}
+
+// Chtimes changes the modification time of a file
+func (m *Mem) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, node, err := m.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	node.modTime = mtime
+	return nil
+}
+
+// lookup returns the cleaned name and node of an existing path
+func (m *Mem) lookup(op, name string) (string, *memNode, error) {
+	name = clean(name)
+	node, ok := m.nodes[name]
+	if !ok {
+		return name, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return name, node, nil
+}
+
+// checkParent fails unless the directory holding name exists
+func (m *Mem) checkParent(op, name string) error {
+	parent, ok := m.nodes[filepath.Dir(name)]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+// clean makes name absolute and clean, so every path has one key
+func clean(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
+
+// Errors of Mem, matching the messages of the os package
+var (
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// memInfo implements fs.FileInfo
+type memInfo struct {
+	name string
+	node memNode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+// memFile is a file of a Mem opened for reading
+type memFile struct {
+	info memInfo
+	r    *bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *memFile) Close() error               { return nil }
+
+// memWriter collects the contents of a file created in a Mem
+type memWriter struct {
+	m    *Mem
+	name string
+	perm fs.FileMode
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+func (w *memWriter) Close() error                { return w.m.WriteFile(w.name, w.buf.Bytes(), w.perm) }
@@ -0,0 +1,186 @@
+// Package vfs abstracts the file system operations used to load and stage
+// projects, so that they can run against the real disk or an in-memory
+// file system. Paths are native paths, as used with the os package.
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FS is a writable file system
+type FS interface {
+	Open(name string) (fs.File, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+
+	// Create creates or truncates a file; its contents are visible once
+	// the returned writer is closed
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// OS is the file system of the operating system
+var OS FS = osFS{}
+
+// Or returns fsys, or OS when fsys is nil
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS
+	}
+	return fsys
+}
+
+// IsOS reports whether fsys is the file system of the operating system,
+// where files can also be linked or handed to other processes
+func IsOS(fsys FS) bool {
+	return fsys == nil || fsys == OS
+}
+
+// Exists reports whether name exists in fsys
+func Exists(fsys FS, name string) bool {
+	_, err := fsys.Stat(name)
+	return err == nil
+}
+
+// Glob returns the names in fsys matching pattern, with the syntax of
+// filepath.Match. Wildcards are allowed in every element of the pattern.
+func Glob(fsys FS, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := fsys.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanDir(dir)
+
+	dirs := []string{dir}
+	if hasMeta(dir) {
+		var err error
+		if dirs, err = Glob(fsys, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var matches []string
+	for _, d := range dirs {
+		entries, err := fsys.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if ok, _ := filepath.Match(file, entry.Name()); ok {
+				matches = append(matches, filepath.Join(d, entry.Name()))
+			}
+		}
+	}
+	return matches, nil
+}
+
+// WalkDir walks the tree rooted at root like filepath.WalkDir, visiting
+// entries in lexical order
+func WalkDir(fsys FS, root string, fn fs.WalkDirFunc) error {
+	if IsOS(fsys) {
+		return filepath.WalkDir(root, fn)
+	}
+
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDir visits name and, for directories, everything below it
+func walkDir(fsys FS, name string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		// Give fn a chance to skip the unreadable directory
+		if err = fn(name, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDir(fsys, filepath.Join(name, entry.Name()), entry, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// hasMeta reports whether a pattern contains wildcards
+func hasMeta(pattern string) bool {
+	magic := `*?[\`
+	if filepath.Separator == '\\' {
+		magic = `*?[`
+	}
+	return strings.ContainsAny(pattern, magic)
+}
+
+// cleanDir turns the directory part of a split pattern into a path
+func cleanDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return filepath.Clean(dir)
+}
+
+// osFS implements FS with the os package
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error)            { return os.Open(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (osFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (osFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+
+func (osFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
@@ -0,0 +1,356 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/deps"
+	"github.com/weltschmerzie/omp-cli/internal/server"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// File is the name of the file that marks the root of a workspace
+const File = "workspace.json"
+
+// Config represents the structure of workspace.json
+type Config struct {
+	Members       []string `json:"members"`
+	IncludePaths  []string `json:"include_paths,omitempty"`
+	PawnccPath    string   `json:"pawncc_path,omitempty"`
+	PawnccVersion string   `json:"pawncc_version,omitempty"`
+}
+
+// Member is a project that belongs to a workspace
+type Member struct {
+	Name   string
+	Root   string
+	Config *utils.ProjectConfig
+}
+
+// Workspace is a set of projects built together. A project outside of any
+// workspace is treated as a workspace with itself as the only member.
+type Workspace struct {
+	Root     string
+	Config   Config
+	Members  []*Member
+	Implicit bool
+}
+
+// Resolve finds the workspace or project dir belongs to and returns it along
+// with the members that commands should act on. An explicit member name
+// selects that member; otherwise a directory inside a member selects that
+// member, and the workspace root selects all of them.
+func Resolve(dir, member string) (*Workspace, []*Member, error) {
+	if dir == "" {
+		dir = "."
+	}
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	wsRoot := findUp(start, File)
+	if wsRoot == "" {
+		return standalone(start, member)
+	}
+
+	ws, err := Load(wsRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if member != "" {
+		m := ws.Member(member)
+		if m == nil {
+			return nil, nil, fmt.Errorf("workspace has no member named %q", member)
+		}
+		return ws, []*Member{m}, nil
+	}
+
+	for _, m := range ws.Members {
+		if start == m.Root || strings.HasPrefix(start, m.Root+string(filepath.Separator)) {
+			return ws, []*Member{m}, nil
+		}
+	}
+
+	// A project nested below the workspace root that is not a member
+	if projectRoot := findUp(start, utils.ProjectFile); projectRoot != "" && projectRoot != wsRoot &&
+		strings.HasPrefix(projectRoot, wsRoot+string(filepath.Separator)) {
+		return standalone(start, member)
+	}
+
+	return ws, ws.Members, nil
+}
+
+// Load reads the workspace rooted at root and all of its members
+func Load(root string) (*Workspace, error) {
+	data, err := os.ReadFile(filepath.Join(root, File))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", File, err)
+	}
+
+	ws := &Workspace{Root: root}
+	if err := json.Unmarshal(data, &ws.Config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", File, err)
+	}
+	if len(ws.Config.Members) == 0 {
+		return nil, fmt.Errorf("%s does not list any members", File)
+	}
+
+	for _, path := range ws.Config.Members {
+		memberRoot := filepath.Join(root, path)
+		if !utils.IsOpenMPProject(memberRoot) {
+			return nil, fmt.Errorf("workspace member %s is not an open.mp project", path)
+		}
+
+		config, err := utils.GetProjectConfig(memberRoot)
+		if err != nil {
+			return nil, fmt.Errorf("workspace member %s: %w", path, err)
+		}
+
+		if ws.Member(config.Name) != nil {
+			return nil, fmt.Errorf("workspace has more than one member named %q", config.Name)
+		}
+		ws.Members = append(ws.Members, &Member{Name: config.Name, Root: memberRoot, Config: config})
+	}
+
+	return ws, nil
+}
+
+// Member returns the member with the given name, or nil
+func (w *Workspace) Member(name string) *Member {
+	for _, m := range w.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// PawnccPath returns the compiler directory shared by all members, or an
+// empty string when each member uses its own
+func (w *Workspace) PawnccPath() string {
+	if w.Config.PawnccPath == "" {
+		return ""
+	}
+	return w.abs(w.Root, w.Config.PawnccPath)
+}
+
+// IncludePaths returns the absolute include directories for a member:
+// its own include_paths, those of the libraries it uses and the
+// workspace-wide include_paths, in that order
+func (w *Workspace) IncludePaths(m *Member) ([]string, error) {
+	var paths []string
+	for _, p := range m.Config.IncludePaths {
+		paths = append(paths, w.abs(m.Root, p))
+	}
+
+	libraries, err := w.Libraries(m)
+	if err != nil {
+		return nil, err
+	}
+	for _, lib := range libraries {
+		if len(lib.Config.IncludePaths) == 0 {
+			paths = append(paths, lib.Root)
+		}
+		for _, p := range lib.Config.IncludePaths {
+			paths = append(paths, w.abs(lib.Root, p))
+		}
+
+		// Users of a library need the packages it depends on as well
+		libDeps, err := deps.IncludePaths(lib.Root)
+		if err != nil {
+			return nil, fmt.Errorf("library %s: %w", lib.Name, err)
+		}
+		paths = append(paths, libDeps...)
+	}
+
+	for _, p := range w.Config.IncludePaths {
+		paths = append(paths, w.abs(w.Root, p))
+	}
+
+	return dedupe(paths), nil
+}
+
+// Libraries returns the library members m uses, including the libraries
+// those libraries use, in dependency order
+func (w *Workspace) Libraries(m *Member) ([]*Member, error) {
+	var ordered []*Member
+	state := map[string]int{} // 1 = visiting, 2 = done
+
+	var visit func(*Member) error
+	visit = func(cur *Member) error {
+		for _, name := range cur.Config.Libraries {
+			lib := w.Member(name)
+			if lib == nil {
+				return fmt.Errorf("%s uses unknown library %q", cur.Name, name)
+			}
+			if lib.Config.ProjectType() != utils.ProjectTypeLibrary {
+				return fmt.Errorf("%s uses %q, which is a %s, not a library", cur.Name, name, lib.Config.ProjectType())
+			}
+
+			switch state[name] {
+			case 1:
+				return fmt.Errorf("library %q depends on itself", name)
+			case 2:
+				continue
+			}
+
+			state[name] = 1
+			if err := visit(lib); err != nil {
+				return err
+			}
+			state[name] = 2
+			ordered = append(ordered, lib)
+		}
+		return nil
+	}
+
+	if err := visit(m); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// BuildOrder sorts members so that libraries come before their users
+func (w *Workspace) BuildOrder(members []*Member) ([]*Member, error) {
+	var ordered []*Member
+	seen := map[string]bool{}
+	for _, m := range members {
+		libraries, err := w.Libraries(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range append(libraries, m) {
+			if !seen[dep.Name] && contains(members, dep) {
+				seen[dep.Name] = true
+				ordered = append(ordered, dep)
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// Validate checks a member's configuration and the files it refers to
+func (w *Workspace) Validate(m *Member) []error {
+	var problems []error
+
+	if err := m.Config.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if m.Config.MainFile != "" {
+		if _, err := os.Stat(filepath.Join(m.Root, m.Config.MainFile)); err != nil {
+			problems = append(problems, fmt.Errorf("main_file %s does not exist", m.Config.MainFile))
+		}
+	}
+
+	if err := deps.Check(m.Root, m.Config); err != nil {
+		problems = append(problems, err)
+	}
+
+	if includes, err := w.IncludePaths(m); err != nil {
+		problems = append(problems, err)
+	} else {
+		for _, dir := range includes {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				problems = append(problems, fmt.Errorf("include path %s is not a directory", dir))
+			}
+		}
+	}
+
+	if _, err := utils.ResolveResources(m.Root, m.Config.Resources); err != nil {
+		problems = append(problems, err)
+	}
+	for _, plugin := range m.Config.Plugins {
+		if _, _, err := plugin.Resolve(m.Root, runtime.GOOS); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if m.Config.ServerVersion != "" {
+		if _, err := server.Find(m.Config.ServerVersion); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if m.Config.ProjectType() != utils.ProjectTypeLibrary {
+		serverConfig, err := utils.GetServerConfig(m.Root)
+		if err != nil {
+			problems = append(problems, err)
+		} else if err := serverConfig.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("config.json: %w", err))
+		}
+	}
+
+	return problems
+}
+
+// standalone wraps the project containing dir in an implicit workspace
+func standalone(dir, member string) (*Workspace, []*Member, error) {
+	root, err := utils.FindProjectRoot(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := utils.GetProjectConfig(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get project configuration: %w", err)
+	}
+	if member != "" && member != config.Name {
+		return nil, nil, fmt.Errorf("%s is not part of a workspace (no %s found)", root, File)
+	}
+
+	m := &Member{Name: config.Name, Root: root, Config: config}
+	ws := &Workspace{Root: root, Members: []*Member{m}, Implicit: true}
+	return ws, ws.Members, nil
+}
+
+// findUp returns the nearest directory at or above dir containing name
+func findUp(dir, name string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// abs resolves path relative to base unless it is already absolute
+func (w *Workspace) abs(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// contains reports whether m is one of members
+func contains(members []*Member, m *Member) bool {
+	for _, other := range members {
+		if other == m {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupe removes repeated entries while keeping the first occurrence
+func dedupe(paths []string) []string {
+	seen := map[string]bool{}
+	out := paths[:0]
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+// Package amx reads compiled Pawn scripts (.amx files): their header, the
+// tables of public functions, natives, libraries, public variables and
+// tags, the debug information of scripts compiled with -d2 or -d3, and
+// their instructions.
+package amx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Magic numbers of the three cell sizes
+const (
+	Magic16 = 0xF1E2
+	Magic32 = 0xF1E0
+	Magic64 = 0xF1E1
+)
+
+// DebugMagic starts the debug information the compiler appends with -d2
+// or -d3
+const DebugMagic = 0xF1EF
+
+// HeaderSize is the size of the header on disk
+const HeaderSize = 56
+
+// Header flags
+const (
+	FlagDebug    = 0x02
+	FlagCompact  = 0x04
+	FlagSleep    = 0x08
+	FlagNoChecks = 0x10
+	FlagNtvReg   = 0x1000
+	FlagJITC     = 0x2000
+	FlagBrowse   = 0x4000
+	FlagReloc    = 0x8000
+)
+
+// flagNames names the header flags, in the order they are listed
+var flagNames = []struct {
+	flag uint16
+	name string
+}{
+	{FlagDebug, "debug"},
+	{FlagCompact, "compact"},
+	{FlagSleep, "sleep"},
+	{FlagNoChecks, "nochecks"},
+	{FlagNtvReg, "ntvreg"},
+	{FlagJITC, "jitc"},
+	{FlagBrowse, "browse"},
+	{FlagReloc, "reloc"},
+}
+
+// Header is the header of an AMX file. The section fields are offsets from
+// the start of the file.
+type Header struct {
+	Size        int32  `json:"size"`
+	Magic       uint16 `json:"magic"`
+	FileVersion uint8  `json:"file_version"`
+	AMXVersion  uint8  `json:"amx_version"`
+	Flags       uint16 `json:"flags"`
+	DefSize     uint16 `json:"defsize"`
+	COD         int32  `json:"cod"`
+	DAT         int32  `json:"dat"`
+	HEA         int32  `json:"hea"`
+	STP         int32  `json:"stp"`
+	CIP         int32  `json:"cip"`
+	Publics     int32  `json:"publics"`
+	Natives     int32  `json:"natives"`
+	Libraries   int32  `json:"libraries"`
+	PubVars     int32  `json:"pubvars"`
+	Tags        int32  `json:"tags"`
+	NameTable   int32  `json:"nametable"`
+}
+
+// Symbol is an entry of the publics, natives, libraries or pubvars table.
+// Addresses of publics are relative to the code section and those of
+// public variables to the data section; natives and libraries have none
+// until the script is loaded.
+type Symbol struct {
+	Name    string `json:"name"`
+	Address uint64 `json:"address"`
+}
+
+// Tag is an entry of the tags table
+type Tag struct {
+	Name string `json:"name"`
+	ID   uint64 `json:"id"`
+}
+
+// File is a parsed AMX file
+type File struct {
+	Header Header `json:"header"`
+
+	// CellSize is the size of a cell in bytes: 2, 4 or 8
+	CellSize int `json:"cell_size"`
+
+	// FlagNames lists the flags set in the header
+	FlagNames []string `json:"flag_names"`
+
+	// CodeSize and DataSize are the sizes of the code and data sections,
+	// and StackHeapSize the memory reserved for the stack and heap
+	CodeSize      int64 `json:"code_size"`
+	DataSize      int64 `json:"data_size"`
+	StackHeapSize int64 `json:"stack_heap_size"`
+
+	// HasMain reports whether the script has a main function, which starts
+	// at Header.CIP
+	HasMain bool `json:"has_main"`
+
+	// DebugInfo reports whether the file carries debug information
+	DebugInfo bool `json:"debug_info"`
+
+	Publics   []Symbol `json:"publics"`
+	Natives   []Symbol `json:"natives"`
+	Libraries []Symbol `json:"libraries"`
+	PubVars   []Symbol `json:"pubvars"`
+	Tags      []Tag    `json:"tags"`
+
+	// data is the whole file
+	data []byte
+
+	// debug caches the parsed debug information
+	debug *Debug
+}
+
+// Open reads and parses the AMX file at path
+func Open(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse parses the contents of an AMX file
+func Parse(data []byte) (*File, error) {
+	if len(data) < HeaderSize {
+		return nil, fmt.Errorf("file too short for an AMX header (%d bytes)", len(data))
+	}
+
+	le := binary.LittleEndian
+	h := Header{
+		Size:        int32(le.Uint32(data[0:])),
+		Magic:       le.Uint16(data[4:]),
+		FileVersion: data[6],
+		AMXVersion:  data[7],
+		Flags:       le.Uint16(data[8:]),
+		DefSize:     le.Uint16(data[10:]),
+		COD:         int32(le.Uint32(data[12:])),
+		DAT:         int32(le.Uint32(data[16:])),
+		HEA:         int32(le.Uint32(data[20:])),
+		STP:         int32(le.Uint32(data[24:])),
+		CIP:         int32(le.Uint32(data[28:])),
+		Publics:     int32(le.Uint32(data[32:])),
+		Natives:     int32(le.Uint32(data[36:])),
+		Libraries:   int32(le.Uint32(data[40:])),
+		PubVars:     int32(le.Uint32(data[44:])),
+		Tags:        int32(le.Uint32(data[48:])),
+		NameTable:   int32(le.Uint32(data[52:])),
+	}
+
+	f := &File{Header: h, data: data}
+	switch h.Magic {
+	case Magic16:
+		f.CellSize = 2
+	case Magic32:
+		f.CellSize = 4
+	case Magic64:
+		f.CellSize = 8
+	default:
+		return nil, fmt.Errorf("not an AMX file (magic %#04x)", h.Magic)
+	}
+
+	if h.Size < HeaderSize || int(h.Size) > len(data) {
+		return nil, fmt.Errorf("header size %d does not match the file size %d", h.Size, len(data))
+	}
+	// Compact files store the code and data sections encoded, so DAT is
+	// only known to lie within the file once they are expanded
+	end := h.DAT
+	if h.Flags&FlagCompact != 0 {
+		end = h.COD
+	}
+	offsets := []int32{h.Publics, h.Natives, h.Libraries, h.PubVars, h.Tags, h.NameTable, h.COD, h.DAT}
+	if !sort.SliceIsSorted(offsets, func(i, j int) bool { return offsets[i] < offsets[j] }) || h.Publics < HeaderSize || end > h.Size {
+		return nil, fmt.Errorf("corrupt AMX header: sections out of order")
+	}
+	if h.HEA < h.DAT || h.STP < h.HEA {
+		return nil, fmt.Errorf("corrupt AMX header: bad data, heap or stack size")
+	}
+
+	for _, fl := range flagNames {
+		if h.Flags&fl.flag != 0 {
+			f.FlagNames = append(f.FlagNames, fl.name)
+		}
+	}
+	f.CodeSize = int64(h.DAT - h.COD)
+	f.DataSize = int64(h.HEA - h.DAT)
+	f.StackHeapSize = int64(h.STP - h.HEA)
+	f.HasMain = h.CIP >= 0
+	f.DebugInfo = h.Flags&FlagDebug != 0 && len(data) >= int(h.Size)+6 && le.Uint16(data[h.Size+4:]) == DebugMagic
+
+	var err error
+	if f.Publics, err = f.table(h.Publics, h.Natives); err != nil {
+		return nil, fmt.Errorf("publics table: %w", err)
+	}
+	if f.Natives, err = f.table(h.Natives, h.Libraries); err != nil {
+		return nil, fmt.Errorf("natives table: %w", err)
+	}
+	if f.Libraries, err = f.table(h.Libraries, h.PubVars); err != nil {
+		return nil, fmt.Errorf("libraries table: %w", err)
+	}
+	if f.PubVars, err = f.table(h.PubVars, h.Tags); err != nil {
+		return nil, fmt.Errorf("pubvars table: %w", err)
+	}
+	tags, err := f.table(h.Tags, h.NameTable)
+	if err != nil {
+		return nil, fmt.Errorf("tags table: %w", err)
+	}
+	for _, t := range tags {
+		f.Tags = append(f.Tags, Tag{Name: t.Name, ID: t.Address})
+	}
+	return f, nil
+}
+
+// NativeNames returns the names of the natives the script calls
+func (f *File) NativeNames() []string {
+	names := make([]string, len(f.Natives))
+	for i, n := range f.Natives {
+		names[i] = n.Name
+	}
+	return names
+}
+
+// Data returns the contents of the whole file
+func (f *File) Data() []byte {
+	return f.data
+}
+
+// table reads the records between the offsets start and end. Files since
+// version 7 keep the names in a name table and records hold an offset into
+// it; older ones store a fixed-size name in each record.
+func (f *File) table(start, end int32) ([]Symbol, error) {
+	size := int32(f.Header.DefSize)
+	cell := int32(f.CellSize)
+	if size < cell+4 {
+		return nil, fmt.Errorf("record size %d is too small", size)
+	}
+	if (end-start)%size != 0 {
+		return nil, fmt.Errorf("size %d is not a multiple of the record size %d", end-start, size)
+	}
+
+	symbols := []Symbol{}
+	for off := start; off < end; off += size {
+		record := f.data[off : off+size]
+		s := Symbol{Address: readCell(record, f.CellSize)}
+		if size == cell+4 {
+			nameOfs := int32(binary.LittleEndian.Uint32(record[cell:]))
+			name, err := f.cString(nameOfs)
+			if err != nil {
+				return nil, err
+			}
+			s.Name = name
+		} else {
+			s.Name = cString(record[cell:])
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols, nil
+}
+
+// cString reads the zero-terminated string at off in the name table
+func (f *File) cString(off int32) (string, error) {
+	if off < f.Header.NameTable || off >= f.Header.COD {
+		return "", fmt.Errorf("name offset %d is outside of the name table", off)
+	}
+	return cString(f.data[off:f.Header.COD]), nil
+}
+
+// cString returns b up to its first zero byte
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
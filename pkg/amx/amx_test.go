@@ -0,0 +1,99 @@
+package amx
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readFixture reads a script of testdata, written by testdata/gen.go
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(readFixture(t, "gm.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.CellSize != 4 || f.Header.FileVersion != 11 || f.CodeSize != 116 || f.DataSize != 20 || f.StackHeapSize != 16384 {
+		t.Errorf("file = %+v", f)
+	}
+	if f.HasMain || !f.DebugInfo {
+		t.Errorf("has main = %t, debug info = %t", f.HasMain, f.DebugInfo)
+	}
+	if want := []string{"debug", "nochecks"}; !reflect.DeepEqual(f.FlagNames, want) {
+		t.Errorf("flags = %v, want %v", f.FlagNames, want)
+	}
+	if want := []Symbol{{Name: "OnGameModeInit", Address: 0x20}}; !reflect.DeepEqual(f.Publics, want) {
+		t.Errorf("publics = %+v, want %+v", f.Publics, want)
+	}
+	if want := []string{"print", "SetGameModeText"}; !reflect.DeepEqual(f.NativeNames(), want) {
+		t.Errorf("natives = %v, want %v", f.NativeNames(), want)
+	}
+	if len(f.Libraries) != 0 || len(f.PubVars) != 0 {
+		t.Errorf("libraries = %v, pubvars = %v", f.Libraries, f.PubVars)
+	}
+	if want := []Tag{{Name: "Float", ID: 0x40000001}, {Name: "_"}}; !reflect.DeepEqual(f.Tags, want) {
+		t.Errorf("tags = %+v, want %+v", f.Tags, want)
+	}
+
+	f, err = Parse(readFixture(t, "gm-nodebug.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.DebugInfo || len(f.FlagNames) != 1 {
+		t.Errorf("debug info = %t, flags = %v", f.DebugInfo, f.FlagNames)
+	}
+}
+
+func TestParseRejectsCorruptFiles(t *testing.T) {
+	// set returns the fixture with a field of the header replaced
+	set := func(off int, v uint32) []byte {
+		data := readFixture(t, "gm-nodebug.amx")
+		binary.LittleEndian.PutUint32(data[off:], v)
+		return data
+	}
+	badMagic := readFixture(t, "gm-nodebug.amx")
+	binary.LittleEndian.PutUint16(badMagic[4:], DebugMagic)
+	data := readFixture(t, "gm-nodebug.amx")
+	h := func(off int) uint32 { return binary.LittleEndian.Uint32(data[off:]) }
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "empty", want: "too short"},
+		{name: "truncated header", data: data[:HeaderSize-1], want: "too short"},
+		{name: "truncated file", data: data[:len(data)-1], want: "does not match the file size"},
+		{name: "size below header", data: set(0, HeaderSize-1), want: "does not match the file size"},
+		{name: "bad magic", data: badMagic, want: "not an AMX file"},
+		{name: "publics in header", data: set(32, 8), want: "out of order"},
+		{name: "natives before publics", data: set(36, h(32)-8), want: "out of order"},
+		{name: "name table after code", data: set(52, h(12)+4), want: "out of order"},
+		{name: "data past the end", data: set(16, uint32(len(data))+4), want: "out of order"},
+		{name: "heap before data", data: set(20, h(16)-4), want: "bad data, heap or stack size"},
+		{name: "stack before heap", data: set(24, h(20)-4), want: "bad data, heap or stack size"},
+		{name: "ragged natives table", data: set(40, h(40)-4), want: "natives table: size"},
+		{name: "name outside name table", data: set(60, h(12)), want: "publics table: name offset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package amx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// debugHeaderSize is the size of the header of the debug information
+const debugHeaderSize = 22
+
+// Symbol kinds of the debug information
+const (
+	IdentVariable  = 1
+	IdentReference = 2
+	IdentArray     = 3
+	IdentRefArray  = 4
+	IdentFunction  = 9
+)
+
+// Debug is the debug information the compiler appends to a script built
+// with -d2 or -d3. Addresses are relative to the code section.
+type Debug struct {
+	Files   []DebugFile
+	Lines   []DebugLine
+	Symbols []DebugSymbol
+	Tags    []Tag
+
+	// functions are the function symbols by CodeStart, for Function
+	functionsOnce sync.Once
+	functions     []*DebugSymbol
+}
+
+// DebugFile starts the code compiled from a source file
+type DebugFile struct {
+	Address uint64
+	Name    string
+}
+
+// DebugLine starts the code of a source line; Line counts from 1
+type DebugLine struct {
+	Address uint64
+	Line    int
+}
+
+// DebugSymbol is a function or variable. Functions span the code from
+// CodeStart up to CodeEnd; variables are visible there, and Address is
+// their location in the data section or, for locals, on the stack frame.
+type DebugSymbol struct {
+	Name      string
+	Address   int64
+	Tag       int
+	CodeStart uint64
+	CodeEnd   uint64
+	Ident     int
+	VClass    int
+	Dims      []DebugDim
+}
+
+// DebugDim is a dimension of an array symbol
+type DebugDim struct {
+	Tag  int
+	Size uint64
+}
+
+// Debug returns the debug information of the script, or nil when it has
+// none
+func (f *File) Debug() (*Debug, error) {
+	if !f.DebugInfo {
+		return nil, nil
+	}
+	if f.debug != nil {
+		return f.debug, nil
+	}
+
+	r := &reader{data: f.data, off: int(f.Header.Size), cell: f.CellSize}
+	size := int(r.u32())
+	r.skip(2 + 1 + 1 + 2)
+	files, lines, symbols, tags := r.u16(), r.u16(), r.u16(), r.u16()
+	r.skip(2 + 2) // automatons and states
+	if size < debugHeaderSize || int(f.Header.Size)+size > len(f.data) {
+		return nil, fmt.Errorf("corrupt debug information: size %d", size)
+	}
+	r.data = f.data[:int(f.Header.Size)+size]
+
+	d := &Debug{}
+	for i := 0; i < files && r.err == nil; i++ {
+		d.Files = append(d.Files, DebugFile{Address: r.ucell(), Name: r.str()})
+	}
+	for i := 0; i < lines && r.err == nil; i++ {
+		d.Lines = append(d.Lines, DebugLine{Address: r.ucell(), Line: int(int32(r.u32())) + 1})
+	}
+	for i := 0; i < symbols && r.err == nil; i++ {
+		s := DebugSymbol{Address: r.cellValue(), Tag: int(int16(r.u16()))}
+		s.CodeStart, s.CodeEnd = r.ucell(), r.ucell()
+		s.Ident, s.VClass = int(r.u8()), int(r.u8())
+		dims := int(int16(r.u16()))
+		s.Name = r.str()
+		for j := 0; j < dims && r.err == nil; j++ {
+			s.Dims = append(s.Dims, DebugDim{Tag: int(int16(r.u16())), Size: r.ucell()})
+		}
+		d.Symbols = append(d.Symbols, s)
+	}
+	for i := 0; i < tags && r.err == nil; i++ {
+		id := uint64(r.u16())
+		d.Tags = append(d.Tags, Tag{ID: id, Name: r.str()})
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("corrupt debug information: %w", r.err)
+	}
+
+	// The compiler writes the tables in address order; make sure of it
+	sort.SliceStable(d.Files, func(i, j int) bool { return d.Files[i].Address < d.Files[j].Address })
+	sort.SliceStable(d.Lines, func(i, j int) bool { return d.Lines[i].Address < d.Lines[j].Address })
+
+	f.debug = d
+	return d, nil
+}
+
+// File returns the source file the code at addr was compiled from
+func (d *Debug) File(addr uint64) (string, bool) {
+	i := sort.Search(len(d.Files), func(i int) bool { return d.Files[i].Address > addr })
+	if i == 0 {
+		return "", false
+	}
+	return d.Files[i-1].Name, true
+}
+
+// Line returns the source line the code at addr was compiled from
+func (d *Debug) Line(addr uint64) (int, bool) {
+	i := sort.Search(len(d.Lines), func(i int) bool { return d.Lines[i].Address > addr })
+	if i == 0 {
+		return 0, false
+	}
+	return d.Lines[i-1].Line, true
+}
+
+// Function returns the function holding the code at addr
+func (d *Debug) Function(addr uint64) (*DebugSymbol, bool) {
+	d.functionsOnce.Do(func() {
+		for i := range d.Symbols {
+			if d.Symbols[i].Ident == IdentFunction {
+				d.functions = append(d.functions, &d.Symbols[i])
+			}
+		}
+		sort.SliceStable(d.functions, func(i, j int) bool { return d.functions[i].CodeStart < d.functions[j].CodeStart })
+	})
+
+	// Functions do not overlap, so only the last one starting at or
+	// before addr can hold it
+	i := sort.Search(len(d.functions), func(i int) bool { return d.functions[i].CodeStart > addr })
+	if i == 0 || addr >= d.functions[i-1].CodeEnd {
+		return nil, false
+	}
+	return d.functions[i-1], true
+}
+
+// Location is where a code address comes from in the source
+type Location struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// String formats the location as "function at file:line", leaving out
+// what is not known
+func (l Location) String() string {
+	var parts []string
+	if l.Function != "" {
+		parts = append(parts, l.Function)
+	}
+	if l.File != "" {
+		at := l.File
+		if l.Line > 0 {
+			at += ":" + strconv.Itoa(l.Line)
+		}
+		parts = append(parts, "at "+at)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Locate returns the function, file and line of a code address from the
+// debug information; without it, the location is empty
+func (f *File) Locate(addr uint64) Location {
+	d, err := f.Debug()
+	if err != nil || d == nil {
+		return Location{}
+	}
+
+	var loc Location
+	if fn, ok := d.Function(addr); ok {
+		loc.Function = fn.Name
+	}
+	loc.File, _ = d.File(addr)
+	loc.Line, _ = d.Line(addr)
+	return loc
+}
+
+// reader reads the little-endian, unaligned records of an AMX file. The
+// first read past the end sets err; later reads return zero.
+type reader struct {
+	data []byte
+	off  int
+	cell int
+	err  error
+}
+
+// take returns the next n bytes
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if r.off+n > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of data at offset %d", r.off)
+		return make([]byte, n)
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) skip(n int)    { r.take(n) }
+func (r *reader) u8() uint8     { return r.take(1)[0] }
+func (r *reader) u16() int      { return int(binary.LittleEndian.Uint16(r.take(2))) }
+func (r *reader) u32() uint32   { return binary.LittleEndian.Uint32(r.take(4)) }
+func (r *reader) ucell() uint64 { return readCell(r.take(r.cell), r.cell) }
+
+// cellValue reads a signed cell
+func (r *reader) cellValue() int64 {
+	return signExtend(r.ucell(), r.cell)
+}
+
+// str reads a zero-terminated string
+func (r *reader) str() string {
+	if r.err != nil {
+		return ""
+	}
+	for i := r.off; i < len(r.data); i++ {
+		if r.data[i] == 0 {
+			s := string(r.data[r.off:i])
+			r.off = i + 1
+			return s
+		}
+	}
+	r.err = fmt.Errorf("unterminated string at offset %d", r.off)
+	return ""
+}
+
+// readCell reads an unsigned cell of the given size
+func readCell(b []byte, size int) uint64 {
+	switch size {
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 8:
+		return binary.LittleEndian.Uint64(b)
+	}
+	return uint64(binary.LittleEndian.Uint32(b))
+}
+
+// signExtend turns an unsigned cell into its signed value
+func signExtend(v uint64, size int) int64 {
+	shift := 64 - 8*size
+	return int64(v<<shift) >> shift
+}
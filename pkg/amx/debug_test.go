@@ -0,0 +1,85 @@
+package amx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDebugFunction(t *testing.T) {
+	d := &Debug{Symbols: []DebugSymbol{
+		{Name: "OnGameModeInit", Ident: IdentFunction, CodeStart: 0x40, CodeEnd: 0x80},
+		{Name: "count", Ident: IdentVariable, CodeStart: 0x00, CodeEnd: 0x40},
+		{Name: "main", Ident: IdentFunction, CodeStart: 0x08, CodeEnd: 0x40},
+		{Name: "helper", Ident: IdentFunction, CodeStart: 0x90, CodeEnd: 0xa0},
+	}}
+
+	tests := []struct {
+		addr uint64
+		want string
+	}{
+		{addr: 0x00},
+		{addr: 0x08, want: "main"},
+		{addr: 0x3c, want: "main"},
+		{addr: 0x40, want: "OnGameModeInit"},
+		{addr: 0x84},
+		{addr: 0x9c, want: "helper"},
+		{addr: 0xa0},
+	}
+	for _, tt := range tests {
+		fn, ok := d.Function(tt.addr)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("Function(%#x) = %s, want none", tt.addr, fn.Name)
+		case tt.want != "" && (!ok || fn.Name != tt.want):
+			t.Errorf("Function(%#x) = %v, %t, want %s", tt.addr, fn, ok, tt.want)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	f, err := Parse(readFixture(t, "gm.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr uint64
+		want Location
+	}{
+		{addr: 0x00, want: Location{File: "gamemodes/gm.pwn"}},
+		{addr: 0x10, want: Location{Function: "Add", File: "gamemodes/gm.pwn", Line: 3}},
+		{addr: 0x20, want: Location{Function: "OnGameModeInit", File: "gamemodes/gm.pwn", Line: 7}},
+		{addr: 0x38, want: Location{Function: "OnGameModeInit", File: "gamemodes/gm.pwn", Line: 8}},
+		{addr: 0x5c, want: Location{Function: "OnGameModeInit", File: "gamemodes/gm.pwn", Line: 9}},
+		{addr: 0x70, want: Location{Function: "OnGameModeInit", File: "gamemodes/gm.pwn", Line: 10}},
+	}
+	for _, tt := range tests {
+		if got := f.Locate(tt.addr); got != tt.want {
+			t.Errorf("Locate(%#x) = %+v, want %+v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDebugWithoutDebugInfo(t *testing.T) {
+	f, err := Parse(readFixture(t, "gm-nodebug.amx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := f.Debug(); d != nil || err != nil {
+		t.Errorf("Debug() = %v, %v", d, err)
+	}
+	if loc := f.Locate(0x20); loc != (Location{}) {
+		t.Errorf("Locate = %+v", loc)
+	}
+}
+
+func TestDebugRejectsTruncatedInfo(t *testing.T) {
+	data := readFixture(t, "gm.amx")
+	f, err := Parse(data[:len(data)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Debug(); err == nil || !strings.Contains(err.Error(), "corrupt debug information") {
+		t.Errorf("Debug error = %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package amx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Opcode describes an instruction of the abstract machine
+type Opcode struct {
+	// Name is the mnemonic, such as "load.s.pri"
+	Name string
+
+	// Operands is the number of operand cells that follow the opcode;
+	// CASETBL and the obsolete FILE and SYMBOL have a variable number
+	Operands int
+
+	// Packed opcodes carry their single operand in the upper half of the
+	// opcode cell instead of the cell after it
+	Packed bool
+}
+
+// Opcodes with special operands
+const (
+	OpCall     = 49
+	OpJump     = 51
+	OpJrel     = 52
+	OpSysreqC  = 123
+	OpFile     = 124
+	OpSymbol   = 126
+	OpSwitch   = 129
+	OpCasetbl  = 130
+	OpSysreqN  = 135
+	OpSysreqD  = 158
+	OpSysreqND = 159
+)
+
+// variable marks opcodes whose operand count depends on their operands
+const variable = -1
+
+// Opcodes lists the instructions by number: those of Pawn 3.2, which the
+// open.mp compiler emits, followed by the packed opcodes added in Pawn 3.3
+var Opcodes = buildOpcodes()
+
+// buildOpcodes builds the opcode table from the mnemonics
+func buildOpcodes() []Opcode {
+	names := []string{
+		"none", "load.pri 1", "load.alt 1", "load.s.pri 1", "load.s.alt 1",
+		"lref.pri 1", "lref.alt 1", "lref.s.pri 1", "lref.s.alt 1", "load.i",
+		"lodb.i 1", "const.pri 1", "const.alt 1", "addr.pri 1", "addr.alt 1",
+		"stor.pri 1", "stor.alt 1", "stor.s.pri 1", "stor.s.alt 1", "sref.pri 1",
+		"sref.alt 1", "sref.s.pri 1", "sref.s.alt 1", "stor.i", "strb.i 1",
+		"lidx", "lidx.b 1", "idxaddr", "idxaddr.b 1", "align.pri 1",
+		"align.alt 1", "lctrl 1", "sctrl 1", "move.pri", "move.alt",
+		"xchg", "push.pri", "push.alt", "push.r 1", "push.c 1",
+		"push 1", "push.s 1", "pop.pri", "pop.alt", "stack 1",
+		"heap 1", "proc", "ret", "retn", "call 1",
+		"call.pri", "jump 1", "jrel 1", "jzer 1", "jnz 1",
+		"jeq 1", "jneq 1", "jless 1", "jleq 1", "jgrtr 1",
+		"jgeq 1", "jsless 1", "jsleq 1", "jsgrtr 1", "jsgeq 1",
+		"shl", "shr", "sshr", "shl.c.pri 1", "shl.c.alt 1",
+		"shr.c.pri 1", "shr.c.alt 1", "smul", "sdiv", "sdiv.alt",
+		"umul", "udiv", "udiv.alt", "add", "sub",
+		"sub.alt", "and", "or", "xor", "not",
+		"neg", "invert", "add.c 1", "smul.c 1", "zero.pri",
+		"zero.alt", "zero 1", "zero.s 1", "sign.pri", "sign.alt",
+		"eq", "neq", "less", "leq", "grtr",
+		"geq", "sless", "sleq", "sgrtr", "sgeq",
+		"eq.c.pri 1", "eq.c.alt 1", "inc.pri", "inc.alt", "inc 1",
+		"inc.s 1", "inc.i", "dec.pri", "dec.alt", "dec 1",
+		"dec.s 1", "dec.i", "movs 1", "cmps 1", "fill 1",
+		"halt 1", "bounds 1", "sysreq.pri", "sysreq.c 1", "file *",
+		"line 2", "symbol *", "srange 2", "jump.pri", "switch 1",
+		"casetbl *", "swap.pri", "swap.alt", "push.adr 1", "nop",
+		"sysreq.n 2", "symtag 1", "break", "push2.c 2", "push2 2",
+		"push2.s 2", "push2.adr 2", "push3.c 3", "push3 3", "push3.s 3",
+		"push3.adr 3", "push4.c 4", "push4 4", "push4.s 4", "push4.adr 4",
+		"push5.c 5", "push5 5", "push5.s 5", "push5.adr 5", "load.both 2",
+		"load.s.both 2", "const 2", "const.s 2", "sysreq.d 1", "sysreq.nd 2",
+
+		// Packed opcodes
+		"load.p.pri", "load.p.alt", "load.p.s.pri", "load.p.s.alt", "lref.p.pri",
+		"lref.p.alt", "lref.p.s.pri", "lref.p.s.alt", "lodb.p.i", "const.p.pri",
+		"const.p.alt", "addr.p.pri", "addr.p.alt", "stor.p.pri", "stor.p.alt",
+		"stor.p.s.pri", "stor.p.s.alt", "sref.p.pri", "sref.p.alt", "sref.p.s.pri",
+		"sref.p.s.alt", "strb.p.i", "lidx.p.b", "idxaddr.p.b", "align.p.pri",
+		"align.p.alt", "push.p.c", "push.p", "push.p.s", "stack.p",
+		"heap.p", "shl.p.c.pri", "shl.p.c.alt", "shr.p.c.pri", "shr.p.c.alt",
+		"add.p.c", "smul.p.c", "zero.p", "zero.p.s", "eq.p.c.pri",
+		"eq.p.c.alt", "inc.p", "inc.p.s", "dec.p", "dec.p.s",
+		"movs.p", "cmps.p", "fill.p", "halt.p", "bounds.p",
+		"push.p.adr",
+	}
+
+	opcodes := make([]Opcode, len(names))
+	for i, spec := range names {
+		name, operands, _ := strings.Cut(spec, " ")
+		op := Opcode{Name: name}
+		switch operands {
+		case "":
+		case "*":
+			op.Operands = variable
+		default:
+			op.Operands, _ = strconv.Atoi(operands)
+		}
+		op.Packed = i > OpSysreqND
+		opcodes[i] = op
+	}
+	return opcodes
+}
+
+// Instruction is a decoded instruction
+type Instruction struct {
+	// Address is the offset of the instruction in the code section
+	Address uint64
+
+	// Opcode is the instruction number and Name its mnemonic
+	Opcode int
+	Name   string
+
+	// Operands are the operands as signed cells, a packed operand
+	// included
+	Operands []int64
+
+	// Size is the number of bytes the instruction takes
+	Size int
+}
+
+// Code returns the code section, expanded when the file uses the compact
+// encoding
+func (f *File) Code() ([]byte, error) {
+	h := f.Header
+	if h.Flags&FlagCompact == 0 {
+		return f.data[h.COD:h.DAT], nil
+	}
+
+	image, err := expand(f.data[h.COD:h.Size], f.CellSize, int(h.HEA-h.COD))
+	if err != nil {
+		return nil, err
+	}
+	return image[:h.DAT-h.COD], nil
+}
+
+// expand decodes the compact encoding, in which every cell is stored in
+// groups of 7 bits, most significant first, with the high bit set on all
+// but the last byte and bit 6 of the first byte holding the sign
+func expand(data []byte, cellSize, size int) ([]byte, error) {
+	image := make([]byte, 0, size)
+	for i := 0; i < len(data); {
+		v := int64(data[i] & 0x7f)
+		if data[i]&0x40 != 0 {
+			v -= 0x80
+		}
+		for data[i]&0x80 != 0 {
+			i++
+			if i == len(data) {
+				return nil, fmt.Errorf("truncated compact encoding")
+			}
+			v = v<<7 | int64(data[i]&0x7f)
+		}
+		i++
+
+		if len(image)+cellSize > size {
+			return nil, fmt.Errorf("compact encoding expands beyond the data section")
+		}
+		image = binary.LittleEndian.AppendUint64(image, uint64(v))[:len(image)+cellSize]
+	}
+	return image, nil
+}
+
+// Disassemble decodes the instructions of the code section
+func (f *File) Disassemble() ([]Instruction, error) {
+	code, err := f.Code()
+	if err != nil {
+		return nil, err
+	}
+
+	cell := f.CellSize
+	half := uint(4 * cell)
+	read := func(off int) (int64, error) {
+		if off+cell > len(code) {
+			return 0, fmt.Errorf("instruction at %#x runs past the end of the code", off)
+		}
+		return signExtend(readCell(code[off:], cell), cell), nil
+	}
+
+	var instructions []Instruction
+	for off := 0; off < len(code); {
+		raw, err := read(off)
+		if err != nil {
+			return nil, err
+		}
+
+		// The opcode is in the lower half of the cell, a packed operand in
+		// the upper half
+		number := int(uint64(raw) & (1<<half - 1))
+		if number >= len(Opcodes) {
+			return nil, fmt.Errorf("invalid opcode %d at %#x", number, off)
+		}
+		op := Opcodes[number]
+		ins := Instruction{Address: uint64(off), Opcode: number, Name: op.Name}
+
+		if op.Packed {
+			ins.Operands = []int64{raw >> half}
+		}
+
+		count := op.Operands
+		if count == variable {
+			if count, err = f.variableOperands(number, code, off, read); err != nil {
+				return nil, err
+			}
+		}
+		for i := 1; i <= count; i++ {
+			v, err := read(off + i*cell)
+			if err != nil {
+				return nil, err
+			}
+			ins.Operands = append(ins.Operands, v)
+		}
+
+		ins.Size = (count + 1) * cell
+		off += ins.Size
+		instructions = append(instructions, ins)
+	}
+	return instructions, nil
+}
+
+// variableOperands returns the number of operand cells of a CASETBL, FILE
+// or SYMBOL instruction at off
+func (f *File) variableOperands(number int, code []byte, off int, read func(int) (int64, error)) (int, error) {
+	cell := f.CellSize
+	n, err := read(off + cell)
+	if err != nil {
+		return 0, err
+	}
+
+	switch number {
+	case OpCasetbl:
+		// The number of cases, the default address, then a value and an
+		// address for every case
+		if n < 0 || int(n) > len(code)/cell {
+			return 0, fmt.Errorf("invalid case table at %#x", off)
+		}
+		return 2 + 2*int(n), nil
+	default:
+		// The size in bytes of what follows
+		if n < 0 || off+cell+int(n) > len(code) {
+			return 0, fmt.Errorf("invalid %s at %#x", Opcodes[number].Name, off)
+		}
+		return 1 + (int(n)+cell-1)/cell, nil
+	}
+}
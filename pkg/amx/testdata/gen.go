@@ -0,0 +1,170 @@
+//go:build ignore
+
+// Gen writes gm.amx and gm-nodebug.amx, the 32-bit scripts the tests
+// read. They hold what pawncc -d3 makes of gamemodes/gm.pwn:
+//
+//	 1  #include <open.mp>
+//	 2
+//	 3  Add(a)
+//	 4  	return a + 1;
+//	 5
+//	 6  public OnGameModeInit()
+//	 7  {
+//	 8  	SetGameModeText("Test");
+//	 9  	Add(5);
+//	10  	return 1;
+//	11  }
+//
+// Run it in this folder with go run gen.go.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"os"
+)
+
+func w32(b *bytes.Buffer, v uint32) { binary.Write(b, binary.LittleEndian, v) }
+func w16(b *bytes.Buffer, v uint16) { binary.Write(b, binary.LittleEndian, v) }
+
+func main() {
+	if err := os.WriteFile("gm.amx", script(true), 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("gm-nodebug.amx", script(false), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func script(debug bool) []byte {
+	// Code, with addresses relative to its start
+	var code bytes.Buffer
+	op := func(o uint32, args ...uint32) uint32 {
+		a := uint32(code.Len())
+		w32(&code, o)
+		for _, x := range args {
+			w32(&code, x)
+		}
+		return a
+	}
+	op(120, 0)     // HALT 0
+	add := op(46)  // PROC
+	op(137)        // BREAK
+	op(3, 12)      // LOAD.S.pri 12
+	op(107)        // INC.pri
+	op(48)         // RETN
+	ogmi := op(46) // PROC
+	op(137)        // BREAK
+	op(39, 0)      // PUSH.C 0
+	op(39, 4)      // PUSH.C 4
+	op(123, 1)     // SYSREQ.C SetGameModeText
+	op(44, 8)      // STACK 8
+	op(137)        // BREAK
+	op(39, 5)      // PUSH.C 5
+	op(39, 4)      // PUSH.C 4
+	op(49, add)    // CALL Add
+	op(137)        // BREAK
+	op(11, 1)      // CONST.pri 1
+	op(48)         // RETN
+	end := uint32(code.Len())
+
+	data := []byte{}
+	for _, c := range "Test\x00" {
+		data = binary.LittleEndian.AppendUint32(data, uint32(c))
+	}
+
+	// Name table
+	natives := []string{"print", "SetGameModeText"}
+	names := append(append([]string{"OnGameModeInit"}, natives...), "Float", "_")
+	const publicsOfs = 56
+	nativesOfs := publicsOfs + 8
+	librariesOfs := nativesOfs + 8*len(natives)
+	tagsOfs := librariesOfs
+	nameTableOfs := tagsOfs + 16
+	var nt bytes.Buffer
+	w16(&nt, 31)
+	nameOfs := map[string]uint32{}
+	for _, n := range names {
+		nameOfs[n] = uint32(nameTableOfs + nt.Len())
+		nt.WriteString(n)
+		nt.WriteByte(0)
+	}
+	cod := nameTableOfs + nt.Len()
+	for cod%4 != 0 {
+		nt.WriteByte(0)
+		cod++
+	}
+	dat := cod + code.Len()
+	size := dat + len(data)
+
+	// Header and tables
+	var f bytes.Buffer
+	flags := uint16(0x10)
+	if debug {
+		flags |= 0x02
+	}
+	w32(&f, uint32(size))
+	w16(&f, 0xF1E0)
+	f.WriteByte(11)
+	f.WriteByte(11)
+	w16(&f, flags)
+	w16(&f, 8)
+	for _, v := range []int{cod, dat, size, size + 16384, -1, publicsOfs, nativesOfs, librariesOfs, librariesOfs, tagsOfs, nameTableOfs} {
+		w32(&f, uint32(int32(v)))
+	}
+	w32(&f, ogmi)
+	w32(&f, nameOfs["OnGameModeInit"])
+	for _, n := range natives {
+		w32(&f, 0)
+		w32(&f, nameOfs[n])
+	}
+	w32(&f, 0x40000001)
+	w32(&f, nameOfs["Float"])
+	w32(&f, 0)
+	w32(&f, nameOfs["_"])
+	f.Write(nt.Bytes())
+	f.Write(code.Bytes())
+	f.Write(data)
+	if !debug {
+		return f.Bytes()
+	}
+
+	// Debug information: the file, zero-based lines, symbols and tags
+	var d bytes.Buffer
+	w32(&d, 0)
+	d.WriteString("gamemodes/gm.pwn\x00")
+	lines := [][2]uint32{{add, 2}, {add + 4, 2}, {ogmi, 6}, {ogmi + 4, 7}, {ogmi + 40, 8}, {ogmi + 68, 9}}
+	for _, l := range lines {
+		w32(&d, l[0])
+		w32(&d, l[1])
+	}
+	sym := func(addr uint32, start, stop uint32, ident, vclass byte, name string) {
+		w32(&d, addr)
+		w16(&d, 0)
+		w32(&d, start)
+		w32(&d, stop)
+		d.WriteByte(ident)
+		d.WriteByte(vclass)
+		w16(&d, 0)
+		d.WriteString(name + "\x00")
+	}
+	sym(add, add, ogmi, 9, 0, "Add")
+	sym(12, add, ogmi, 1, 1, "a")
+	sym(ogmi, ogmi, end, 9, 0, "OnGameModeInit")
+	w16(&d, 0)
+	d.WriteString("_\x00")
+	w16(&d, 1)
+	d.WriteString("Float\x00")
+
+	w32(&f, uint32(22+d.Len()))
+	w16(&f, 0xF1EF)
+	f.WriteByte(11)
+	f.WriteByte(11)
+	w16(&f, 0)
+	for _, n := range []uint16{1, uint16(len(lines)), 3, 2, 0, 0} {
+		w16(&f, n)
+	}
+	f.Write(d.Bytes())
+	return f.Bytes()
+}
@@ -0,0 +1,313 @@
+// Package ompcli builds and runs open.mp projects from Go programs, the
+// same way the ompcli command does. Nothing is printed unless writers are
+// given, and results are returned instead:
+//
+//	project, err := ompcli.Load("path/to/project")
+//	if err != nil {
+//		return err
+//	}
+//	result, err := project.Build(ctx, ompcli.BuildOptions{})
+//	for _, d := range result.Diagnostics {
+//		fmt.Printf("%s:%d: %s\n", d.File, d.Line, d.Message)
+//	}
+package ompcli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/builder"
+	"github.com/weltschmerzie/omp-cli/internal/runner"
+	"github.com/weltschmerzie/omp-cli/internal/workspace"
+	"github.com/weltschmerzie/omp-cli/pkg/utils"
+)
+
+// Project is an open.mp project, possibly a member of a workspace
+type Project struct {
+	// Name is the name from project.json and Root the folder holding it
+	Name string
+	Root string
+
+	// Config is the parsed project.json
+	Config *utils.ProjectConfig
+
+	ws     *workspace.Workspace
+	member *workspace.Member
+}
+
+// BuildOptions controls how a project is built
+type BuildOptions struct {
+	// TargetOS is the operating system the server runs on, linux or
+	// windows; plugins are staged for it. Empty means this system.
+	TargetOS string
+
+	// Profile stages config.<profile>.json instead of config.json
+	Profile string
+
+	// IncludePaths are include directories searched before the ones the
+	// project and its workspace configure
+	IncludePaths []string
+
+	// PawnccVersion overrides the compiler version the project pins
+	PawnccVersion string
+
+	// Stdout and Stderr receive the build's messages; nil discards them.
+	// With Verbose, the compiler's own output is written to them as well.
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Verbose bool
+
+	// OnEvent is called for every event of the build when set
+	OnEvent func(BuildEvent)
+
+	// Compiler replaces the pawncc the project is configured with
+	Compiler Compiler
+}
+
+// RunOptions controls how a project is run
+type RunOptions struct {
+	// Debug starts the server in debug mode
+	Debug bool
+
+	// Port overrides the port from config.json when non-zero
+	Port int
+
+	// Standard streams of the server. A nil Stdin gives the server no
+	// input and nil writers discard its output.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// OnEvent is called when the server starts and exits when set
+	OnEvent func(RunEvent)
+
+	// Symbolicate adds the source function, file and line to the frames of
+	// crashdetect's backtraces in the server output, when the gamemode was
+	// compiled with debug information
+	Symbolicate bool
+
+	// Server replaces the server executable of the build; it is started
+	// once
+	Server ServerProcess
+}
+
+// Load loads the project in dir or the nearest parent directory containing
+// project.json. Inside a workspace, dir must be within one of its members;
+// use LoadMember from the workspace root.
+func Load(dir string) (*Project, error) {
+	return load(dir, "")
+}
+
+// LoadMember loads the member called name of the workspace dir belongs to
+func LoadMember(dir, name string) (*Project, error) {
+	if name == "" {
+		return nil, fmt.Errorf("no member name given")
+	}
+	return load(dir, name)
+}
+
+// Build compiles the project and stages it into its build directory. The
+// result is returned even when the build fails, with the compiler's
+// diagnostics. Cancelling ctx stops the compiler.
+func (p *Project) Build(ctx context.Context, opts BuildOptions) (*BuildResult, error) {
+	switch opts.TargetOS {
+	case "", "linux", "windows":
+	default:
+		return nil, fmt.Errorf("unsupported target OS %q (use linux or windows)", opts.TargetOS)
+	}
+
+	includes, err := p.ws.IncludePaths(p.member)
+	if err != nil {
+		return nil, err
+	}
+
+	pawnccVersion := opts.PawnccVersion
+	if pawnccVersion == "" {
+		pawnccVersion = p.ws.Config.PawnccVersion
+	}
+
+	var onEvent func(builder.Event)
+	if opts.OnEvent != nil {
+		onEvent = func(e builder.Event) { opts.OnEvent(buildEvent(e)) }
+	}
+	var compiler builder.Compiler
+	if opts.Compiler != nil {
+		compiler = compilerAdapter{opts.Compiler}
+	}
+
+	result, err := builder.Build(ctx, p.Root, builder.Options{
+		Verbose:       opts.Verbose,
+		IncludePaths:  append(append([]string{}, opts.IncludePaths...), includes...),
+		PawnccPath:    p.ws.PawnccPath(),
+		PawnccVersion: pawnccVersion,
+		TargetOS:      opts.TargetOS,
+		Profile:       opts.Profile,
+		Stdout:        writer(opts.Stdout),
+		Stderr:        writer(opts.Stderr),
+		OnEvent:       onEvent,
+		Compiler:      compiler,
+	})
+	return buildResult(result), err
+}
+
+// Run starts the server of a built gamemode and waits for it to exit.
+// Cancelling ctx asks the server to shut down, and kills it if it has not
+// after StopTimeout; Run then returns ctx's error.
+func (p *Project) Run(ctx context.Context, opts RunOptions) (*RunResult, error) {
+	if p.Config.ProjectType() != utils.ProjectTypeGamemode {
+		return nil, fmt.Errorf("%s is a %s; only gamemodes can be run", p.Name, p.Config.ProjectType())
+	}
+
+	stdin := opts.Stdin
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+
+	var onEvent func(runner.Event)
+	if opts.OnEvent != nil {
+		onEvent = func(e runner.Event) { opts.OnEvent(runEvent(e)) }
+	}
+	var process runner.ServerProcess
+	if opts.Server != nil {
+		process = processAdapter{opts.Server}
+	}
+
+	result, err := runner.Run(ctx, p.Root, runner.Options{
+		Debug:       opts.Debug,
+		Port:        opts.Port,
+		Stdin:       stdin,
+		Stdout:      writer(opts.Stdout),
+		Stderr:      writer(opts.Stderr),
+		OnEvent:     onEvent,
+		Process:     process,
+		Symbolicate: opts.Symbolicate,
+	})
+	return runResult(result), err
+}
+
+// load resolves a project the way the commands do
+func load(dir, name string) (*Project, error) {
+	ws, members, err := workspace.Resolve(dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) != 1 {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = m.Name
+		}
+		return nil, fmt.Errorf("%s is the root of a workspace; load one of its members (%s)", ws.Root, strings.Join(names, ", "))
+	}
+
+	m := members[0]
+	return &Project{Name: m.Name, Root: m.Root, Config: m.Config, ws: ws, member: m}, nil
+}
+
+// writer returns w, or a writer that discards everything when w is nil
+func writer(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// buildResult converts the result of the builder
+func buildResult(r *builder.BuildResult) *BuildResult {
+	if r == nil {
+		return nil
+	}
+
+	diagnostics := make([]Diagnostic, len(r.Diagnostics))
+	for i, d := range r.Diagnostics {
+		diagnostics[i] = Diagnostic(d)
+	}
+	if r.Diagnostics == nil {
+		diagnostics = nil
+	}
+
+	return &BuildResult{
+		Success:        r.Success,
+		Errors:         r.Errors,
+		Warnings:       r.Warnings,
+		Project:        r.Project,
+		Output:         r.Output,
+		Compiler:       r.Compiler,
+		Diagnostics:    diagnostics,
+		MissingNatives: r.MissingNatives,
+		Skipped:        r.Skipped,
+		Staged:         StageStats(r.Staged),
+		Duration:       r.Duration,
+	}
+}
+
+// buildEvent converts an event of the builder
+func buildEvent(e builder.Event) BuildEvent {
+	switch e := e.(type) {
+	case builder.TargetStarted:
+		return TargetStarted(e)
+	case builder.CompilerInvoked:
+		return CompilerInvoked(e)
+	case builder.DiagnosticReported:
+		return DiagnosticReported{Project: e.Project, Diagnostic: Diagnostic(e.Diagnostic)}
+	case builder.FilesStaged:
+		return FilesStaged{Project: e.Project, Stats: StageStats(e.Stats)}
+	case builder.Notice:
+		return Notice(e)
+	case builder.TargetFinished:
+		return TargetFinished{Project: e.Project, Result: buildResult(e.Result), Err: e.Err}
+	}
+	panic(fmt.Sprintf("unknown build event %T", e))
+}
+
+// runResult converts the result of the runner
+func runResult(r *runner.Result) *RunResult {
+	if r == nil {
+		return nil
+	}
+	result := RunResult(*r)
+	return &result
+}
+
+// runEvent converts an event of the runner
+func runEvent(e runner.Event) RunEvent {
+	switch e := e.(type) {
+	case runner.ServerStarted:
+		return ServerStarted(e)
+	case runner.ServerExited:
+		return ServerExited{Project: e.Project, Result: runResult(e.Result), Err: e.Err}
+	}
+	panic(fmt.Sprintf("unknown run event %T", e))
+}
+
+// compilerAdapter lets the builder use a Compiler
+type compilerAdapter struct {
+	c Compiler
+}
+
+func (a compilerAdapter) Path() string {
+	return a.c.Path()
+}
+
+func (a compilerAdapter) Compile(ctx context.Context, job builder.CompileJob) error {
+	return a.c.Compile(ctx, CompileJob(job))
+}
+
+// processAdapter lets the runner use a ServerProcess
+type processAdapter struct {
+	p ServerProcess
+}
+
+func (a processAdapter) Start(ctx context.Context, cmd runner.ServerCommand) error {
+	return a.p.Start(ctx, ServerCommand(cmd))
+}
+
+func (a processAdapter) PID() int {
+	return a.p.PID()
+}
+
+func (a processAdapter) Wait() (int, error) {
+	return a.p.Wait()
+}
@@ -0,0 +1,154 @@
+package ompcli_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/pkg/ompcli"
+	"github.com/weltschmerzie/omp-cli/pkg/ompcli/ompclitest"
+)
+
+// writeProject writes a gamemode project into a temporary directory
+func writeProject(t *testing.T) string {
+	root := filepath.Join(t.TempDir(), "gm")
+	files := map[string]string{
+		"project.json":     `{"name": "gm", "version": "1.0.0", "main_file": "gamemodes/gm.pwn", "output_file": "gamemodes/gm.amx", "check_natives": "off"}`,
+		"config.json":      `{"hostname": "test", "port": 7777, "maxplayers": 50}`,
+		"gamemodes/gm.pwn": "main() {}\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBuildAndRun(t *testing.T) {
+	t.Setenv("OMPCLI_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	root := writeProject(t)
+
+	project, err := ompcli.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warning := ompcli.Diagnostic{Severity: ompcli.SeverityWarning, File: "gamemodes/gm.pwn", Line: 1, Code: 203, Message: `symbol is never used: "x"`}
+	compiler := &ompclitest.Compiler{Diagnostics: []ompcli.Diagnostic{warning}, Output: []byte("amx")}
+
+	var events []ompcli.BuildEvent
+	result, err := project.Build(context.Background(), ompcli.BuildOptions{
+		Compiler: compiler,
+		OnEvent:  func(e ompcli.BuildEvent) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if !result.Success || result.Compiler != "fake-pawncc" || result.Staged.Copied == 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != 203 {
+		t.Errorf("diagnostics = %+v", result.Diagnostics)
+	}
+	if len(compiler.Jobs()) != 1 {
+		t.Errorf("jobs = %+v", compiler.Jobs())
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("events = %+v", events)
+	}
+	if r, ok := events[2].(ompcli.DiagnosticReported); !ok || r.Diagnostic.Code != 203 {
+		t.Errorf("third event = %+v, want the warning", events[2])
+	}
+	if f, ok := events[4].(ompcli.TargetFinished); !ok || f.Result.Output != result.Output {
+		t.Errorf("last event = %+v, want TargetFinished", events[4])
+	}
+
+	// The fake server only needs an executable to be staged
+	exe := "omp-server"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(root, "build", exe), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &ompclitest.Server{Lifetime: time.Millisecond, ExitCode: 2}
+	var runEvents []string
+	run, err := project.Run(context.Background(), ompcli.RunOptions{
+		Port:    8888,
+		Server:  server,
+		OnEvent: func(e ompcli.RunEvent) { runEvents = append(runEvents, reflect.TypeOf(e).Name()) },
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if run.PID != 4242 || run.Port != 8888 || run.ExitCode != 2 {
+		t.Errorf("run = %+v", run)
+	}
+	if want := []string{"ServerStarted", "ServerExited"}; !reflect.DeepEqual(runEvents, want) {
+		t.Errorf("run events = %v, want %v", runEvents, want)
+	}
+	if cmd := server.Command(); cmd.Dir != filepath.Join(root, "build") {
+		t.Errorf("server ran in %s", cmd.Dir)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := writeProject(t)
+
+	// Like the commands, Load finds the project from a folder inside it
+	project, err := ompcli.Load(filepath.Join(root, "gamemodes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "gm" || project.Root != root || project.Config.MainFile != "gamemodes/gm.pwn" {
+		t.Errorf("project = %+v", project)
+	}
+
+	if _, err := ompcli.LoadMember(root, ""); err == nil {
+		t.Error("LoadMember without a name succeeded")
+	}
+	if _, err := ompcli.Load(t.TempDir()); err == nil {
+		t.Error("Load outside of a project succeeded")
+	}
+}
+
+func TestBuildRejectsTargetOS(t *testing.T) {
+	project, err := ompcli.Load(writeProject(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := project.Build(context.Background(), ompcli.BuildOptions{TargetOS: "darwin"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported target OS") || result != nil {
+		t.Errorf("Build = %+v, %v", result, err)
+	}
+}
+
+func TestRunRejectsLibraries(t *testing.T) {
+	root := writeProject(t)
+	library := `{"name": "lib", "version": "1.0.0", "type": "library", "main_file": "lib.inc", "output_file": "lib.amx"}`
+	if err := os.WriteFile(filepath.Join(root, "project.json"), []byte(library), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := ompcli.Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := project.Run(context.Background(), ompcli.RunOptions{})
+	if err == nil || !strings.Contains(err.Error(), "only gamemodes can be run") || result != nil {
+		t.Errorf("Run = %+v, %v", result, err)
+	}
+}
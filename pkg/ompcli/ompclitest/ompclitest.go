@@ -0,0 +1,168 @@
+// Package ompclitest provides fakes of the compiler and the server, for
+// testing code that builds and runs projects with package ompcli without
+// pawncc or omp-server installed:
+//
+//	compiler := &ompclitest.Compiler{
+//		Diagnostics: []ompcli.Diagnostic{{
+//			Severity: ompcli.SeverityWarning,
+//			File:     "gamemodes/main.pwn",
+//			Line:     3,
+//			Code:     203,
+//			Message:  `symbol is never used: "x"`,
+//		}},
+//	}
+//	result, err := project.Build(ctx, ompcli.BuildOptions{Compiler: compiler})
+package ompclitest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/weltschmerzie/omp-cli/pkg/ompcli"
+)
+
+// Compiler reports canned diagnostics and, unless one is an error, writes
+// its Output as the compiled script. Jobs returns what it was asked to
+// compile.
+type Compiler struct {
+	// Diagnostics are reported by every compilation
+	Diagnostics []ompcli.Diagnostic
+
+	// Output is the compiled script that is written
+	Output []byte
+
+	// Err, when set, fails every compilation
+	Err error
+
+	mu   sync.Mutex
+	jobs []ompcli.CompileJob
+}
+
+// Path returns a name for the fake compiler
+func (c *Compiler) Path() string {
+	return "fake-pawncc"
+}
+
+// Compile records the job and prints the canned diagnostics the way pawncc
+// does
+func (c *Compiler) Compile(ctx context.Context, job ompcli.CompileJob) error {
+	c.mu.Lock()
+	c.jobs = append(c.jobs, job)
+	c.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	failed := false
+	for _, d := range c.Diagnostics {
+		if job.Stdout != nil {
+			fmt.Fprintln(job.Stdout, d.String())
+		}
+		failed = failed || d.Severity == ompcli.SeverityError
+	}
+	if c.Err != nil {
+		return c.Err
+	}
+	if failed {
+		return fmt.Errorf("exit status 1")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(job.Output), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(job.Output, c.Output, 0644)
+}
+
+// Jobs returns the compilations asked for so far
+func (c *Compiler) Jobs() []ompcli.CompileJob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ompcli.CompileJob{}, c.jobs...)
+}
+
+// Server prints canned log lines and runs until its Lifetime ends or its
+// context is cancelled, which shuts it down cleanly. Command returns what
+// it was started with.
+type Server struct {
+	// Log is written to the server's stdout when it starts
+	Log []string
+
+	// Lifetime is how long the server runs before exiting by itself; zero
+	// runs until the context is cancelled
+	Lifetime time.Duration
+
+	// ExitCode and Err are what the server exits with by itself
+	ExitCode int
+	Err      error
+
+	// StartErr, when set, makes starting the server fail
+	StartErr error
+
+	mu      sync.Mutex
+	started bool
+	command ompcli.ServerCommand
+	ctx     context.Context
+}
+
+// Start records the command and prints the log
+func (s *Server) Start(ctx context.Context, cmd ompcli.ServerCommand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("server already started")
+	}
+	if s.StartErr != nil {
+		return s.StartErr
+	}
+	s.started, s.command, s.ctx = true, cmd, ctx
+
+	for _, line := range s.Log {
+		if cmd.Stdout != nil {
+			fmt.Fprintln(cmd.Stdout, line)
+		}
+	}
+	return nil
+}
+
+// PID returns a made-up process id
+func (s *Server) PID() int {
+	return 4242
+}
+
+// Wait waits for the server's lifetime to end or its context to be
+// cancelled
+func (s *Server) Wait() (int, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		return -1, fmt.Errorf("server not started")
+	}
+
+	var timeout <-chan time.Time
+	if s.Lifetime > 0 {
+		timer := time.NewTimer(s.Lifetime)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-timeout:
+		return s.ExitCode, s.Err
+	case <-ctx.Done():
+		return 0, nil
+	}
+}
+
+// Command returns the command the server was started with
+func (s *Server) Command() ompcli.ServerCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.command
+}
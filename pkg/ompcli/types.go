@@ -0,0 +1,246 @@
+package ompcli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Severities of a Diagnostic
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// StopTimeout is how long a server gets to shut down when the context of
+// Run is cancelled before it is killed
+const StopTimeout = 10 * time.Second
+
+// BuildResult describes a build
+type BuildResult struct {
+	Success  bool
+	Errors   []string
+	Warnings []string
+
+	// Project is the name of the project that was built
+	Project string
+
+	// Output is the compiled script in the build directory; empty when
+	// nothing was compiled
+	Output string
+
+	// Compiler is the pawncc executable that was used
+	Compiler string
+
+	// Diagnostics are the errors and warnings pawncc reported, in order
+	Diagnostics []Diagnostic
+
+	// MissingNatives are the natives the script calls that neither
+	// open.mp nor a configured plugin provides
+	MissingNatives []string
+
+	// Skipped is set for libraries without a main file, which have
+	// nothing to compile
+	Skipped bool
+
+	// Staged counts what was staged into the build directory
+	Staged StageStats
+
+	// Duration is how long the build took
+	Duration time.Duration
+}
+
+// StageStats counts the files of a build directory that were copied,
+// linked, left as they were because they were up to date, and removed
+// because the build no longer stages them
+type StageStats struct {
+	Copied  int
+	Linked  int
+	Skipped int
+	Pruned  int
+}
+
+// Diagnostic is an error or warning reported by pawncc. File, Line and
+// Code are zero when the line could not be parsed further.
+type Diagnostic struct {
+	Severity string
+	File     string
+	Line     int
+	EndLine  int
+	Code     int
+	Message  string
+
+	// Raw is the line as pawncc printed it
+	Raw string
+}
+
+// String returns the line pawncc printed, or formats d the way pawncc
+// does when it was not parsed from output
+func (d Diagnostic) String() string {
+	if d.Raw != "" {
+		return d.Raw
+	}
+	if d.File == "" {
+		return d.Message
+	}
+
+	lines := strconv.Itoa(d.Line)
+	if d.EndLine > d.Line {
+		lines += " -- " + strconv.Itoa(d.EndLine)
+	}
+	return fmt.Sprintf("%s(%s) : %s %03d: %s", d.File, lines, d.Severity, d.Code, d.Message)
+}
+
+// BuildEvent is one of TargetStarted, CompilerInvoked, DiagnosticReported,
+// FilesStaged, Notice or TargetFinished. Events of one build are delivered
+// one at a time, in the order they happened.
+type BuildEvent interface {
+	buildEvent()
+}
+
+// TargetStarted is sent when the build of a project starts
+type TargetStarted struct {
+	Project string
+	Root    string
+}
+
+// CompilerInvoked is sent when the compiler starts on the main script
+type CompilerInvoked struct {
+	Project  string
+	Compiler string
+	Source   string
+	Output   string
+}
+
+// DiagnosticReported is sent for every error or warning as soon as the
+// compiler prints it
+type DiagnosticReported struct {
+	Project    string
+	Diagnostic Diagnostic
+}
+
+// FilesStaged is sent once the build directory is up to date
+type FilesStaged struct {
+	Project string
+	Stats   StageStats
+}
+
+// Notice is sent for a message the user should see, such as a file that
+// could not be staged
+type Notice struct {
+	Project string
+	Message string
+}
+
+// TargetFinished is sent when the build of a project ends. Err is set when
+// it failed.
+type TargetFinished struct {
+	Project string
+	Result  *BuildResult
+	Err     error
+}
+
+func (TargetStarted) buildEvent()      {}
+func (CompilerInvoked) buildEvent()    {}
+func (DiagnosticReported) buildEvent() {}
+func (FilesStaged) buildEvent()        {}
+func (Notice) buildEvent()             {}
+func (TargetFinished) buildEvent()     {}
+
+// RunResult describes a server that ran
+type RunResult struct {
+	// Project is the name of the project that was run
+	Project string
+
+	// PID is the process id of the server and Port the port it listened on
+	PID  int
+	Port int
+
+	// ExitCode is the exit code of the server, -1 when it was killed by a
+	// signal or never started
+	ExitCode int
+
+	// Duration is how long the server ran
+	Duration time.Duration
+}
+
+// RunEvent is one of ServerStarted or ServerExited
+type RunEvent interface {
+	runEvent()
+}
+
+// ServerStarted is sent once the server process is running
+type ServerStarted struct {
+	Project string
+	PID     int
+	Port    int
+}
+
+// ServerExited is sent when the server process has ended. Err is set when
+// it did not exit cleanly.
+type ServerExited struct {
+	Project string
+	Result  *RunResult
+	Err     error
+}
+
+func (ServerStarted) runEvent() {}
+func (ServerExited) runEvent()  {}
+
+// Compiler compiles the main script of a project; see ompclitest for a
+// fake
+type Compiler interface {
+	// Path identifies the compiler, such as the pawncc executable
+	Path() string
+
+	// Compile compiles job.Source into job.Output, writing the compiler's
+	// messages to job.Stdout and job.Stderr. It fails when the compiler
+	// does. Diagnostics printed the way pawncc prints them are reported.
+	Compile(ctx context.Context, job CompileJob) error
+}
+
+// CompileJob is one compilation
+type CompileJob struct {
+	// Dir is the project root, which relative paths resolve against
+	Dir string
+
+	// Source is the main script, relative to Dir, and Output the absolute
+	// path of the compiled script
+	Source string
+	Output string
+
+	// IncludePaths are searched for includes, in order
+	IncludePaths []string
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ServerProcess is the server of a project; see ompclitest for a fake.
+// Each one is started once.
+type ServerProcess interface {
+	// Start starts the server; cancelling ctx asks it to shut down
+	Start(ctx context.Context, cmd ServerCommand) error
+
+	// PID returns the process id of the started server
+	PID() int
+
+	// Wait waits for the server to exit and returns its exit code, -1
+	// when it was killed
+	Wait() (int, error)
+}
+
+// ServerCommand describes the server process to start
+type ServerCommand struct {
+	// Path is the server executable, Args its arguments and Dir the build
+	// directory it runs in
+	Path string
+	Args []string
+	Dir  string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
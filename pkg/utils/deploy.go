@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultKeepReleases is how many releases a deploy target keeps when
+// keep is not set
+const DefaultKeepReleases = 5
+
+// DeployTarget is a place the project is deployed to, from the deploy
+// section of project.json
+type DeployTarget struct {
+	// Path is the deploy directory; releases go to <path>/releases and
+	// <path>/current points at the live one
+	Path string `json:"path"`
+
+	// Host is the SSH destination ([user@]host); empty deploys locally
+	Host string `json:"host,omitempty"`
+
+	// Port and Identity are passed to ssh when set
+	Port     int    `json:"port,omitempty"`
+	Identity string `json:"identity,omitempty"`
+
+	// Keep is the number of releases kept for rollback, the live one
+	// included; zero means DefaultKeepReleases
+	Keep int `json:"keep,omitempty"`
+
+	// Profile and TargetOS are used to build the package; the profile
+	// defaults to production
+	Profile  string `json:"profile,omitempty"`
+	TargetOS string `json:"target_os,omitempty"`
+
+	// Shared paths, such as scriptfiles, live in <path>/shared and are
+	// linked into every release so they survive deploys
+	Shared []string `json:"shared,omitempty"`
+
+	// PreDeploy runs in the deploy directory before uploading; PostDeploy
+	// runs in the new release after switching to it, and after a rollback.
+	// Use them to stop and restart the server.
+	PreDeploy  []string `json:"pre_deploy,omitempty"`
+	PostDeploy []string `json:"post_deploy,omitempty"`
+}
+
+// IsRemote reports whether the target is reached over SSH
+func (t *DeployTarget) IsRemote() bool {
+	return t.Host != ""
+}
+
+// KeepReleases returns the number of releases to keep
+func (t *DeployTarget) KeepReleases() int {
+	if t.Keep <= 0 {
+		return DefaultKeepReleases
+	}
+	return t.Keep
+}
+
+// String describes where the target is
+func (t *DeployTarget) String() string {
+	if t.IsRemote() {
+		return t.Host + ":" + t.Path
+	}
+	return t.Path
+}
+
+// Validate checks a deploy target for invalid values
+func (t *DeployTarget) Validate(name string) error {
+	if t.Path == "" {
+		return fmt.Errorf("deploy target %q: path must not be empty", name)
+	}
+	if t.IsRemote() && !path.IsAbs(t.Path) {
+		return fmt.Errorf("deploy target %q: path must be absolute on a remote host", name)
+	}
+	if t.Port < 0 || t.Port > 65535 {
+		return fmt.Errorf("deploy target %q: port must be between 1 and 65535", name)
+	}
+	if t.Keep < 0 {
+		return fmt.Errorf("deploy target %q: keep must not be negative", name)
+	}
+	switch t.TargetOS {
+	case "", "linux", "windows":
+	default:
+		return fmt.Errorf("deploy target %q: target_os must be linux or windows", name)
+	}
+	for _, shared := range t.Shared {
+		clean := path.Clean(filepath.ToSlash(shared))
+		if shared == "" || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("deploy target %q: shared path %q must stay inside the release", name, shared)
+		}
+	}
+	return nil
+}
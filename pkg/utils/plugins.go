@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// Plugin kinds
+const (
+	// PluginKindLegacy is a SA-MP style plugin loaded from plugins/
+	PluginKindLegacy = "plugin"
+
+	// PluginKindComponent is an open.mp component loaded from components/
+	PluginKindComponent = "component"
+)
+
+// Plugin is an entry of the plugins list in project.json. It is written
+// either as a string, naming a plugin file ("plugins/streamer.so") or a
+// plugin by logical name ("streamer"), or as an object that lists the file
+// for each operating system:
+//
+//	{"name": "mysql", "files": {"linux": "plugins/mysql.so", "windows": "plugins/mysql.dll"}}
+type Plugin struct {
+	Name  string            `json:"name"`
+	Kind  string            `json:"kind,omitempty"`
+	Files map[string]string `json:"files,omitempty"`
+
+	// Includes lists the include files declaring the plugin's natives,
+	// when they are not named <name>.inc or a_<name>.inc
+	Includes []string `json:"includes,omitempty"`
+
+	// path is the file given in the string form, if any
+	path string
+}
+
+// UnmarshalJSON accepts both the string and the object form
+func (p *Plugin) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = Plugin{Name: s}
+		if strings.ContainsAny(s, `/\`) || isPluginExt(filepath.Ext(s)) {
+			p.path = s
+			p.Name = strings.TrimSuffix(filepath.Base(s), filepath.Ext(s))
+		}
+		return nil
+	}
+
+	// Decode through an alias to avoid recursing into this method
+	type plain Plugin
+	var obj plain
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("plugin must be a string or an object: %w", err)
+	}
+	*p = Plugin(obj)
+	return nil
+}
+
+// MarshalJSON writes plugins declared as strings back as strings
+func (p Plugin) MarshalJSON() ([]byte, error) {
+	if p.Kind == "" && p.Files == nil && p.Includes == nil {
+		return json.Marshal(p.String())
+	}
+	type plain Plugin
+	return json.Marshal(plain(p))
+}
+
+// String returns the plugin as it would be written in the string form
+func (p Plugin) String() string {
+	if p.path != "" {
+		return p.path
+	}
+	return p.Name
+}
+
+// Validate checks a plugin entry for invalid values
+func (p Plugin) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("plugin name must not be empty")
+	}
+	switch p.Kind {
+	case "", PluginKindLegacy, PluginKindComponent:
+	default:
+		return fmt.Errorf("plugin %s: kind must be %q or %q", p.Name, PluginKindLegacy, PluginKindComponent)
+	}
+	return nil
+}
+
+// Resolve returns the file a plugin provides for the operating system goos,
+// relative to root, and whether it is a legacy plugin or a component.
+// Plugins given by name are looked up as <name>.so or <name>.dll in
+// plugins/ and components/ and their per-OS subfolders; plugins given as a
+// file built for another OS are looked up with the extension of goos
+// instead, so a binary for the wrong OS is never staged.
+func (p Plugin) Resolve(root, goos string) (string, string, error) {
+	return p.ResolveFS(vfs.OS, root, goos)
+}
+
+// ResolveFS is Resolve for a project in fsys
+func (p Plugin) ResolveFS(fsys vfs.FS, root, goos string) (string, string, error) {
+	var candidates []string
+	switch {
+	case p.Files != nil:
+		file, ok := p.Files[goos]
+		if !ok {
+			return "", "", fmt.Errorf("plugin %s has no file for %s", p.Name, goos)
+		}
+		candidates = []string{file}
+	case p.path != "":
+		ext := filepath.Ext(p.path)
+		if isPluginExt(ext) && !strings.EqualFold(ext, PluginExt(goos)) {
+			candidates = []string{strings.TrimSuffix(p.path, ext) + PluginExt(goos)}
+		} else {
+			candidates = []string{p.path}
+		}
+	default:
+		for _, dir := range []string{"plugins", "components", "plugins/" + goos, "components/" + goos} {
+			candidates = append(candidates, dir+"/"+p.Name+PluginExt(goos))
+		}
+	}
+
+	for _, file := range candidates {
+		if vfs.Exists(fsys, filepath.Join(root, filepath.FromSlash(file))) {
+			return file, p.kindOf(file), nil
+		}
+	}
+	return "", "", fmt.Errorf("plugin %s: no file for %s (looked for %s)", p.Name, goos, strings.Join(candidates, ", "))
+}
+
+// kindOf returns the declared kind, or infers it from where file lives
+func (p Plugin) kindOf(file string) string {
+	if p.Kind != "" {
+		return p.Kind
+	}
+	for _, part := range strings.Split(filepath.ToSlash(file), "/") {
+		if part == "components" {
+			return PluginKindComponent
+		}
+	}
+	return PluginKindLegacy
+}
+
+// PluginExt returns the extension of plugins built for goos
+func PluginExt(goos string) string {
+	if goos == "windows" {
+		return ".dll"
+	}
+	return ".so"
+}
+
+// isPluginExt reports whether ext is the extension of a plugin binary
+func isPluginExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".so", ".dll", ".dylib":
+		return true
+	}
+	return false
+}
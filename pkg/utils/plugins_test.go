@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPluginResolve(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"plugins/crashdetect.so", "plugins/crashdetect.dll", "plugins/mysql.dll", "components/linux/x.so"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		plugin string
+		goos   string
+		file   string
+		kind   string
+		err    string
+	}{
+		{plugin: `"plugins/crashdetect.dll"`, goos: "linux", file: "plugins/crashdetect.so", kind: PluginKindLegacy},
+		{plugin: `"plugins/crashdetect.so"`, goos: "windows", file: "plugins/crashdetect.dll", kind: PluginKindLegacy},
+		{plugin: `"plugins/mysql.dll"`, goos: "windows", file: "plugins/mysql.dll", kind: PluginKindLegacy},
+		{plugin: `"plugins/mysql.dll"`, goos: "linux", err: "plugin mysql: no file for linux (looked for plugins/mysql.so)"},
+		{plugin: `"x"`, goos: "linux", file: "components/linux/x.so", kind: PluginKindComponent},
+		{plugin: `{"name": "mysql", "files": {"windows": "plugins/mysql.dll"}}`, goos: "linux", err: "plugin mysql has no file for linux"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plugin+" "+tt.goos, func(t *testing.T) {
+			var plugin Plugin
+			if err := json.Unmarshal([]byte(tt.plugin), &plugin); err != nil {
+				t.Fatal(err)
+			}
+			file, kind, err := plugin.Resolve(root, tt.goos)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Resolve() = %s, %v, want error %q", file, err, tt.err)
+				}
+				return
+			}
+			if err != nil || file != tt.file || kind != tt.kind {
+				t.Errorf("Resolve() = %s, %s, %v, want %s, %s", file, kind, err, tt.file, tt.kind)
+			}
+		})
+	}
+}
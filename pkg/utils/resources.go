@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// ResourceFile is a single file to stage: Src is relative to the project
+// root and Dest to the build directory, both with forward slashes
+type ResourceFile struct {
+	Src  string
+	Dest string
+}
+
+// reservedDests are staged by ompcli itself and cannot be resources
+var reservedDests = map[string]bool{
+	"config.json":    true,
+	"omp-server":     true,
+	"omp-server.exe": true,
+}
+
+// skipDirs are never searched for resources
+var skipDirs = map[string]bool{
+	"build":   true,
+	".ompcli": true,
+	".git":    true,
+	"dist":    true,
+}
+
+// ResolveResources expands the resources list of project.json into the
+// files to stage. Entries are one of:
+//
+//	maps/map1.json                    a file, staged at the same path
+//	scriptfiles                       a directory, copied with its structure
+//	scriptfiles/**/*.ini              a glob; ** matches any number of folders
+//	assets/logo.png -> textures/x.png a file, directory or glob staged elsewhere
+//	!scriptfiles/**/*.bak             excludes matching files from all entries
+//
+// Two files staged at the same destination are reported as an error.
+func ResolveResources(root string, resources []string) ([]ResourceFile, error) {
+	return ResolveResourcesFS(vfs.OS, root, resources)
+}
+
+// ResolveResourcesFS is ResolveResources for a project in fsys
+func ResolveResourcesFS(fsys vfs.FS, root string, resources []string) ([]ResourceFile, error) {
+	var excludes []string
+	for _, entry := range resources {
+		if pattern, ok := strings.CutPrefix(strings.TrimSpace(entry), "!"); ok {
+			excludes = append(excludes, cleanPattern(pattern))
+		}
+	}
+
+	var files []ResourceFile
+	owner := map[string]string{}  // dest -> entry that staged it
+	source := map[string]string{} // dest -> file staged there
+	for _, entry := range resources {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "!") {
+			continue
+		}
+
+		src, dest, mapped := strings.Cut(entry, "->")
+		src = cleanPattern(strings.TrimSpace(src))
+		dest = strings.TrimSpace(dest)
+		if mapped {
+			if dest == "" {
+				return nil, fmt.Errorf("resource %q: missing destination after ->", entry)
+			}
+			dest = path.Clean(filepath.ToSlash(dest))
+			if path.IsAbs(dest) || dest == ".." || strings.HasPrefix(dest, "../") {
+				return nil, fmt.Errorf("resource %q: destination must stay inside the build directory", entry)
+			}
+		}
+
+		matches, err := expandResource(fsys, root, src, dest, mapped)
+		if err != nil {
+			return nil, fmt.Errorf("resource %q: %w", entry, err)
+		}
+
+		for _, file := range matches {
+			if excluded(file.Src, excludes) {
+				continue
+			}
+			if reservedDests[file.Dest] {
+				return nil, fmt.Errorf("resource %q: %s is staged by ompcli and cannot be a resource", entry, file.Dest)
+			}
+			if prev, ok := owner[file.Dest]; ok {
+				// The same file listed twice is not a collision
+				if source[file.Dest] == file.Src {
+					continue
+				}
+				return nil, fmt.Errorf("resources %q and %q both stage %s", prev, entry, file.Dest)
+			}
+			owner[file.Dest] = entry
+			source[file.Dest] = file.Src
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// expandResource returns the files one resource entry stages
+func expandResource(fsys vfs.FS, root, src, dest string, mapped bool) ([]ResourceFile, error) {
+	if !hasMeta(src) {
+		info, err := fsys.Stat(filepath.Join(root, filepath.FromSlash(src)))
+		if err != nil {
+			return nil, fmt.Errorf("%s does not exist", src)
+		}
+		if !mapped {
+			dest = src
+		}
+		if !info.IsDir() {
+			return []ResourceFile{{Src: src, Dest: dest}}, nil
+		}
+
+		// Directories are copied with their structure
+		return walkResources(fsys, root, src, dest, func(string) bool { return true })
+	}
+
+	// Globs keep the structure below their last folder without wildcards
+	base := globBase(src)
+	if !mapped {
+		dest = base
+	}
+	return walkResources(fsys, root, base, dest, func(rel string) bool {
+		return MatchGlob(src, rel)
+	})
+}
+
+// walkResources stages the files below base that match, keeping their
+// path relative to base under dest. A missing base is an error, like a
+// missing file.
+func walkResources(fsys vfs.FS, root, base, dest string, match func(rel string) bool) ([]ResourceFile, error) {
+	var files []ResourceFile
+	dir := filepath.Join(root, filepath.FromSlash(base))
+	if _, err := fsys.Stat(dir); err != nil {
+		return nil, fmt.Errorf("%s does not exist", base)
+	}
+
+	err := vfs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			// Globs from the project root must not pick up ompcli's own output
+			if skipDirs[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !match(rel) {
+			return nil
+		}
+
+		inner, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, ResourceFile{Src: rel, Dest: path.Join(dest, filepath.ToSlash(inner))})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Src < files[j].Src })
+	return files, err
+}
+
+// MatchGlob reports whether a slash-separated path matches pattern. Besides
+// the wildcards of path.Match, a "**" segment matches any number of
+// folders, including none.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// excluded reports whether src matches one of the exclude patterns. A
+// pattern without wildcards excludes that file or everything below it.
+func excluded(src string, excludes []string) bool {
+	for _, pattern := range excludes {
+		if MatchGlob(pattern, src) || (!hasMeta(pattern) && strings.HasPrefix(src, pattern+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// globBase returns the folders of pattern before the first wildcard
+func globBase(pattern string) string {
+	var base []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if hasMeta(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+	return path.Join(base...)
+}
+
+// hasMeta reports whether s contains glob wildcards
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// cleanPattern normalizes a resource path to forward slashes
+func cleanPattern(p string) string {
+	return path.Clean(filepath.ToSlash(strings.TrimSpace(p)))
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveResourcesMissing(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "scriptfiles"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entry string
+		err   string
+	}{
+		{entry: "maps/map1.json", err: `resource "maps/map1.json": maps/map1.json does not exist`},
+		{entry: "maps", err: `resource "maps": maps does not exist`},
+		{entry: "maps/**/*.json", err: `resource "maps/**/*.json": maps does not exist`},
+		{entry: "maps/*.json -> x", err: `resource "maps/*.json -> x": maps does not exist`},
+		{entry: "scriptfiles/**/*.ini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			files, err := ResolveResources(root, []string{tt.entry})
+			switch {
+			case tt.err == "" && (err != nil || len(files) != 0):
+				t.Errorf("ResolveResources() = %v, %v, want nothing", files, err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Errorf("ResolveResources() error = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
@@ -2,24 +2,88 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/weltschmerzie/omp-cli/internal/jsonedit"
+	"github.com/weltschmerzie/omp-cli/internal/natives"
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+	"github.com/weltschmerzie/omp-cli/internal/vfs"
+)
+
+// Project types
+const (
+	ProjectTypeGamemode     = "gamemode"
+	ProjectTypeFilterscript = "filterscript"
+	ProjectTypeLibrary      = "library"
 )
 
 // ProjectConfig represents the configuration of an open.mp project
 type ProjectConfig struct {
-	Name       string   `json:"name"`
-	Version    string   `json:"version"`
-	MainFile   string   `json:"main_file"`
-	OutputFile string   `json:"output_file"`
-	Resources  []string `json:"resources"`
-	Plugins    []string `json:"plugins"`
-	ServerCfg  string   `json:"server_cfg"`
-	Author     string   `json:"author"`
-	Repository string   `json:"repository"`
-	PawnccPath string   `json:"pawncc_path"`
+	Name          string   `json:"name"`
+	Version       string   `json:"version"`
+	Type          string   `json:"type,omitempty"`
+	MainFile      string   `json:"main_file"`
+	OutputFile    string   `json:"output_file"`
+	IncludePaths  []string `json:"include_paths,omitempty"`
+	Libraries     []string `json:"libraries,omitempty"`
+	Resources     []string `json:"resources"`
+	Plugins       []Plugin `json:"plugins"`
+	ServerCfg     string   `json:"server_cfg"`
+	Author        string   `json:"author"`
+	Repository    string   `json:"repository"`
+	PawnccPath    string   `json:"pawncc_path"`
+	PawnccVersion string   `json:"pawncc_version,omitempty"`
+	ServerVersion string   `json:"server_version,omitempty"`
+
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Registry     string            `json:"registry,omitempty"`
+
+	Staging *StagingConfig `json:"staging,omitempty"`
+
+	// CheckNatives is what a build does when the script calls natives
+	// that neither open.mp nor a plugin provides: "warn" (the default),
+	// "error" or "off"
+	CheckNatives string `json:"check_natives,omitempty"`
+
+	Deploy map[string]*DeployTarget `json:"deploy,omitempty"`
+}
+
+// StagingConfig controls how files are staged into build/
+type StagingConfig struct {
+	// Link is "copy" (the default), "hardlink" or "reflink"
+	Link string `json:"link,omitempty"`
+
+	// LinkThreshold is the size in bytes from which files are linked
+	LinkThreshold int64 `json:"link_threshold,omitempty"`
+}
+
+// StagerOptions returns the stager options the configuration asks for
+func (c *ProjectConfig) StagerOptions() stage.Options {
+	if c.Staging == nil {
+		return stage.Options{}
+	}
+	return stage.Options{Link: c.Staging.Link, LinkThreshold: c.Staging.LinkThreshold}
+}
+
+// NativeCheck returns the check_natives mode, defaulting to a warning
+func (c *ProjectConfig) NativeCheck() string {
+	if c.CheckNatives == "" {
+		return natives.CheckWarn
+	}
+	return c.CheckNatives
+}
+
+// ProjectType returns the type of the project, defaulting to a gamemode
+func (c *ProjectConfig) ProjectType() string {
+	if c.Type == "" {
+		return ProjectTypeGamemode
+	}
+	return c.Type
 }
 
 // ServerConfig represents the configuration of an open.mp server
@@ -35,28 +99,141 @@ type ServerConfig struct {
 	Password     string   `json:"password"`
 }
 
-// IsOpenMPProject checks if the current directory is an open.mp project
-func IsOpenMPProject() bool {
+// Validate checks the project configuration for invalid values
+func (c *ProjectConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+
+	for _, plugin := range c.Plugins {
+		if err := plugin.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Staging != nil {
+		if err := stage.Validate(c.Staging.Link); err != nil {
+			return fmt.Errorf("staging: %w", err)
+		}
+	}
+	if err := natives.Validate(c.CheckNatives); err != nil {
+		return err
+	}
+	for name, target := range c.Deploy {
+		if target == nil {
+			return fmt.Errorf("deploy target %q must be an object", name)
+		}
+		if err := target.Validate(name); err != nil {
+			return err
+		}
+	}
+
+	switch c.ProjectType() {
+	case ProjectTypeGamemode, ProjectTypeFilterscript:
+	case ProjectTypeLibrary:
+		// Libraries only provide includes and need not compile anything
+		if c.MainFile == "" {
+			return nil
+		}
+	default:
+		return fmt.Errorf("type must be one of %q, %q or %q", ProjectTypeGamemode, ProjectTypeFilterscript, ProjectTypeLibrary)
+	}
+
+	if c.MainFile == "" {
+		return fmt.Errorf("main_file must not be empty")
+	}
+	if c.OutputFile == "" {
+		return fmt.Errorf("output_file must not be empty")
+	}
+	if filepath.Ext(c.OutputFile) != ".amx" {
+		return fmt.Errorf("output_file must have the .amx extension")
+	}
+	return nil
+}
+
+// Validate checks the server configuration for invalid values
+func (c *ServerConfig) Validate() error {
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if c.MaxPlayers < 1 || c.MaxPlayers > 1000 {
+		return fmt.Errorf("maxplayers must be between 1 and 1000")
+	}
+	return nil
+}
+
+// ProjectFile is the name of the file that marks the root of a project
+const ProjectFile = "project.json"
+
+// FindProjectRoot walks up from dir to the nearest directory containing
+// project.json, the way git looks for .git. If no project.json is found,
+// dir itself is returned when it looks like a project without one.
+// An empty dir means the current working directory.
+func FindProjectRoot(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if info, err := os.Stat(start); err != nil {
+		return "", fmt.Errorf("failed to access %s: %w", dir, err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	for current := start; ; {
+		if _, err := os.Stat(filepath.Join(current, ProjectFile)); err == nil {
+			return current, nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	// Projects without project.json are only recognised in place
+	if IsOpenMPProject(start) {
+		return start, nil
+	}
+
+	return "", fmt.Errorf("%s is %w (no %s found)", start, ErrNoProject, ProjectFile)
+}
+
+// ErrNoProject is returned when a directory is not inside a project
+var ErrNoProject = errors.New("not inside an open.mp project")
+
+// IsOpenMPProject checks if the given directory is an open.mp project
+func IsOpenMPProject(root string) bool {
+	return IsOpenMPProjectFS(vfs.OS, root)
+}
+
+// IsOpenMPProjectFS checks if the given directory of fsys is an open.mp
+// project
+func IsOpenMPProjectFS(fsys vfs.FS, root string) bool {
 	// Check for project.json file
-	if _, err := os.Stat("project.json"); !os.IsNotExist(err) {
+	if _, err := fsys.Stat(filepath.Join(root, ProjectFile)); !os.IsNotExist(err) {
 		return true
 	}
 
 	// Check for config.json file
-	if _, err := os.Stat("config.json"); !os.IsNotExist(err) {
+	if _, err := fsys.Stat(filepath.Join(root, "config.json")); !os.IsNotExist(err) {
 		return true
 	}
 
 	// Check for pawn scripts in gamemodes directory
-	if _, err := os.Stat("gamemodes"); !os.IsNotExist(err) {
-		matches, err := filepath.Glob(filepath.Join("gamemodes", "*.pwn"))
+	if _, err := fsys.Stat(filepath.Join(root, "gamemodes")); !os.IsNotExist(err) {
+		matches, err := vfs.Glob(fsys, filepath.Join(root, "gamemodes", "*.pwn"))
 		if err == nil && len(matches) > 0 {
 			return true
 		}
 	}
 
 	// Check for pawn scripts in root directory (legacy support)
-	matches, err := filepath.Glob("*.pwn")
+	matches, err := vfs.Glob(fsys, filepath.Join(root, "*.pwn"))
 	if err == nil && len(matches) > 0 {
 		return true
 	}
@@ -64,11 +241,19 @@ func IsOpenMPProject() bool {
 	return false
 }
 
-// GetProjectConfig reads and parses the project configuration
-func GetProjectConfig() (*ProjectConfig, error) {
+// GetProjectConfig reads and parses the project configuration of the
+// project rooted at root. Paths in the configuration are relative to root.
+func GetProjectConfig(root string) (*ProjectConfig, error) {
+	return GetProjectConfigFS(vfs.OS, root)
+}
+
+// GetProjectConfigFS reads and parses the project configuration of the
+// project rooted at root in fsys
+func GetProjectConfigFS(fsys vfs.FS, root string) (*ProjectConfig, error) {
 	// Try to read project.json first
-	if _, err := os.Stat("project.json"); !os.IsNotExist(err) {
-		data, err := os.ReadFile("project.json")
+	projectFile := filepath.Join(root, ProjectFile)
+	if _, err := fsys.Stat(projectFile); !os.IsNotExist(err) {
+		data, err := fsys.ReadFile(projectFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read project.json: %w", err)
 		}
@@ -88,16 +273,16 @@ func GetProjectConfig() (*ProjectConfig, error) {
 		MainFile:   filepath.Join("gamemodes", "gamemode.pwn"),
 		OutputFile: filepath.Join("gamemodes", "gamemode.amx"),
 		Resources:  []string{},
-		Plugins:    []string{},
+		Plugins:    []Plugin{},
 		ServerCfg:  "config.json", // Updated to config.json
 		PawnccPath: "qawno",       // Default pawncc path
 	}
 
 	// Check for main script file in gamemodes directory
-	if _, err := os.Stat("gamemodes"); !os.IsNotExist(err) {
-		matches, err := filepath.Glob(filepath.Join("gamemodes", "*.pwn"))
+	if _, err := fsys.Stat(filepath.Join(root, "gamemodes")); !os.IsNotExist(err) {
+		matches, err := vfs.Glob(fsys, filepath.Join(root, "gamemodes", "*.pwn"))
 		if err == nil && len(matches) > 0 {
-			config.MainFile = matches[0]
+			config.MainFile = filepath.Join("gamemodes", filepath.Base(matches[0]))
 			baseName := filepath.Base(matches[0])
 			nameWithoutExt := baseName[:len(baseName)-4] // Remove .pwn extension
 			config.Name = nameWithoutExt
@@ -105,9 +290,9 @@ func GetProjectConfig() (*ProjectConfig, error) {
 		}
 	} else {
 		// Legacy support: Check for main script file in root directory
-		matches, err := filepath.Glob("*.pwn")
+		matches, err := vfs.Glob(fsys, filepath.Join(root, "*.pwn"))
 		if err == nil && len(matches) > 0 {
-			config.MainFile = matches[0]
+			config.MainFile = filepath.Base(matches[0])
 			baseName := filepath.Base(matches[0])
 			nameWithoutExt := baseName[:len(baseName)-4] // Remove .pwn extension
 			config.Name = nameWithoutExt
@@ -118,11 +303,19 @@ func GetProjectConfig() (*ProjectConfig, error) {
 	return config, nil
 }
 
-// GetServerConfig reads and parses the server configuration
-func GetServerConfig() (*ServerConfig, error) {
+// GetServerConfig reads and parses the server configuration of the
+// project rooted at root
+func GetServerConfig(root string) (*ServerConfig, error) {
+	return GetServerConfigFS(vfs.OS, root)
+}
+
+// GetServerConfigFS reads and parses the server configuration of the
+// project rooted at root in fsys
+func GetServerConfigFS(fsys vfs.FS, root string) (*ServerConfig, error) {
 	// Try to read config.json
-	if _, err := os.Stat("config.json"); !os.IsNotExist(err) {
-		data, err := os.ReadFile("config.json")
+	serverFile := filepath.Join(root, "config.json")
+	if _, err := fsys.Stat(serverFile); !os.IsNotExist(err) {
+		data, err := fsys.ReadFile(serverFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read config.json: %w", err)
 		}
@@ -147,78 +340,161 @@ func GetServerConfig() (*ServerConfig, error) {
 	}, nil
 }
 
-// CopyRequiredFiles copies necessary files to the build directory
-func CopyRequiredFiles(buildDir string) error {
+// StageOptions controls how CopyRequiredFiles stages a project
+type StageOptions struct {
+	// TargetOS selects the plugin files to stage; empty means this system
+	TargetOS string
+
+	// Plugins and Components are extra files, given as absolute paths,
+	// copied along with those listed in project.json
+	Plugins    []string
+	Components []string
+
+	// Profile picks config.<profile>.json over config.json as the staged
+	// server configuration when that file exists
+	Profile string
+
+	// FS holds the project; nil means the real file system. The stager
+	// should use the same one.
+	FS vfs.FS
+}
+
+// CopyRequiredFiles stages the files of the project rooted at root into
+// the stager's build directory. Plugins are resolved for the target OS;
+// legacy plugins go to plugins/ and are listed in the staged config.json,
+// open.mp components go to components/.
+func CopyRequiredFiles(root string, st *stage.Stager, opts StageOptions) error {
+	targetOS := opts.TargetOS
+	if targetOS == "" {
+		targetOS = runtime.GOOS
+	}
+	fsys := vfs.Or(opts.FS)
+
 	// Get project configuration
-	config, err := GetProjectConfig()
+	config, err := GetProjectConfigFS(fsys, root)
 	if err != nil {
 		return err
 	}
 
-	// Copy config.json
-	if _, err := os.Stat("config.json"); !os.IsNotExist(err) {
-		if err := copyFile("config.json", filepath.Join(buildDir, "config.json")); err != nil {
-			return fmt.Errorf("failed to copy config.json: %w", err)
-		}
-	} else if _, err := os.Stat(config.ServerCfg); !os.IsNotExist(err) {
-		// For backward compatibility, also check for server.cfg
-		if err := copyFile(config.ServerCfg, filepath.Join(buildDir, "config.json")); err != nil {
-			return fmt.Errorf("failed to copy server configuration: %w", err)
-		}
-	}
-
 	// Create gamemodes directory in build directory
-	gamemodesDir := filepath.Join(buildDir, "gamemodes")
-	if err := os.MkdirAll(gamemodesDir, 0755); err != nil {
+	gamemodesDir := filepath.Join(st.Dir, "gamemodes")
+	if err := fsys.MkdirAll(gamemodesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create gamemodes directory: %w", err)
 	}
 
-	// Copy resources
-	for _, resource := range config.Resources {
-		if _, err := os.Stat(resource); !os.IsNotExist(err) {
-			destPath := filepath.Join(buildDir, filepath.Base(resource))
-			if err := copyFile(resource, destPath); err != nil {
-				return fmt.Errorf("failed to copy resource %s: %w", resource, err)
-			}
+	// Copy resources, keeping their folders
+	resources, err := ResolveResourcesFS(fsys, root, config.Resources)
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources {
+		if err := st.File(filepath.Join(root, filepath.FromSlash(resource.Src)), resource.Dest); err != nil {
+			return fmt.Errorf("failed to copy resource %s: %w", resource.Src, err)
+		}
+	}
+
+	// Resolve plugins for the target OS
+	plugins := append([]string{}, opts.Plugins...)
+	components := append([]string{}, opts.Components...)
+	for _, plugin := range config.Plugins {
+		file, kind, err := plugin.ResolveFS(fsys, root, targetOS)
+		if err != nil {
+			return err
+		}
+		if kind == PluginKindComponent {
+			components = append(components, filepath.Join(root, filepath.FromSlash(file)))
+		} else {
+			plugins = append(plugins, filepath.Join(root, filepath.FromSlash(file)))
 		}
 	}
 
 	// Copy plugins
-	pluginsDir := filepath.Join(buildDir, "plugins")
-	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
+	if err := fsys.MkdirAll(filepath.Join(st.Dir, "plugins"), 0755); err != nil {
 		return fmt.Errorf("failed to create plugins directory: %w", err)
 	}
 
-	for _, plugin := range config.Plugins {
-		if _, err := os.Stat(plugin); !os.IsNotExist(err) {
-			destPath := filepath.Join(pluginsDir, filepath.Base(plugin))
-			if err := copyFile(plugin, destPath); err != nil {
-				return fmt.Errorf("failed to copy plugin %s: %w", plugin, err)
+	var names []string
+	for _, plugin := range plugins {
+		if err := st.File(plugin, "plugins/"+filepath.Base(plugin)); err != nil {
+			return fmt.Errorf("failed to copy plugin %s: %w", plugin, err)
+		}
+		names = append(names, strings.TrimSuffix(filepath.Base(plugin), filepath.Ext(plugin)))
+	}
+
+	// Copy components
+	for _, component := range components {
+		if err := st.File(component, "components/"+filepath.Base(component)); err != nil {
+			return fmt.Errorf("failed to copy component %s: %w", component, err)
+		}
+	}
+
+	// Copy config.json, listing the legacy plugins. Other formats, such as
+	// a legacy server.cfg named by server_cfg, are copied as they are.
+	serverFile := serverConfigFile(fsys, root, config, opts.Profile)
+	if serverFile == "" {
+		return nil
+	}
+	if data, err := fsys.ReadFile(serverFile); err == nil {
+		if strings.EqualFold(filepath.Ext(serverFile), ".json") {
+			if data, err = setServerPlugins(data, names); err != nil {
+				return fmt.Errorf("failed to update plugins in %s: %w", filepath.Base(serverFile), err)
 			}
 		}
+		if err := st.Write("config.json", data, 0644); err != nil {
+			return fmt.Errorf("failed to copy server configuration: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read server configuration: %w", err)
 	}
 
 	return nil
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
+// ServerConfigFile returns the server configuration staged for a profile:
+// config.<profile>.json when it exists, otherwise config.json or, for
+// backward compatibility, the file named by server_cfg. It is empty when
+// the project names none.
+func ServerConfigFile(root string, config *ProjectConfig, profile string) string {
+	return serverConfigFile(vfs.OS, root, config, profile)
+}
+
+// serverConfigFile is ServerConfigFile for a project in fsys
+func serverConfigFile(fsys vfs.FS, root string, config *ProjectConfig, profile string) string {
+	if profile != "" {
+		profileFile := filepath.Join(root, "config."+profile+".json")
+		if vfs.Exists(fsys, profileFile) {
+			return profileFile
+		}
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	serverFile := filepath.Join(root, "config.json")
+	if _, err := fsys.Stat(serverFile); os.IsNotExist(err) {
+		if config.ServerCfg == "" {
+			return ""
+		}
+		serverFile = filepath.Join(root, config.ServerCfg)
 	}
-	defer destFile.Close()
+	return serverFile
+}
 
-	_, err = io.Copy(destFile, sourceFile)
+// setServerPlugins replaces the plugins list of a server config.json,
+// keeping the rest of the file as it is. Plugins are listed without their
+// extension, which the server adds for the OS it runs on.
+func setServerPlugins(data []byte, names []string) ([]byte, error) {
+	doc, err := jsonedit.Parse(data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := doc.Get(jsonedit.Path{{Key: "plugins"}}); err != nil && len(names) == 0 {
+		return data, nil
 	}
 
-	return nil
+	list := jsonedit.NewArray()
+	for _, name := range names {
+		list.Items = append(list.Items, jsonedit.NewString(name))
+	}
+	if err := doc.Set(jsonedit.Path{{Key: "plugins"}}, list); err != nil {
+		return nil, err
+	}
+	return doc.Bytes(), nil
 }
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weltschmerzie/omp-cli/internal/stage"
+)
+
+// stageProject writes a project, stages it and returns its build directory
+func stageProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buildDir := filepath.Join(root, "build")
+	st := stage.New(buildDir, stage.Options{})
+	if err := CopyRequiredFiles(root, st, StageOptions{TargetOS: "linux"}); err != nil {
+		t.Fatalf("CopyRequiredFiles: %v", err)
+	}
+	return buildDir
+}
+
+// staged returns a file of the build directory, reporting whether it exists
+func staged(buildDir, name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(buildDir, filepath.FromSlash(name)))
+	return string(data), err == nil
+}
+
+func TestStageWithoutServerConfig(t *testing.T) {
+	// Libraries and older projects have neither config.json nor server_cfg
+	buildDir := stageProject(t, map[string]string{
+		"project.json": `{"name": "lib", "type": "library", "main_file": "lib.inc", "output_file": "lib.amx"}`,
+	})
+	if _, ok := staged(buildDir, "config.json"); ok {
+		t.Error("config.json was staged")
+	}
+}
+
+func TestStageServerConfigPlugins(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		config string
+	}{
+		{
+			name: "config.json lists the plugins",
+			files: map[string]string{
+				"project.json":        `{"name": "gm", "main_file": "gm.pwn", "output_file": "gm.amx", "plugins": ["plugins/streamer.so"]}`,
+				"config.json":         "{\n  \"port\": 7777\n}\n",
+				"plugins/streamer.so": "so",
+			},
+			config: "{\n  \"port\": 7777,\n  \"plugins\": [\n    \"streamer\"\n  ]\n}\n",
+		},
+		{
+			name: "a legacy server.cfg is copied unchanged",
+			files: map[string]string{
+				"project.json":        `{"name": "gm", "main_file": "gm.pwn", "output_file": "gm.amx", "server_cfg": "server.cfg", "plugins": ["plugins/streamer.so"]}`,
+				"server.cfg":          "port 7777\nplugins streamer.so\n",
+				"plugins/streamer.so": "so",
+			},
+			config: "port 7777\nplugins streamer.so\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildDir := stageProject(t, tt.files)
+			if config, _ := staged(buildDir, "config.json"); config != tt.config {
+				t.Errorf("staged config.json %q, want %q", config, tt.config)
+			}
+			if _, ok := staged(buildDir, "plugins/streamer.so"); !ok {
+				t.Error("plugin not staged")
+			}
+		})
+	}
+}